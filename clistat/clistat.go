@@ -28,6 +28,17 @@ func (s *CliStat) Hit() {
 	if s.Cnt&255 != 0 {
 		return
 	}
+	s.report()
+}
+
+// Add records n hits at once (e.g. a batch of rows); progress is checked on every call
+func (s *CliStat) Add(n int64) {
+	s.Cnt += n
+	s.report()
+}
+
+// report logs progress when Timeout seconds passed since the last report
+func (s *CliStat) report() {
 	now := time.Now().Unix()
 	ellapsed := now - s.Ltime
 
@@ -67,6 +67,17 @@ func TestHitWithTimeout(t *testing.T) {
 	}
 }
 
+func TestAdd(t *testing.T) {
+	stat := clistat.New(1)
+
+	stat.Add(1000)
+	stat.Hit()
+	stat.Add(24)
+	if stat.Cnt != 1025 {
+		t.Errorf("Expected Cnt to be 1025, got %d", stat.Cnt)
+	}
+}
+
 func TestFinish(t *testing.T) {
 	stat := clistat.New(10)
 
@@ -1,12 +1,11 @@
 package main
 
 import (
-	"encoding/csv"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 
 	"github.com/parf/homebase-go-lib/fileiterator"
@@ -17,6 +16,7 @@ var (
 	tableFlag  = flag.String("table", "", "Table name (generates SELECT * FROM table)")
 	driverFlag = flag.String("driver", "mysql", "Database driver: mysql or postgre")
 	dsnFlag    = flag.String("dsn", "", "Database connection string")
+	sampleFlag = flag.Int("sample", 10000, "Number of leading records that build the CSV header")
 )
 
 func main() {
@@ -39,7 +39,7 @@ func handleFileMode() {
 		fmt.Fprintf(os.Stderr, "any2csv - Convert any format to CSV\n")
 		fmt.Fprintf(os.Stderr, "=====================================\n\n")
 		fmt.Fprintf(os.Stderr, "Usage:\n")
-		fmt.Fprintf(os.Stderr, "  File mode:  %s <input-file> [output-file|-]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  File mode:  %s <input-file|'glob'|dir> [output-file|-]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  SQL mode:   %s --dsn=\"user:pass@host\" --sql=\"SELECT * FROM table\" [output-file|-]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "              Use '-' for stdout, omit for auto-generated filename\n\n")
 
@@ -55,7 +55,8 @@ func handleFileMode() {
 		fmt.Fprintf(os.Stderr, "  --sql=\"SELECT * FROM table\"  SQL query to execute\n")
 		fmt.Fprintf(os.Stderr, "  --table=\"schema.table\"       Table name (alternative to --sql)\n")
 		fmt.Fprintf(os.Stderr, "  --driver=mysql               Database driver: mysql or postgre (default: mysql)\n")
-		fmt.Fprintf(os.Stderr, "  --dsn=\"connection-string\"    Database connection string\n\n")
+		fmt.Fprintf(os.Stderr, "  --dsn=\"connection-string\"    Database connection string\n")
+		fmt.Fprintf(os.Stderr, "  --sample=10000               Records that build the CSV header (file and SQL mode)\n\n")
 
 		fmt.Fprintf(os.Stderr, "DSN Format:\n")
 		fmt.Fprintf(os.Stderr, "  MySQL:      user:password@tcp(host:3306)/database\n")
@@ -91,11 +92,15 @@ func handleFileMode() {
 		fmt.Fprintf(os.Stderr, "  %s data.jsonl                      → data.csv\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s data.parquet -                  → stdout\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s data.jsonl output.csv           → output.csv\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "  %s data.parquet data.csv.gz        → data.csv.gz (with Gzip)\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s data.parquet data.csv.gz        → data.csv.gz (with Gzip)\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s 'events-*.jsonl.zst' all.csv    → all.csv (every matching file, quote the glob)\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s exports/ all.csv                → all.csv (every supported file in the directory)\n\n", os.Args[0])
 
 		fmt.Fprintf(os.Stderr, "Schema Support:\n")
 		fmt.Fprintf(os.Stderr, "  ✅ Automatically handles ANY structure - no schema required!\n")
-		fmt.Fprintf(os.Stderr, "  ✅ Column order is sorted alphabetically for consistency.\n\n")
+		fmt.Fprintf(os.Stderr, "  ✅ Column order is sorted alphabetically for consistency.\n")
+		fmt.Fprintf(os.Stderr, "  ⚠️  The header is the union of the fields of the first 10000 records (--sample);\n")
+		fmt.Fprintf(os.Stderr, "     a field first seen after them stops the conversion - raise --sample.\n\n")
 
 		fmt.Fprintf(os.Stderr, "See also: ./any2jsonl, ./any2parquet\n")
 		os.Exit(1)
@@ -104,6 +109,12 @@ func handleFileMode() {
 	inputFile := flag.Arg(0)
 	outputFile := flag.Arg(1)
 
+	// Globs and directories are read as one stream of records
+	if outputFile == "" && fileiterator.IsGlobInput(inputFile) {
+		fmt.Fprintf(os.Stderr, "Error: output file is required for glob and directory inputs\n")
+		os.Exit(1)
+	}
+
 	if outputFile == "" {
 		outputFile = strings.TrimSuffix(inputFile, filepath.Ext(inputFile))
 		// Handle double extensions
@@ -116,32 +127,18 @@ func handleFileMode() {
 		outputFile += ".csv"
 	}
 
-	// Read all records from input (supports ANY schema)
-	records, err := fileiterator.ReadInput(inputFile)
+	// Stream records from input (supports ANY schema)
+	reader, err := fileiterator.OpenRecordReader(inputFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
 		os.Exit(1)
 	}
+	defer reader.Close()
 
-	fmt.Fprintf(os.Stderr, "Read %d records\n", len(records))
-
-	// If output is "-", write to stdout. Otherwise, write to file.
-	if outputFile == "-" {
-		// Write to stdout
-		if err := writeCSVToWriter(os.Stdout, records); err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing to stdout: %v\n", err)
-			os.Exit(1)
-		}
-	} else {
+	if outputFile != "-" {
 		fmt.Fprintf(os.Stderr, "Converting %s -> %s\n", inputFile, outputFile)
-		// Write to CSV file (compression auto-detected from filename)
-		if err := fileiterator.WriteOutput(outputFile, records); err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing CSV: %v\n", err)
-			os.Exit(1)
-		}
-		stat, _ := os.Stat(outputFile)
-		fmt.Fprintf(os.Stderr, "Written %s (%d bytes, %.2f MB)\n", outputFile, stat.Size(), float64(stat.Size())/1024/1024)
 	}
+	convert(reader, outputFile)
 }
 
 func handleSQLMode() {
@@ -178,74 +175,56 @@ func handleSQLMode() {
 
 	fmt.Fprintf(os.Stderr, "Executing SQL query: %s\n", sqlQuery)
 
-	// Read from SQL
-	records, err := fileiterator.ReadSQLInput(*driverFlag, dsn, sqlQuery)
+	// Stream rows from SQL
+	reader, err := fileiterator.OpenSQLRecordReader(*driverFlag, dsn, sqlQuery)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error executing SQL query: %v\n", err)
 		os.Exit(1)
 	}
-
-	fmt.Fprintf(os.Stderr, "Read %d records\n", len(records))
+	defer reader.Close()
 
 	// If output file is "-" or empty, write to stdout. Otherwise, write to file.
-	if outputFile == "" || outputFile == "-" {
-		// Write to stdout
-		if err := writeCSVToWriter(os.Stdout, records); err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing to stdout: %v\n", err)
-			os.Exit(1)
-		}
-	} else {
-		// Write to file
-		if err := fileiterator.WriteOutput(outputFile, records); err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing CSV: %v\n", err)
-			os.Exit(1)
-		}
-		stat, _ := os.Stat(outputFile)
-		fmt.Fprintf(os.Stderr, "Written %s (%d bytes, %.2f MB)\n", outputFile, stat.Size(), float64(stat.Size())/1024/1024)
+	if outputFile == "" {
+		outputFile = "-"
 	}
+	convert(reader, outputFile)
 }
 
-func writeCSVToWriter(w *os.File, records []map[string]any) error {
-	if len(records) == 0 {
-		return nil
+// convert streams all records from reader to outputFile ("-" for stdout) in CSV format.
+// Records are converted one at a time, so memory use does not depend on input size.
+func convert(reader fileiterator.RecordReader, outputFile string) {
+	var out io.WriteCloser = os.Stdout
+	if outputFile != "-" {
+		// Compression auto-detected from filename
+		out = fileiterator.FUCreate(outputFile)
 	}
 
-	csvWriter := csv.NewWriter(w)
-	defer csvWriter.Flush()
-
-	// Get all unique column names and sort them for consistency
-	columnSet := make(map[string]bool)
-	for _, record := range records {
-		for key := range record {
-			columnSet[key] = true
-		}
+	writer, err := fileiterator.NewRecordWriterWithOptions(out, fileiterator.FormatCSV, fileiterator.RecordWriterOptions{SampleSize: *sampleFlag})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing CSV: %v\n", err)
+		os.Exit(1)
 	}
 
-	columns := make([]string, 0, len(columnSet))
-	for col := range columnSet {
-		columns = append(columns, col)
+	n, err := fileiterator.CopyRecords(writer, reader)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error converting records: %v\n", err)
+		os.Exit(1)
 	}
-	sort.Strings(columns)
-
-	// Write header
-	if err := csvWriter.Write(columns); err != nil {
-		return err
+	if err := writer.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing CSV: %v\n", err)
+		os.Exit(1)
 	}
-
-	// Write records
-	for _, record := range records {
-		row := make([]string, len(columns))
-		for i, col := range columns {
-			if val, ok := record[col]; ok && val != nil {
-				row[i] = fmt.Sprintf("%v", val)
-			}
-		}
-		if err := csvWriter.Write(row); err != nil {
-			return err
-		}
+	if outputFile == "-" {
+		fmt.Fprintf(os.Stderr, "Converted %d records\n", n)
+		return
+	}
+	if err := out.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing CSV: %v\n", err)
+		os.Exit(1)
 	}
 
-	return nil
+	stat, _ := os.Stat(outputFile)
+	fmt.Fprintf(os.Stderr, "Written %s (%d records, %d bytes, %.2f MB)\n", outputFile, n, stat.Size(), float64(stat.Size())/1024/1024)
 }
 
 func normalizeDSN(driver, dsn string) string {
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/parf/homebase-go-lib/fileiterator"
 	hbsql "github.com/parf/homebase-go-lib/sql"
 )
@@ -18,6 +23,9 @@ var (
 	driverFlag = flag.String("driver", "mysql", "Database driver: mysql or postgre")
 	dsnFlag    = flag.String("dsn", "", "Destination database connection string")
 	batchFlag  = flag.Int("batch", 1000, "Batch size for inserts")
+	sampleFlag = flag.Int("sample", 10000, "Number of leading records used to infer the table schema")
+	txFlag     = flag.String("tx", "none", "Transactions: none, batch (one per batch) or load (whole import)")
+	loadFlag   = flag.Bool("load-data", false, "MySQL: load batches with LOAD DATA LOCAL INFILE (server needs local_infile=ON)")
 )
 
 func main() {
@@ -51,14 +59,14 @@ func main() {
 		destTable = flag.Arg(1)
 	}
 
-	// Read data from source
-	var records []map[string]any
+	// Open streaming reader for source
+	var reader fileiterator.RecordReader
 	var err error
 
 	if source != "" {
 		// Read from file
 		fmt.Fprintf(os.Stderr, "Reading from file: %s\n", source)
-		records, err = fileiterator.ReadInput(source)
+		reader, err = fileiterator.OpenRecordReader(source)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error reading source: %v\n", err)
 			os.Exit(1)
@@ -71,20 +79,26 @@ func main() {
 			sqlQuery = fmt.Sprintf("SELECT * FROM %s", *tableFlag)
 		}
 		fmt.Fprintf(os.Stderr, "Executing source SQL: %s\n", sqlQuery)
-		records, err = fileiterator.ReadSQLInput(*driverFlag, srcDSN, sqlQuery)
+		reader, err = fileiterator.OpenSQLRecordReader(*driverFlag, srcDSN, sqlQuery)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error reading from SQL: %v\n", err)
 			os.Exit(1)
 		}
 	}
+	defer reader.Close()
 
-	if len(records) == 0 {
+	// Buffer leading records to infer the table schema
+	sample, err := readSample(reader, *sampleFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading source: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(sample) == 0 {
 		fmt.Fprintf(os.Stderr, "No records to insert\n")
 		os.Exit(0)
 	}
 
-	fmt.Fprintf(os.Stderr, "Read %d records\n", len(records))
-
 	// Connect to destination database
 	dsn := normalizeDSN(*driverFlag, *dsnFlag)
 	driver := *driverFlag
@@ -106,7 +120,7 @@ func main() {
 
 	// Get all column names (sorted for consistency)
 	columnSet := make(map[string]bool)
-	for _, record := range records {
+	for _, record := range sample {
 		for key := range record {
 			columnSet[key] = true
 		}
@@ -119,7 +133,7 @@ func main() {
 	sort.Strings(columns)
 
 	// Infer column types from data
-	columnTypes := inferColumnTypes(records, columns)
+	columnTypes := inferColumnTypes(sample, columns)
 
 	// Create table if not exists
 	fmt.Fprintf(os.Stderr, "Creating table if not exists: %s\n", destTable)
@@ -128,24 +142,112 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Insert data using BatchInserter
-	fmt.Fprintf(os.Stderr, "Inserting %d records (batch size: %d)...\n", len(records), *batchFlag)
+	// Insert data using bind parameters - values are passed to the driver unescaped
+	fmt.Fprintf(os.Stderr, "Inserting records (batch size: %d)...\n", *batchFlag)
+
+	txModes := map[string]hbsql.TxMode{"none": hbsql.NoTx, "batch": hbsql.TxPerFlush, "load": hbsql.TxPerLoad}
+	txMode, ok := txModes[*txFlag]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown --tx=%s (use none, batch or load)\n", *txFlag)
+		os.Exit(1)
+	}
+	var loc *time.Location // LOAD DATA writes times in the DSN loc, like INSERT does
+	if *loadFlag {
+		cfg, err := mysql.ParseDSN(dsn)
+		if driver != "mysql" || err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --load-data requires --driver=mysql\n")
+			os.Exit(1)
+		}
+		loc = cfg.Loc
+	}
 
 	fieldList := strings.Join(columns, ", ")
-	insert, flush := hbsql.BatchInserter(db, destTable, fieldList, *batchFlag)
-	defer flush()
+	inserter, err := hbsql.NewInserter(db, destTable, fieldList, hbsql.InserterOptions{
+		BufferSize:  *batchFlag,
+		Param:       true,
+		Placeholder: hbsql.PlaceholderFor(driver),
+		Copy:        hbsql.IsLibPQ(db), // PostgreSQL: COPY FROM STDIN
+		LoadData:    *loadFlag,
+		Loc:         loc,
+		Tx:          txMode,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	for _, record := range records {
+	ctx := context.Background()
+	count := 0
+	insertRecord := func(record map[string]any) {
+		for key := range record {
+			if !columnSet[key] {
+				fmt.Fprintf(os.Stderr, "Error: record %d has field %q not seen in the first %d records (use a larger --sample)\n", count+1, key, len(sample))
+				os.Exit(1)
+			}
+		}
 		values := make([]any, len(columns))
 		for i, col := range columns {
-			values[i] = record[col]
+			values[i] = dbValue(record[col])
 		}
-		insert(values)
+		if err := inserter.Insert(ctx, values); err != nil {
+			inserter.Rollback()
+			fmt.Fprintf(os.Stderr, "Insert error: %v\n", err)
+			os.Exit(1)
+		}
+		count++
 	}
 
-	flush() // Ensure all records are inserted
+	for _, record := range sample {
+		insertRecord(record)
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading source: %v\n", err)
+			os.Exit(1)
+		}
+		insertRecord(record)
+	}
 
-	fmt.Fprintf(os.Stderr, "Successfully inserted %d records into %s\n", len(records), destTable)
+	// Insert the last batch
+	if err := inserter.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Insert error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Successfully inserted %d records into %s\n", count, destTable)
+}
+
+// readSample reads up to n leading records from reader
+func readSample(reader fileiterator.RecordReader, n int) ([]map[string]any, error) {
+	var sample []map[string]any
+	for len(sample) < n {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		sample = append(sample, record)
+	}
+	return sample, nil
+}
+
+// dbValue converts nested values (objects, arrays) to JSON text for the driver
+func dbValue(v any) any {
+	switch v.(type) {
+	case map[string]any, []any:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+	return v
 }
 
 func inferColumnTypes(records []map[string]any, columns []string) map[string]string {
@@ -260,7 +362,7 @@ func showHelp() {
 	fmt.Fprintf(os.Stderr, "any2db - Import data from files or databases to database tables\n")
 	fmt.Fprintf(os.Stderr, "================================================================\n\n")
 	fmt.Fprintf(os.Stderr, "Usage:\n")
-	fmt.Fprintf(os.Stderr, "  File to DB:  %s --dsn=\"user:pass@host/dbname\" <source-file> <dest-table>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  File to DB:  %s --dsn=\"user:pass@host/dbname\" <source-file|'glob'|dir> <dest-table>\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "  SQL to DB:   %s --dsn=\"user:pass@host/dbname\" --sql=\"SELECT...\" <dest-table>\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "  Table copy:  %s --dsn=\"user:pass@host/dbname\" --table=\"source.table\" <dest-table>\n\n", os.Args[0])
 
@@ -269,14 +371,18 @@ func showHelp() {
 	fmt.Fprintf(os.Stderr, "  --sql=\"SELECT * FROM table\"  Source SQL query\n")
 	fmt.Fprintf(os.Stderr, "  --table=\"schema.table\"       Source table name\n")
 	fmt.Fprintf(os.Stderr, "  --driver=mysql               Database driver: mysql or postgre (default: mysql)\n")
-	fmt.Fprintf(os.Stderr, "  --batch=1000                 Batch size for inserts (default: 1000)\n\n")
+	fmt.Fprintf(os.Stderr, "  --batch=1000                 Batch size for inserts (default: 1000)\n")
+	fmt.Fprintf(os.Stderr, "  --sample=10000               Records used to infer the table schema (default: 10000)\n")
+	fmt.Fprintf(os.Stderr, "  --tx=none                    Transactions: none, batch (per batch) or load (all or nothing)\n")
+	fmt.Fprintf(os.Stderr, "  --load-data                  MySQL: load batches with LOAD DATA LOCAL INFILE (needs local_infile=ON)\n\n")
 
 	fmt.Fprintf(os.Stderr, "Features:\n")
 	fmt.Fprintf(os.Stderr, "  • Automatically creates destination table if not exists\n")
 	fmt.Fprintf(os.Stderr, "  • Infers column types from data (BIGINT, DOUBLE, TEXT, BOOLEAN)\n")
 	fmt.Fprintf(os.Stderr, "  • Supports MySQL and PostgreSQL\n")
-	fmt.Fprintf(os.Stderr, "  • Uses batch inserts for performance\n")
-	fmt.Fprintf(os.Stderr, "  • Auto-escapes values to prevent SQL injection\n\n")
+	fmt.Fprintf(os.Stderr, "  • Uses batch inserts for performance (PostgreSQL: COPY FROM STDIN)\n")
+	fmt.Fprintf(os.Stderr, "  • Streams records - constant memory for any input size\n")
+	fmt.Fprintf(os.Stderr, "  • Sends values as bind parameters - no escaping, binary and full-precision safe\n\n")
 
 	fmt.Fprintf(os.Stderr, "Supported source formats:\n")
 	fmt.Fprintf(os.Stderr, "  • Parquet (.parquet, .pk)\n")
@@ -293,6 +399,15 @@ func showHelp() {
 	fmt.Fprintf(os.Stderr, "  # Import Parquet to PostgreSQL\n")
 	fmt.Fprintf(os.Stderr, "  %s --driver=postgre --dsn=\"user:pass@pghost/mydb\" data.parquet public.orders\n\n", os.Args[0])
 
+	fmt.Fprintf(os.Stderr, "  # Import all daily shards (quote the glob; a directory works too)\n")
+	fmt.Fprintf(os.Stderr, "  %s --dsn=\"root:pass@localhost/mydb\" 'events-2026-10-*.jsonl.zst' events\n\n", os.Args[0])
+
+	fmt.Fprintf(os.Stderr, "  # All-or-nothing import: one transaction, rolled back on any error\n")
+	fmt.Fprintf(os.Stderr, "  %s --dsn=\"root:pass@localhost/mydb\" --tx=load data.jsonl.zst events\n\n", os.Args[0])
+
+	fmt.Fprintf(os.Stderr, "  # Fast MySQL bulk load with LOAD DATA LOCAL INFILE\n")
+	fmt.Fprintf(os.Stderr, "  %s --dsn=\"root:pass@localhost/mydb\" --load-data --batch=100000 hits.jsonl.zst hits\n\n", os.Args[0])
+
 	fmt.Fprintf(os.Stderr, "  # Copy table from one DB to another (same server)\n")
 	fmt.Fprintf(os.Stderr, "  %s --dsn=\"root:pass@localhost/destdb\" --table=\"sourcedb.users\" users_copy\n\n", os.Args[0])
 
@@ -306,7 +421,7 @@ func showHelp() {
 	fmt.Fprintf(os.Stderr, "  • Destination table is created with inferred schema if not exists\n")
 	fmt.Fprintf(os.Stderr, "  • If table exists, data is appended (columns must match)\n")
 	fmt.Fprintf(os.Stderr, "  • Column names are sorted alphabetically\n")
-	fmt.Fprintf(os.Stderr, "  • All string values are auto-escaped for security\n\n")
+	fmt.Fprintf(os.Stderr, "  • Nested objects and arrays are stored as JSON text\n\n")
 
 	fmt.Fprintf(os.Stderr, "See also: ./any2jsonl, ./any2parquet, ./any2csv\n")
 }
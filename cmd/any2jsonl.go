@@ -1,9 +1,9 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -38,7 +38,7 @@ func handleFileMode() {
 		fmt.Fprintf(os.Stderr, "any2jsonl - Convert any format to JSONL\n")
 		fmt.Fprintf(os.Stderr, "=======================================\n\n")
 		fmt.Fprintf(os.Stderr, "Usage:\n")
-		fmt.Fprintf(os.Stderr, "  File mode:  %s <input-file> [output-file|-]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  File mode:  %s <input-file|'glob'|dir> [output-file|-]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  SQL mode:   %s --dsn=\"user:pass@host\" --sql=\"SELECT * FROM table\" [output-file|-]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "              Use '-' for stdout, omit for auto-generated filename\n\n")
 
@@ -96,7 +96,9 @@ func handleFileMode() {
 		fmt.Fprintf(os.Stderr, "  %s data.csv -                      → stdout\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s data.parquet output.jsonl       → output.jsonl\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s data.csv data.jsonl.zst         → data.jsonl.zst (with Zstd)\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "  %s data.msgpack data.jsonl.gz      → data.jsonl.gz (with Gzip)\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s data.msgpack data.jsonl.gz      → data.jsonl.gz (with Gzip)\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s 'events-*.csv.gz' all.jsonl.zst  → all.jsonl.zst (every matching file, quote the glob)\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s exports/ -                      → stdout (every supported file in the directory)\n\n", os.Args[0])
 
 		fmt.Fprintf(os.Stderr, "Performance (1M records):\n")
 		fmt.Fprintf(os.Stderr, "  Plain JSONL: 156MB, 1.93s read, 1.38s write\n")
@@ -114,6 +116,12 @@ func handleFileMode() {
 	inputFile := flag.Arg(0)
 	outputFile := flag.Arg(1)
 
+	// Globs and directories are read as one stream of records
+	if outputFile == "" && fileiterator.IsGlobInput(inputFile) {
+		fmt.Fprintf(os.Stderr, "Error: output file is required for glob and directory inputs\n")
+		os.Exit(1)
+	}
+
 	if outputFile == "" {
 		outputFile = strings.TrimSuffix(inputFile, filepath.Ext(inputFile))
 		// Handle double extensions
@@ -126,35 +134,18 @@ func handleFileMode() {
 		outputFile += ".jsonl"
 	}
 
-	// Read all records from input (supports ANY schema)
-	records, err := fileiterator.ReadInput(inputFile)
+	// Stream records from input (supports ANY schema)
+	reader, err := fileiterator.OpenRecordReader(inputFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
 		os.Exit(1)
 	}
+	defer reader.Close()
 
-	fmt.Fprintf(os.Stderr, "Read %d records\n", len(records))
-
-	// If output is "-", write to stdout. Otherwise, write to file.
-	if outputFile == "-" {
-		// Write to stdout
-		encoder := json.NewEncoder(os.Stdout)
-		for _, record := range records {
-			if err := encoder.Encode(record); err != nil {
-				fmt.Fprintf(os.Stderr, "Error writing to stdout: %v\n", err)
-				os.Exit(1)
-			}
-		}
-	} else {
+	if outputFile != "-" {
 		fmt.Fprintf(os.Stderr, "Converting %s -> %s\n", inputFile, outputFile)
-		// Write to JSONL file (compression auto-detected from filename)
-		if err := fileiterator.WriteOutput(outputFile, records); err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing JSONL: %v\n", err)
-			os.Exit(1)
-		}
-		stat, _ := os.Stat(outputFile)
-		fmt.Fprintf(os.Stderr, "Written %s (%d bytes, %.2f MB)\n", outputFile, stat.Size(), float64(stat.Size())/1024/1024)
 	}
+	convert(reader, outputFile)
 }
 
 func handleSQLMode() {
@@ -191,34 +182,56 @@ func handleSQLMode() {
 
 	fmt.Fprintf(os.Stderr, "Executing SQL query: %s\n", sqlQuery)
 
-	// Read from SQL
-	records, err := fileiterator.ReadSQLInput(*driverFlag, dsn, sqlQuery)
+	// Stream rows from SQL
+	reader, err := fileiterator.OpenSQLRecordReader(*driverFlag, dsn, sqlQuery)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error executing SQL query: %v\n", err)
 		os.Exit(1)
 	}
-
-	fmt.Fprintf(os.Stderr, "Read %d records\n", len(records))
+	defer reader.Close()
 
 	// If output file is "-" or empty, write to stdout. Otherwise, write to file.
-	if outputFile == "" || outputFile == "-" {
-		// Write to stdout
-		encoder := json.NewEncoder(os.Stdout)
-		for _, record := range records {
-			if err := encoder.Encode(record); err != nil {
-				fmt.Fprintf(os.Stderr, "Error writing to stdout: %v\n", err)
-				os.Exit(1)
-			}
-		}
-	} else {
-		// Write to file
-		if err := fileiterator.WriteOutput(outputFile, records); err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing JSONL: %v\n", err)
-			os.Exit(1)
-		}
-		stat, _ := os.Stat(outputFile)
-		fmt.Fprintf(os.Stderr, "Written %s (%d bytes, %.2f MB)\n", outputFile, stat.Size(), float64(stat.Size())/1024/1024)
+	if outputFile == "" {
+		outputFile = "-"
+	}
+	convert(reader, outputFile)
+}
+
+// convert streams all records from reader to outputFile ("-" for stdout) in JSONL format.
+// Records are converted one at a time, so memory use does not depend on input size.
+func convert(reader fileiterator.RecordReader, outputFile string) {
+	var out io.WriteCloser = os.Stdout
+	if outputFile != "-" {
+		// Compression auto-detected from filename
+		out = fileiterator.FUCreate(outputFile)
 	}
+
+	writer, err := fileiterator.NewRecordWriter(out, fileiterator.FormatJSONL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing JSONL: %v\n", err)
+		os.Exit(1)
+	}
+
+	n, err := fileiterator.CopyRecords(writer, reader)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error converting records: %v\n", err)
+		os.Exit(1)
+	}
+	if err := writer.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing JSONL: %v\n", err)
+		os.Exit(1)
+	}
+	if outputFile == "-" {
+		fmt.Fprintf(os.Stderr, "Converted %d records\n", n)
+		return
+	}
+	if err := out.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing JSONL: %v\n", err)
+		os.Exit(1)
+	}
+
+	stat, _ := os.Stat(outputFile)
+	fmt.Fprintf(os.Stderr, "Written %s (%d records, %d bytes, %.2f MB)\n", outputFile, n, stat.Size(), float64(stat.Size())/1024/1024)
 }
 
 func normalizeDSN(driver, dsn string) string {
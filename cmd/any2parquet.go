@@ -37,7 +37,7 @@ func handleFileMode() {
 		fmt.Fprintf(os.Stderr, "any2parquet - Convert any format to Parquet (RECOMMENDED) 🏆\n")
 		fmt.Fprintf(os.Stderr, "=============================================================\n\n")
 		fmt.Fprintf(os.Stderr, "Usage:\n")
-		fmt.Fprintf(os.Stderr, "  File mode:  %s <input-file> [output-file|-]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  File mode:  %s <input-file|'glob'|dir> [output-file|-]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  SQL mode:   %s --dsn=\"user:pass@host\" --sql=\"SELECT * FROM table\" [output-file|-]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "              Use '-' for stdout, omit for auto-generated filename\n\n")
 
@@ -80,25 +80,25 @@ func handleFileMode() {
 		fmt.Fprintf(os.Stderr, "  .br  → Brotli (best compression, but very slow)\n")
 		fmt.Fprintf(os.Stderr, "  .xz  → XZ/LZMA (excellent compression, extremely slow - avoid)\n\n")
 
-		fmt.Fprintf(os.Stderr, "⚠️  IMPORTANT: Parquet already has built-in Snappy compression!\n")
-		fmt.Fprintf(os.Stderr, "   Additional compression (.parquet.gz/.zst/.lz4) is usually NOT needed.\n")
-		fmt.Fprintf(os.Stderr, "   It only gives ~10-15%% smaller files but slower access.\n\n")
+		fmt.Fprintf(os.Stderr, "⚠️  IMPORTANT: Parquet compresses data pages itself (Snappy by default)!\n")
+		fmt.Fprintf(os.Stderr, "   .parquet.gz/.zst/.lz4/.br select the Parquet page codec - the file stays\n")
+		fmt.Fprintf(os.Stderr, "   a regular Parquet file that any Parquet tool can read.\n\n")
 
 		fmt.Fprintf(os.Stderr, "Examples:\n")
 		fmt.Fprintf(os.Stderr, "  %s data.jsonl.gz                      → data.parquet\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s data.csv -                         → stdout\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s data.csv.zst output.pq             → output.pq\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s data.jsonl data.parquet.zst        → data.parquet.zst (with Zstd)\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "  %s data.msgpack data.parquet.lz4      → data.parquet.lz4 (with LZ4)\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s data.msgpack data.parquet.lz4      → data.parquet.lz4 (with LZ4)\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s 'events-*.jsonl.zst' all.parquet   → all.parquet (every matching file, quote the glob)\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s exports/ all.parquet               → all.parquet (every supported file in the directory)\n\n", os.Args[0])
 
 		fmt.Fprintf(os.Stderr, "Output compression (recognized extension → format):\n")
-		fmt.Fprintf(os.Stderr, "  .parquet     → Parquet with built-in Snappy compression\n")
-		fmt.Fprintf(os.Stderr, "  .parquet.zst → Parquet + Zstandard (~15%% smaller, slower access)\n")
-		fmt.Fprintf(os.Stderr, "  .parquet.lz4 → Parquet + LZ4 (~10%% smaller, slower access)\n")
-		fmt.Fprintf(os.Stderr, "  .parquet.gz  → Parquet + Gzip (~10-15%% smaller, slower access)\n")
-		fmt.Fprintf(os.Stderr, "  \n")
-		fmt.Fprintf(os.Stderr, "  ⚠️  Additional compression is usually NOT needed!\n")
-		fmt.Fprintf(os.Stderr, "  Parquet already has built-in Snappy compression.\n\n")
+		fmt.Fprintf(os.Stderr, "  .parquet     → Parquet with Snappy pages\n")
+		fmt.Fprintf(os.Stderr, "  .parquet.zst → Parquet with Zstandard pages (~15%% smaller)\n")
+		fmt.Fprintf(os.Stderr, "  .parquet.lz4 → Parquet with LZ4 pages\n")
+		fmt.Fprintf(os.Stderr, "  .parquet.gz  → Parquet with Gzip pages (~10-15%% smaller, slower)\n")
+		fmt.Fprintf(os.Stderr, "  .parquet.br  → Parquet with Brotli pages\n\n")
 
 		fmt.Fprintf(os.Stderr, "Performance (1M records):\n")
 		fmt.Fprintf(os.Stderr, "  Read:  0.15s (4x faster than MsgPack, 13x faster than JSONL)\n")
@@ -107,7 +107,7 @@ func handleFileMode() {
 
 		fmt.Fprintf(os.Stderr, "Schema Support:\n")
 		fmt.Fprintf(os.Stderr, "  Automatically infers schema from your data - supports ANY structure!\n")
-		fmt.Fprintf(os.Stderr, "  Supported types: int64, float64, string, bool\n\n")
+		fmt.Fprintf(os.Stderr, "  Supported types: int64, float64, string, bool, nested objects and arrays\n\n")
 
 		fmt.Fprintf(os.Stderr, "Full Benchmark Results:\n")
 		fmt.Fprintf(os.Stderr, "  https://github.com/parf/homebase-go-lib/blob/main/benchmarks/serialization-benchmark-result.md\n\n")
@@ -122,6 +122,12 @@ func handleFileMode() {
 	inputFile := flag.Arg(0)
 	outputFile := flag.Arg(1)
 
+	// Globs and directories are read as one stream of records
+	if outputFile == "" && fileiterator.IsGlobInput(inputFile) {
+		fmt.Fprintf(os.Stderr, "Error: output file is required for glob and directory inputs\n")
+		os.Exit(1)
+	}
+
 	if outputFile == "" {
 		outputFile = strings.TrimSuffix(inputFile, filepath.Ext(inputFile))
 		// Handle double extensions like .jsonl.gz
@@ -134,45 +140,18 @@ func handleFileMode() {
 		outputFile += ".parquet"
 	}
 
-	// Read all records from input (supports ANY schema)
-	records, err := fileiterator.ReadInput(inputFile)
+	// Stream records from input (supports ANY schema)
+	reader, err := fileiterator.OpenRecordReader(inputFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
 		os.Exit(1)
 	}
+	defer reader.Close()
 
-	fmt.Fprintf(os.Stderr, "Read %d records\n", len(records))
-
-	// If output is "-", write to stdout. Otherwise, write to file.
-	if outputFile == "-" {
-		// Write to stdout (using temp file since Parquet needs seekable writer)
-		tmpFile := "/tmp/any2parquet-" + fmt.Sprintf("%d", os.Getpid()) + ".parquet"
-		if err := fileiterator.WriteParquetAny(tmpFile, records); err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing Parquet: %v\n", err)
-			os.Exit(1)
-		}
-		defer os.Remove(tmpFile)
-
-		// Copy to stdout
-		data, err := os.ReadFile(tmpFile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading temp file: %v\n", err)
-			os.Exit(1)
-		}
-		if _, err := os.Stdout.Write(data); err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing to stdout: %v\n", err)
-			os.Exit(1)
-		}
-	} else {
+	if outputFile != "-" {
 		fmt.Fprintf(os.Stderr, "Converting %s -> %s\n", inputFile, outputFile)
-		// Write to Parquet file (compression auto-detected from filename)
-		if err := fileiterator.WriteParquetAny(outputFile, records); err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing Parquet: %v\n", err)
-			os.Exit(1)
-		}
-		stat, _ := os.Stat(outputFile)
-		fmt.Fprintf(os.Stderr, "Written %s (%d bytes, %.2f MB)\n", outputFile, stat.Size(), float64(stat.Size())/1024/1024)
 	}
+	convert(reader, outputFile)
 }
 
 func handleSQLMode() {
@@ -209,44 +188,54 @@ func handleSQLMode() {
 
 	fmt.Fprintf(os.Stderr, "Executing SQL query: %s\n", sqlQuery)
 
-	// Read from SQL
-	records, err := fileiterator.ReadSQLInput(*driverFlag, dsn, sqlQuery)
+	// Stream rows from SQL
+	reader, err := fileiterator.OpenSQLRecordReader(*driverFlag, dsn, sqlQuery)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error executing SQL query: %v\n", err)
 		os.Exit(1)
 	}
-
-	fmt.Fprintf(os.Stderr, "Read %d records\n", len(records))
+	defer reader.Close()
 
 	// If output file is "-" or empty, write to stdout. Otherwise, write to file.
-	if outputFile == "" || outputFile == "-" {
-		// Write to stdout (using temp file since Parquet needs seekable writer)
-		tmpFile := "/tmp/any2parquet-" + fmt.Sprintf("%d", os.Getpid()) + ".parquet"
-		if err := fileiterator.WriteParquetAny(tmpFile, records); err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing Parquet: %v\n", err)
-			os.Exit(1)
-		}
-		defer os.Remove(tmpFile)
+	if outputFile == "" {
+		outputFile = "-"
+	}
+	convert(reader, outputFile)
+}
 
-		// Copy to stdout
-		data, err := os.ReadFile(tmpFile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading temp file: %v\n", err)
-			os.Exit(1)
-		}
-		if _, err := os.Stdout.Write(data); err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing to stdout: %v\n", err)
-			os.Exit(1)
-		}
+// convert streams all records from reader to outputFile ("-" for stdout) in Parquet format.
+// Records are written one row group at a time, so memory use does not depend on input size.
+func convert(reader fileiterator.RecordReader, outputFile string) {
+	opts := fileiterator.DefaultParquetWriteOptions()
+	var writer *fileiterator.ParquetWriter
+	var err error
+	if outputFile == "-" {
+		writer = fileiterator.NewParquetWriter(os.Stdout, opts)
 	} else {
-		// Write to file
-		if err := fileiterator.WriteParquetAny(outputFile, records); err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing Parquet: %v\n", err)
-			os.Exit(1)
-		}
-		stat, _ := os.Stat(outputFile)
-		fmt.Fprintf(os.Stderr, "Written %s (%d bytes, %.2f MB)\n", outputFile, stat.Size(), float64(stat.Size())/1024/1024)
+		// .parquet.zst etc. select the Parquet page codec instead of compressing the file
+		writer, err = fileiterator.CreateParquetWriter(outputFile, opts)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing Parquet: %v\n", err)
+		os.Exit(1)
+	}
+
+	n, err := fileiterator.CopyRecords(writer, reader)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error converting records: %v\n", err)
+		os.Exit(1)
+	}
+	if err := writer.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing Parquet: %v\n", err)
+		os.Exit(1)
+	}
+	if outputFile == "-" {
+		fmt.Fprintf(os.Stderr, "Converted %d records\n", n)
+		return
 	}
+
+	stat, _ := os.Stat(outputFile)
+	fmt.Fprintf(os.Stderr, "Written %s (%d records, %d bytes, %.2f MB)\n", outputFile, n, stat.Size(), float64(stat.Size())/1024/1024)
 }
 
 func normalizeDSN(driver, dsn string) string {
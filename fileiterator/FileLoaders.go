@@ -2,185 +2,41 @@ package fileiterator
 
 import (
 	"bufio"
-	"compress/gzip"
-	"compress/zlib"
+	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"net/http"
-	"os"
 	"strconv"
 	"strings"
 
-	"github.com/andybalholm/brotli"
 	"github.com/klauspost/compress/zstd"
-	"github.com/pierrec/lz4/v4"
-	"github.com/ulikunitz/xz"
 )
 
-// FUOpen opens a file or URL and returns an io.ReadCloser.
-// Automatically detects and decompresses files based on extension:
-// .gz (gzip), .zst/.zst1/.zst2 (zstd), .zlib/.zz (zlib), .lz4 (lz4), .br (brotli), .xz (xz)
+// FUOpen opens a file, URL or "-" (stdin) and returns an io.ReadCloser.
+// Automatically detects and decompresses by magic bytes (gzip, zstd, lz4, xz, zlib, bzip2)
+// or, when the content has no known magic, by extension:
+// .gz (gzip), .zst/.zst1/.zst2 (zstd), .zlib/.zz (zlib), .lz4 (lz4), .br (brotli), .xz (xz), .bz2 (bzip2)
+// Use ReaderCodec to see the chosen codec and OpenWithOptions to override it.
+// Panics on error - use Open for the error-returning version.
 func FUOpen(file_or_url string) io.ReadCloser {
-	var base io.ReadCloser
-
-	if strings.HasPrefix(file_or_url, "http") {
-		resp, err := http.Get(file_or_url)
-		if err != nil {
-			panic(err)
-		}
-		if resp.StatusCode != 200 {
-			err := fmt.Errorf("Url: %s - Unexpected HTTP Code %d", file_or_url, resp.StatusCode)
-			panic(err)
-		}
-		base = resp.Body
-	} else {
-		r, err := os.Open(file_or_url)
-		if err != nil {
-			panic(err)
-		}
-		base = r
-	}
-
-	// Auto-detect compression by extension
-	if strings.HasSuffix(file_or_url, ".gz") {
-		gz, err := gzip.NewReader(base)
-		if err != nil {
-			base.Close()
-			panic(err)
-		}
-		return &combinedCloser{Reader: gz, closers: []io.Closer{gz, base}}
-	} else if strings.HasSuffix(file_or_url, ".zst") || strings.HasSuffix(file_or_url, ".zst1") || strings.HasSuffix(file_or_url, ".zst2") {
-		// All zstd levels use the same decoder
-		zr, err := zstd.NewReader(base)
-		if err != nil {
-			base.Close()
-			panic(err)
-		}
-		return &zstdReadCloser{decoder: zr, base: base}
-	} else if strings.HasSuffix(file_or_url, ".zlib") || strings.HasSuffix(file_or_url, ".zz") {
-		zr, err := zlib.NewReader(base)
-		if err != nil {
-			base.Close()
-			panic(err)
-		}
-		return &combinedCloser{Reader: zr, closers: []io.Closer{zr, base}}
-	} else if strings.HasSuffix(file_or_url, ".lz4") {
-		lzr := lz4.NewReader(base)
-		return &simpleReadCloser{Reader: lzr, base: base}
-	} else if strings.HasSuffix(file_or_url, ".br") {
-		brr := brotli.NewReader(base)
-		return &simpleReadCloser{Reader: brr, base: base}
-	} else if strings.HasSuffix(file_or_url, ".xz") {
-		xzr, err := xz.NewReader(base)
-		if err != nil {
-			base.Close()
-			panic(err)
-		}
-		return &simpleReadCloser{Reader: xzr, base: base}
-	}
-
-	return base
-}
-
-// combinedCloser closes multiple closers
-type combinedCloser struct {
-	io.Reader
-	closers []io.Closer
-}
-
-func (c *combinedCloser) Close() error {
-	var firstErr error
-	for _, closer := range c.closers {
-		if err := closer.Close(); err != nil && firstErr == nil {
-			firstErr = err
-		}
+	r, err := Open(context.Background(), file_or_url)
+	if err != nil {
+		panic(err)
 	}
-	return firstErr
-}
-
-// zstdReadCloser wraps zstd decoder to implement io.ReadCloser
-type zstdReadCloser struct {
-	decoder *zstd.Decoder
-	base    io.ReadCloser
-}
-
-func (z *zstdReadCloser) Read(p []byte) (int, error) {
-	return z.decoder.Read(p)
-}
-
-func (z *zstdReadCloser) Close() error {
-	z.decoder.Close()
-	return z.base.Close()
-}
-
-// simpleReadCloser wraps an io.Reader with a base closer
-type simpleReadCloser struct {
-	io.Reader
-	base io.ReadCloser
-}
-
-func (s *simpleReadCloser) Close() error {
-	return s.base.Close()
+	return r
 }
 
 // FUCreate creates a file and returns an io.WriteCloser.
 // Automatically compresses based on file extension:
 // .gz (gzip), .zst (zstd default), .zst1 (zstd level 1), .zst2 (zstd level 2),
 // .zlib/.zz (zlib), .lz4 (lz4), .br (brotli), .xz (xz)
+// Use Create with CreateOptions{Atomic: true} to write a temporary file that Close renames into place.
+// Panics on error - use Create for the error-returning version.
 func FUCreate(filename string) io.WriteCloser {
-	file, err := os.Create(filename)
+	w, err := Create(filename, CreateOptions{})
 	if err != nil {
 		panic(err)
 	}
-
-	// Auto-detect compression by extension
-	if strings.HasSuffix(filename, ".gz") {
-		gzw := gzip.NewWriter(file)
-		return &combinedWriteCloser{Writer: gzw, closers: []io.Closer{gzw, file}}
-	} else if strings.HasSuffix(filename, ".zst1") {
-		// Zstd level 1 - fastest compression
-		zw, err := zstd.NewWriter(file, zstd.WithEncoderLevel(zstd.SpeedFastest))
-		if err != nil {
-			file.Close()
-			panic(err)
-		}
-		return &zstdWriteCloser{encoder: zw, base: file}
-	} else if strings.HasSuffix(filename, ".zst2") {
-		// Zstd level 2 - fast compression
-		zw, err := zstd.NewWriter(file, zstd.WithEncoderLevel(zstd.SpeedDefault))
-		if err != nil {
-			file.Close()
-			panic(err)
-		}
-		return &zstdWriteCloser{encoder: zw, base: file}
-	} else if strings.HasSuffix(filename, ".zst") {
-		// Zstd default level (3)
-		zw, err := zstd.NewWriter(file)
-		if err != nil {
-			file.Close()
-			panic(err)
-		}
-		return &zstdWriteCloser{encoder: zw, base: file}
-	} else if strings.HasSuffix(filename, ".zlib") || strings.HasSuffix(filename, ".zz") {
-		zlibw := zlib.NewWriter(file)
-		return &combinedWriteCloser{Writer: zlibw, closers: []io.Closer{zlibw, file}}
-	} else if strings.HasSuffix(filename, ".lz4") {
-		lzw := lz4.NewWriter(file)
-		return &simpleWriteCloser{Writer: lzw, base: file}
-	} else if strings.HasSuffix(filename, ".br") {
-		brw := brotli.NewWriter(file)
-		return &simpleWriteCloser{Writer: brw, base: file}
-	} else if strings.HasSuffix(filename, ".xz") {
-		xzw, err := xz.NewWriter(file)
-		if err != nil {
-			file.Close()
-			panic(err)
-		}
-		return &simpleWriteCloser{Writer: xzw, base: file}
-	}
-
-	return file
+	return w
 }
 
 // combinedWriteCloser closes multiple closers in sequence
@@ -239,53 +95,105 @@ func (s *simpleWriteCloser) Close() error {
 
 // LoadBinFile loads a file with automatic decompression into a byte buffer
 // Supported: .gz (gzip), .zst (zstd), .zlib/.zz (zlib), .lz4 (lz4), .br (brotli), .xz (xz)
+// Panics on error - use LoadBinFileE for the error-returning version.
 func LoadBinFile(filename string, dest *[]byte) {
-	fi := FUOpen(filename) // FUOpen handles compression automatically
-	defer fi.Close()
 	var err error
-	*dest, err = ioutil.ReadAll(fi)
+	*dest, err = LoadBinFileE(filename)
 	if err != nil {
 		panic(err)
 	}
 	fmt.Printf("File %s loaded. %d bytes\n", filename, len(*dest))
 }
 
+// LoadBinFileE loads a file with automatic decompression and returns its content
+func LoadBinFileE(filename string) ([]byte, error) {
+	fi, err := Open(context.Background(), filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+	return io.ReadAll(fi)
+}
+
 // IterateLines processes lines in a file with automatic decompression
 // Supported: .gz (gzip), .zst (zstd), .zlib/.zz (zlib), .lz4 (lz4), .br (brotli), .xz (xz)
+// Panics on error - use IterateLinesE for the error-returning version.
 func IterateLines(filename string, processor func(string)) {
-	fi := FUOpen(filename) // FUOpen handles compression automatically
+	err := IterateLinesE(filename, func(line string) error {
+		processor(line)
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// IterateLinesE processes lines in a file with automatic decompression.
+// Stops at the first processor error and returns it with the line number.
+func IterateLinesE(filename string, processor func(string) error) error {
+	fi, err := Open(context.Background(), filename)
+	if err != nil {
+		return err
+	}
 	defer fi.Close()
 	scanner := bufio.NewScanner(fi)
 	count := 0
 	for scanner.Scan() {
 		line := scanner.Text()
-		processor(line)
 		count++
+		if err := processor(line); err != nil {
+			return fmt.Errorf("line %d: processor error: %w", count, err)
+		}
 	}
 	if err := scanner.Err(); err != nil {
-		panic(err)
+		return err
 	}
 	fmt.Printf("File %s. Lines processed: %d\n", filename, count)
+	return nil
 }
 
 // IterateIDTabFile iterates over TAB separated (ID <tab> NAME) file with automatic decompression
 // ID is parsed as hexadecimal int32, NAME is converted to lowercase
 // Supported: .gz (gzip), .zst (zstd), .zlib/.zz (zlib), .lz4 (lz4), .br (brotli), .xz (xz)
+// Panics on error - use IterateIDTabFileE for the error-returning version.
 func IterateIDTabFile(filename string, processor func(int32, string)) {
-	fi := FUOpen(filename) // FUOpen handles compression automatically
+	err := IterateIDTabFileE(filename, func(id int32, name string) error {
+		processor(id, name)
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// IterateIDTabFileE iterates over TAB separated (ID <tab> NAME) file with automatic decompression.
+// Malformed lines and processor errors are returned with the line number.
+func IterateIDTabFileE(filename string, processor func(int32, string) error) error {
+	fi, err := Open(context.Background(), filename)
+	if err != nil {
+		return err
+	}
 	defer fi.Close()
 	scanner := bufio.NewScanner(fi)
 	count := 0
 	for scanner.Scan() {
 		line := scanner.Text()
 		lc := strings.Split(line, "\t")
+		if len(lc) < 2 {
+			return fmt.Errorf("line %d: expected ID<tab>NAME, got %q", count+1, line)
+		}
 		id, err := strconv.ParseInt(lc[0], 16, 32)
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("line %d: %w", count+1, err)
 		}
-		processor(int32(id), strings.ToLower(lc[1]))
 		count++
+		if err := processor(int32(id), strings.ToLower(lc[1])); err != nil {
+			return fmt.Errorf("line %d: processor error: %w", count, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
 	}
 	fmt.Printf("File %s. Lines processed: %d\n", filename, count)
+	return nil
 }
-
@@ -1,214 +1,146 @@
 package fileiterator
 
 import (
-	"bufio"
-	"compress/gzip"
-	"compress/zlib"
+	"context"
 	"fmt"
 	"io"
-	"net/http"
-	"os"
-	"strings"
 
-	"github.com/klauspost/compress/zstd"
 	"github.com/parf/homebase-go-lib/clistat"
 )
 
 // IterateBinaryRecords iterates over a file of binary records of fixed recordSize.
-// Automatically detects compression format by extension: .gz (gzip), .zst (zstd), .zlib/.zz (zlib)
-// If no compression extension is detected, processes file as plain binary.
+// Automatically detects compression format by extension:
+// .gz, .zst, .zlib/.zz, .lz4, .br, .xz, .bz2
+// If no compression extension is detected, processes file as plain binary - records are
+// arbitrary bytes, so unlike FUOpen a leading magic number does not select a decompressor.
 // Calls processor function on every record.
 //
 // filename - "filename" or "http://url"
 // recordSize - size of each binary record in bytes
+//
+// Panics if the file can not be opened - use IterateBinaryRecordsE for the error-returning version.
 func IterateBinaryRecords(filename string, recordSize int, processor func([]byte)) {
-	var b io.Reader // base reader
-	var closer io.Closer
-
-	if strings.HasPrefix(filename, "http") {
-		resp, err := http.Get(filename)
-		if err != nil {
-			panic(err)
-		}
-		b = resp.Body
-		closer = resp.Body
-	} else {
-		f, err := os.Open(filename)
-		if err != nil {
-			panic(err)
-		}
-		b = bufio.NewReader(f)
-		closer = f
-	}
-	defer closer.Close()
+	iterateRecordsCodec(filename, "", recordSize, processor)
+}
 
-	// Detect compression by extension
-	var r io.Reader
-	var err error
+// IterateBinaryRecordsE iterates over a file of binary records of fixed recordSize.
+// Same as IterateBinaryRecords, but returns open, read and processor errors.
+// A truncated trailing record is reported as io.ErrUnexpectedEOF.
+func IterateBinaryRecordsE(filename string, recordSize int, processor func([]byte) error) error {
+	return iterateRecordsCodecE(filename, "", recordSize, processor)
+}
 
-	if strings.HasSuffix(filename, ".gz") {
-		r, err = gzip.NewReader(b)
-		if err != nil {
-			panic(err)
-		}
-	} else if strings.HasSuffix(filename, ".zst") {
-		zr, err := zstd.NewReader(b)
-		if err != nil {
-			panic(err)
-		}
-		defer zr.Close()
-		r = zr
-	} else if strings.HasSuffix(filename, ".zlib") || strings.HasSuffix(filename, ".zz") {
-		r, err = zlib.NewReader(b)
-		if err != nil {
-			panic(err)
-		}
-	} else {
-		// No compression - plain binary file
-		r = b
+// IterateBinaryRecordsWithOptions is IterateBinaryRecordsE with checkpoints and resume (see CheckpointOptions).
+// Records have a fixed size, so resuming is exact: uncompressed and seekable zstd files seek
+// straight to the position, other codecs are decoded and skipped without calling processor.
+func IterateBinaryRecordsWithOptions(filename string, recordSize int, opts CheckpointOptions, processor func([]byte) error) error {
+	c, err := openCheckpointed(filename, recordsCodec(filename), opts)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if c.pos.Offset != c.pos.Records*int64(recordSize) {
+		return fmt.Errorf("resume position %+v does not match record size %d", c.pos, recordSize)
 	}
 
-	buf := make([]byte, recordSize)
-	stat := clistat.New(10)
-	fmt.Printf("Loading: %v\n", filename)
-	for {
-		n, err := io.ReadFull(r, buf)
-		if err != nil {
-			if err != io.EOF {
-				fmt.Printf("cnt: %d read:%d\n", stat.Cnt, n)
-				fmt.Println(err)
-			}
-			break
+	err = iterateRecords(c.src, filename, recordSize, func(rec []byte) error {
+		if err := processor(rec); err != nil {
+			return err
 		}
-		stat.Hit()
-		processor(buf)
+		return c.advance(recordSize)
+	})
+	if err == nil && c.since > 0 {
+		err = c.checkpoint()
 	}
-	stat.Finish()
+	return err
 }
 
 // IterateZlibRecords iterates over zlib-compressed file of binary records (explicit zlib)
 // This is the original function for backward compatibility
 func IterateZlibRecords(filename string, recordSize int, processor func([]byte)) {
-	var b io.Reader
-	if strings.HasPrefix(filename, "http") {
-		resp, err := http.Get(filename)
-		if err != nil {
-			panic(err)
-		}
-		b = resp.Body
-	} else {
-		f, err := os.Open(filename)
-		if err != nil {
-			panic(err)
-		}
-		defer f.Close()
-		b = bufio.NewReader(f)
-	}
-
-	r, err := zlib.NewReader(b) // RFC 1950
-	if err != nil {
-		panic(err)
-	}
-	defer r.Close()
+	iterateRecordsCodec(filename, CodecZlib, recordSize, processor)
+}
 
-	buf := make([]byte, recordSize)
-	stat := clistat.New(10)
-	fmt.Printf("Loading: %v\n", filename)
-	for {
-		n, err := io.ReadFull(r, buf)
-		if err != nil {
-			if err != io.EOF {
-				fmt.Printf("cnt: %d read:%d\n", stat.Cnt, n)
-				fmt.Println(err)
-			}
-			break
-		}
-		stat.Hit()
-		processor(buf)
-	}
-	stat.Finish()
+// IterateZlibRecordsE is the error-returning version of IterateZlibRecords
+func IterateZlibRecordsE(filename string, recordSize int, processor func([]byte) error) error {
+	return iterateRecordsCodecE(filename, CodecZlib, recordSize, processor)
 }
 
 // IterateGzipRecords iterates over gzip-compressed file of binary records (explicit gzip)
 func IterateGzipRecords(filename string, recordSize int, processor func([]byte)) {
-	var b io.Reader
-	if strings.HasPrefix(filename, "http") {
-		resp, err := http.Get(filename)
-		if err != nil {
-			panic(err)
-		}
-		b = resp.Body
-	} else {
-		f, err := os.Open(filename)
-		if err != nil {
-			panic(err)
-		}
-		defer f.Close()
-		b = bufio.NewReader(f)
-	}
+	iterateRecordsCodec(filename, CodecGzip, recordSize, processor)
+}
 
-	r, err := gzip.NewReader(b)
+// IterateGzipRecordsE is the error-returning version of IterateGzipRecords
+func IterateGzipRecordsE(filename string, recordSize int, processor func([]byte) error) error {
+	return iterateRecordsCodecE(filename, CodecGzip, recordSize, processor)
+}
+
+// IterateZstdRecords iterates over zstd-compressed file of binary records (explicit zstd)
+func IterateZstdRecords(filename string, recordSize int, processor func([]byte)) {
+	iterateRecordsCodec(filename, CodecZstd, recordSize, processor)
+}
+
+// IterateZstdRecordsE is the error-returning version of IterateZstdRecords
+func IterateZstdRecordsE(filename string, recordSize int, processor func([]byte) error) error {
+	return iterateRecordsCodecE(filename, CodecZstd, recordSize, processor)
+}
+
+// recordsCodec returns the codec of a raw record file by extension only:
+// the first record may start with bytes that look like a compression magic
+func recordsCodec(filename string) string {
+	return codecFromSuffix(sourceName(filename))
+}
+
+// iterateRecordsCodec panics on open errors and prints read errors (legacy behavior)
+func iterateRecordsCodec(filename, codec string, recordSize int, processor func([]byte)) {
+	if codec == "" {
+		codec = recordsCodec(filename)
+	}
+	r, err := OpenWithOptions(context.Background(), filename, OpenOptions{Codec: codec})
 	if err != nil {
 		panic(err)
 	}
 	defer r.Close()
 
-	buf := make([]byte, recordSize)
-	stat := clistat.New(10)
-	fmt.Printf("Loading: %v\n", filename)
-	for {
-		n, err := io.ReadFull(r, buf)
-		if err != nil {
-			if err != io.EOF {
-				fmt.Printf("cnt: %d read:%d\n", stat.Cnt, n)
-				fmt.Println(err)
-			}
-			break
-		}
-		stat.Hit()
-		processor(buf)
+	err = iterateRecords(r, filename, recordSize, func(rec []byte) error {
+		processor(rec)
+		return nil
+	})
+	if err != nil {
+		fmt.Println(err)
 	}
-	stat.Finish()
 }
 
-// IterateZstdRecords iterates over zstd-compressed file of binary records (explicit zstd)
-func IterateZstdRecords(filename string, recordSize int, processor func([]byte)) {
-	var b io.Reader
-	if strings.HasPrefix(filename, "http") {
-		resp, err := http.Get(filename)
-		if err != nil {
-			panic(err)
-		}
-		b = resp.Body
-	} else {
-		f, err := os.Open(filename)
-		if err != nil {
-			panic(err)
-		}
-		defer f.Close()
-		b = bufio.NewReader(f)
+func iterateRecordsCodecE(filename, codec string, recordSize int, processor func([]byte) error) error {
+	if codec == "" {
+		codec = recordsCodec(filename)
 	}
-
-	r, err := zstd.NewReader(b)
+	r, err := OpenWithOptions(context.Background(), filename, OpenOptions{Codec: codec})
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer r.Close()
+	return iterateRecords(r, filename, recordSize, processor)
+}
 
+// iterateRecords reads fixed-size records from r until EOF
+func iterateRecords(r io.Reader, filename string, recordSize int, processor func([]byte) error) error {
 	buf := make([]byte, recordSize)
 	stat := clistat.New(10)
 	fmt.Printf("Loading: %v\n", filename)
+	defer stat.Finish()
 	for {
 		n, err := io.ReadFull(r, buf)
 		if err != nil {
-			if err != io.EOF {
-				fmt.Printf("cnt: %d read:%d\n", stat.Cnt, n)
-				fmt.Println(err)
+			if err == io.EOF {
+				return nil
 			}
-			break
+			return fmt.Errorf("cnt: %d read:%d: %w", stat.Cnt, n, err)
 		}
 		stat.Hit()
-		processor(buf)
+		if err := processor(buf); err != nil {
+			return fmt.Errorf("record %d: processor error: %w", stat.Cnt, err)
+		}
 	}
-	stat.Finish()
 }
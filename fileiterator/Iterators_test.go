@@ -36,6 +36,35 @@ func TestIterateBinaryRecordsPlain(t *testing.T) {
 	}
 }
 
+func TestIterateBinaryRecordsMagicLookalike(t *testing.T) {
+	// Raw records starting with zlib / gzip magic bytes are still raw records
+	for _, head := range [][]byte{{0x78, 0x9c}, {0x1f, 0x8b}} {
+		testFile := filepath.Join(t.TempDir(), "recs.bin")
+		data := append(append([]byte{}, head...), "23456789ABCDEFGHIJKLMNOPQRST"...)
+		if err := os.WriteFile(testFile, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []byte
+		err := fileiterator.IterateBinaryRecordsE(testFile, 10, func(record []byte) error {
+			got = append(got, record...)
+			return nil
+		})
+		if err != nil || string(got) != string(data) {
+			t.Errorf("% x: got %q, %v", head, got, err)
+		}
+
+		got = nil
+		err = fileiterator.IterateBinaryRecordsWithOptions(testFile, 10, fileiterator.CheckpointOptions{}, func(record []byte) error {
+			got = append(got, record...)
+			return nil
+		})
+		if err != nil || string(got) != string(data) {
+			t.Errorf("% x with options: got %q, %v", head, got, err)
+		}
+	}
+}
+
 func TestIterateBinaryRecordsGzip(t *testing.T) {
 	tmpDir := t.TempDir()
 	testFile := filepath.Join(tmpDir, "test.bin.gz")
@@ -0,0 +1,129 @@
+package fileiterator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// atomicSeq makes temporary file names unique within the process
+var atomicSeq atomic.Int64
+
+// AtomicWriter is returned by Create in atomic mode. Data goes to a temporary file
+// "name.tmp.<pid>.<n>" next to the destination; Close closes the compressor, fsyncs the file
+// and renames it over the destination, Abort removes it. A crashed job leaves no
+// half-written destination behind - at most a stale .tmp file.
+type AtomicWriter struct {
+	io.WriteCloser // compressor writing to file (file itself without compression)
+
+	file     *atomicFile
+	path     string // destination
+	keepTemp bool   // Close leaves the finished temporary file in place (ParquetWriter segments)
+	closed   bool
+}
+
+// atomicFile is the temporary file; Close fsyncs and closes it without renaming
+type atomicFile struct {
+	*os.File
+	closed bool
+}
+
+func (f *atomicFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	err := f.File.Sync()
+	if cerr := f.File.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// createAtomic creates the temporary file of an atomic write to path.
+// Every writer gets its own file, so concurrent atomic writes to the same path
+// do not clobber each other - the last Close wins. Unlike os.CreateTemp, perm
+// (after umask) is used for the file.
+func createAtomic(path string, perm os.FileMode) (*atomicFile, error) {
+	for {
+		tmp := fmt.Sprintf("%s.tmp.%d.%d", path, os.Getpid(), atomicSeq.Add(1))
+		f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if errors.Is(err, os.ErrExist) {
+			continue // left behind by a crashed process with the same pid
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &atomicFile{File: f}, nil
+	}
+}
+
+// TempName returns the temporary file data is written to
+func (w *AtomicWriter) TempName() string {
+	return w.file.Name()
+}
+
+// Close finishes the compressed stream, fsyncs the temporary file and renames it
+// to the destination. On any error the temporary file is removed and the
+// destination is left untouched.
+func (w *AtomicWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	err := w.WriteCloser.Close()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(w.file.Name())
+		return err
+	}
+	if w.keepTemp {
+		return nil
+	}
+	if err := os.Rename(w.file.Name(), w.path); err != nil {
+		os.Remove(w.file.Name())
+		return err
+	}
+	syncDir(filepath.Dir(w.path))
+	return nil
+}
+
+// Abort discards everything written: the temporary file is removed and the
+// destination is left untouched. Abort after Close does nothing.
+func (w *AtomicWriter) Abort() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.WriteCloser.Close()
+	w.file.Close()
+	if err := os.Remove(w.file.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// syncDir makes a rename in dir durable; errors are ignored (not supported everywhere)
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// Abort discards a writer after a failed write. Writers created in atomic mode
+// (AtomicWriter, and MsgPackWriter, FlatBufferListWriter, ParquetWriter and record
+// writers on top of one) remove their temporary file and leave the destination untouched;
+// other writers are just closed.
+func Abort(w io.Closer) error {
+	if a, ok := w.(interface{ Abort() error }); ok {
+		return a.Abort()
+	}
+	return w.Close()
+}
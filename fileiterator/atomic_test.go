@@ -0,0 +1,169 @@
+package fileiterator_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/parf/homebase-go-lib/fileiterator"
+)
+
+// tempFiles returns the leftover temporary files of atomic writes in dir
+func tempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "*.tmp.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestCreateAtomic(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "data.txt.zst")
+	if err := os.WriteFile(testFile, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := fileiterator.Create(testFile, fileiterator.CreateOptions{Atomic: true})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	aw, ok := w.(*fileiterator.AtomicWriter)
+	if !ok {
+		t.Fatalf("Expected *AtomicWriter, got %T", w)
+	}
+	if prefix := fmt.Sprintf("%s.tmp.%d.", testFile, os.Getpid()); !strings.HasPrefix(aw.TempName(), prefix) {
+		t.Errorf("TempName = %s, want %s<n>", aw.TempName(), prefix)
+	}
+	fmt.Fprintln(w, "new content")
+
+	// Destination untouched until Close
+	if data, _ := os.ReadFile(testFile); string(data) != "old" {
+		t.Errorf("Destination changed before Close: %q", data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	data, err := fileiterator.LoadBinFileE(testFile)
+	if err != nil || string(data) != "new content\n" {
+		t.Errorf("After Close: %q, %v", data, err)
+	}
+	if left := tempFiles(t, tmpDir); len(left) != 0 {
+		t.Errorf("Temporary files left: %v", left)
+	}
+
+	// Abort keeps the old destination
+	w, err = fileiterator.Create(testFile, fileiterator.CreateOptions{Atomic: true})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	fmt.Fprintln(w, "partial")
+	if err := fileiterator.Abort(w); err != nil {
+		t.Fatalf("Abort failed: %v", err)
+	}
+	if data, _ := fileiterator.LoadBinFileE(testFile); string(data) != "new content\n" {
+		t.Errorf("Abort changed the destination: %q", data)
+	}
+	if left := tempFiles(t, tmpDir); len(left) != 0 {
+		t.Errorf("Temporary files left after Abort: %v", left)
+	}
+}
+
+func TestCreateAtomicConcurrent(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "data.txt")
+	w1, err := fileiterator.Create(testFile, fileiterator.CreateOptions{Atomic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w2, err := fileiterator.Create(testFile, fileiterator.CreateOptions{Atomic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w1.(*fileiterator.AtomicWriter).TempName() == w2.(*fileiterator.AtomicWriter).TempName() {
+		t.Fatal("Two atomic writers share a temporary file")
+	}
+	fmt.Fprint(w1, "first")
+	fmt.Fprint(w2, "second")
+	if err := w1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w2.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// The last Close wins with a complete file
+	if data, _ := os.ReadFile(testFile); string(data) != "second" {
+		t.Errorf("Destination = %q, want %q", data, "second")
+	}
+}
+
+func TestAtomicWriteOptions(t *testing.T) {
+	tmpDir := t.TempDir()
+	atomic := fileiterator.RecordWriterOptions{Atomic: true}
+
+	// A failed WriteOutput leaves neither a destination nor a temporary file
+	out := filepath.Join(tmpDir, "out.jsonl.zst")
+	err := fileiterator.WriteOutputWithOptions(out, []map[string]any{{"id": 1}, {"bad": func() {}}}, atomic)
+	if err == nil {
+		t.Fatal("Expected encode error")
+	}
+	if _, err := os.Stat(out); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Destination exists after failed write: %v", err)
+	}
+
+	// Successful writes of every kind end up at the destination
+	records := []map[string]any{{"id": int64(1), "name": "a"}, {"id": int64(2), "name": "b"}}
+	for _, name := range []string{"out.csv.gz", "out.parquet", "out.msgpack"} {
+		if err := fileiterator.WriteOutputWithOptions(filepath.Join(tmpDir, name), records, atomic); err != nil {
+			t.Fatalf("WriteOutput(%s) failed: %v", name, err)
+		}
+		got, err := fileiterator.ReadInput(filepath.Join(tmpDir, name))
+		if err != nil || len(got) != 2 {
+			t.Errorf("ReadInput(%s): %d records, %v", name, len(got), err)
+		}
+	}
+	if err := fileiterator.SaveMsgPackWithOptions(filepath.Join(tmpDir, "data.msgpack.zst"), records, fileiterator.CreateOptions{Atomic: true}); err != nil {
+		t.Fatalf("SaveMsgPackWithOptions failed: %v", err)
+	}
+	if err := fileiterator.SaveMsgPackWithOptions(filepath.Join(tmpDir, "bad.msgpack"), func() {}, fileiterator.CreateOptions{Atomic: true}); err == nil {
+		t.Error("Expected encode error")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "bad.msgpack")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Destination exists after failed SaveMsgPack: %v", err)
+	}
+
+	// ParquetWriter.Abort keeps an existing destination
+	dest := filepath.Join(tmpDir, "out.parquet")
+	opts := fileiterator.DefaultParquetWriteOptions()
+	opts.Atomic = true
+	pw, err := fileiterator.CreateParquetWriter(dest, opts)
+	if err != nil {
+		t.Fatalf("CreateParquetWriter failed: %v", err)
+	}
+	pw.Write(map[string]any{"id": int64(3)})
+	pw.Flush()
+	if err := pw.Abort(); err != nil {
+		t.Fatalf("Abort failed: %v", err)
+	}
+	if got, err := fileiterator.ReadInput(dest); err != nil || len(got) != 2 {
+		t.Errorf("Abort changed %s: %d records, %v", dest, len(got), err)
+	}
+
+	// Without the option files are written in place
+	plain := filepath.Join(tmpDir, "plain.jsonl")
+	w, err := fileiterator.CreateRecordWriter(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(plain); err != nil {
+		t.Errorf("Non-atomic writer did not create %s: %v", plain, err)
+	}
+	w.Close()
+
+	if left := tempFiles(t, tmpDir); len(left) != 0 {
+		t.Errorf("Temporary files left: %v", left)
+	}
+}
@@ -0,0 +1,253 @@
+package fileiterator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Position is a resumable point of an iteration: everything before it has been processed.
+type Position struct {
+	Records int64 `json:"records"` // records (lines for JSONL) consumed
+	Offset  int64 `json:"offset"`  // byte offset in the uncompressed stream
+
+	// FrameOffset is the compressed offset decoding restarts from:
+	// Offset for uncompressed files, the start of the frame holding Offset for
+	// seekable zstd, 0 for other codecs (the stream is decoded and skipped up to Offset)
+	FrameOffset int64 `json:"frame_offset"`
+}
+
+// CheckpointOptions configures the checkpointing iterators
+// (IterateJSONLWithOptions, IterateBinaryRecordsWithOptions, ...)
+type CheckpointOptions struct {
+	// Resume starts the iteration after this position; nil - from the start
+	// (or from File, when it exists)
+	Resume *Position
+
+	// Every is the number of records between checkpoints; 0 - only after the last record
+	Every int
+
+	// Checkpoint is called every Every records and after the last record,
+	// once the processor has returned for every record before the position
+	Checkpoint func(Position) error
+
+	// File is a sidecar checkpoint file (usually filename + ".ckpt").
+	// It is read as Resume when Resume is nil and rewritten at every checkpoint.
+	// After a complete run it holds the end position, so appended data is picked up
+	// by the next run; delete it to start over.
+	File string
+}
+
+// SaveCheckpoint atomically writes pos to a sidecar file as JSON
+func SaveCheckpoint(filename string, pos Position) error {
+	data, err := json.Marshal(pos)
+	if err != nil {
+		return err
+	}
+	// Same as an atomic Create: fsync before the rename, so a crash cannot leave an empty checkpoint
+	f, err := createAtomic(filename, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	syncDir(filepath.Dir(filename))
+	return nil
+}
+
+// LoadCheckpoint reads a position saved by SaveCheckpoint.
+// A missing file is reported as ErrNotFound.
+func LoadCheckpoint(filename string) (Position, error) {
+	var pos Position
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return pos, err
+	}
+	if err := json.Unmarshal(data, &pos); err != nil {
+		return pos, fmt.Errorf("%s: invalid checkpoint: %w", filename, err)
+	}
+	return pos, nil
+}
+
+// checkpointer tracks the position of an iteration and fires checkpoints
+type checkpointer struct {
+	opts  CheckpointOptions
+	src   *resumableSource
+	pos   Position
+	since int // records since the last checkpoint
+}
+
+// openCheckpointed opens filename at the resume position of opts
+func openCheckpointed(filename, codec string, opts CheckpointOptions) (*checkpointer, error) {
+	var pos Position
+	switch {
+	case opts.Resume != nil:
+		pos = *opts.Resume
+	case opts.File != "":
+		saved, err := LoadCheckpoint(opts.File)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		pos = saved
+	}
+	if pos.Offset < 0 || pos.Records < 0 {
+		return nil, fmt.Errorf("invalid resume position %+v", pos)
+	}
+
+	src, err := openResumable(filename, codec, pos.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return &checkpointer{opts: opts, src: src, pos: pos}, nil
+}
+
+// advance records one consumed record of n bytes
+func (c *checkpointer) advance(n int) error {
+	c.pos.Records++
+	c.pos.Offset += int64(n)
+	c.since++
+	if c.opts.Every > 0 && c.since >= c.opts.Every {
+		return c.checkpoint()
+	}
+	return nil
+}
+
+// checkpoint reports the current position to the callback and the sidecar file
+func (c *checkpointer) checkpoint() error {
+	c.since = 0
+	c.pos.FrameOffset = c.src.frameOffset(c.pos.Offset)
+	if c.opts.Checkpoint != nil {
+		if err := c.opts.Checkpoint(c.pos); err != nil {
+			return fmt.Errorf("checkpoint: %w", err)
+		}
+	}
+	if c.opts.File != "" {
+		return SaveCheckpoint(c.opts.File, c.pos)
+	}
+	return nil
+}
+
+func (c *checkpointer) Close() error {
+	return c.src.Close()
+}
+
+// resumableSource is a decompressed stream opened at an uncompressed offset
+type resumableSource struct {
+	io.ReadCloser
+	plain  bool            // uncompressed local file: offsets are file offsets
+	frames []seekableFrame // seekable zstd frames, nil otherwise
+}
+
+// openResumable opens a file, URL or "-" and skips to offset of the uncompressed stream.
+// Uncompressed local files seek directly, seekable zstd files start decoding at the frame
+// holding offset; other sources are decoded from the start and discarded up to offset.
+func openResumable(filename, codec string, offset int64) (*resumableSource, error) {
+	workers := OpenOptions{}.workers()
+	if filename == "-" || isURL(filename) {
+		r, err := OpenWithOptions(context.Background(), filename, OpenOptions{Codec: codec})
+		if err != nil {
+			return nil, err
+		}
+		return skipTo(&resumableSource{ReadCloser: r}, offset)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if codec == "" {
+		head := make([]byte, magicLen)
+		n, _ := f.ReadAt(head, 0)
+		codec = chooseCodec(head[:n], filename)
+	}
+
+	start := int64(0) // compressed offset to decode from
+	src := &resumableSource{}
+	switch {
+	case codec == CodecNone:
+		if offset > fi.Size() {
+			f.Close()
+			return nil, fmt.Errorf("resume offset %d past the end of %s (%d bytes)", offset, filename, fi.Size())
+		}
+		start, src.plain = offset, true
+	case codec == CodecZstd && isSeekableZstd(f, fi.Size()):
+		var total int64
+		src.frames, total, err = readSeekTable(f, fi.Size())
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		if offset > total {
+			f.Close()
+			return nil, fmt.Errorf("resume offset %d past the end of %s (%d bytes)", offset, filename, total)
+		}
+		start = src.frameOffset(offset)
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	src.ReadCloser, err = newDecompressor(f, codec, workers)
+	if err != nil {
+		return nil, err // f is closed by newDecompressor
+	}
+	if src.plain {
+		return src, nil
+	}
+	if src.frames != nil {
+		// Decoding starts at the frame holding offset
+		if i := findFrame(src.frames, offset); i < len(src.frames) {
+			offset -= src.frames[i].dataOff
+		} else {
+			offset = 0
+		}
+	}
+	return skipTo(src, offset)
+}
+
+// skipTo discards n decompressed bytes of src
+func skipTo(src *resumableSource, n int64) (*resumableSource, error) {
+	if skipped, err := io.CopyN(io.Discard, src, n); err != nil {
+		src.Close()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("resume: skipped %d of %d bytes: %w", skipped, n, err)
+	}
+	return src, nil
+}
+
+// frameOffset returns the compressed offset decoding restarts from to reach offset
+func (s *resumableSource) frameOffset(offset int64) int64 {
+	switch {
+	case s.plain:
+		return offset
+	case len(s.frames) > 0:
+		i := findFrame(s.frames, offset)
+		if i == len(s.frames) {
+			last := s.frames[i-1]
+			return last.off + last.size
+		}
+		return s.frames[i].off
+	}
+	return 0
+}
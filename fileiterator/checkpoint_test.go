@@ -0,0 +1,198 @@
+package fileiterator_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parf/homebase-go-lib/fileiterator"
+)
+
+func TestIterateJSONLWithOptionsResume(t *testing.T) {
+	for _, ext := range []string{".jsonl", ".jsonl.gz"} {
+		t.Run(ext, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			testFile := filepath.Join(tmpDir, "ids"+ext)
+			ckpt := testFile + ".ckpt"
+			writeJSONLinesEvery(t, testFile, 50, 10, fileiterator.CreateOptions{})
+
+			var checkpoints []fileiterator.Position
+			opts := fileiterator.CheckpointOptions{
+				Every: 7,
+				File:  ckpt,
+				Checkpoint: func(pos fileiterator.Position) error {
+					checkpoints = append(checkpoints, pos)
+					return nil
+				},
+			}
+
+			// First run dies at id 30
+			crash := errors.New("crash")
+			seen := 0
+			err := fileiterator.IterateJSONLWithOptions(testFile, opts, func(obj map[string]any) error {
+				if obj["id"].(float64) == 30 {
+					return crash
+				}
+				seen++
+				return nil
+			})
+			if !errors.Is(err, crash) {
+				t.Fatalf("Expected crash, got %v", err)
+			}
+			if len(checkpoints) == 0 {
+				t.Fatal("Expected checkpoints before the crash")
+			}
+			saved, err := fileiterator.LoadCheckpoint(ckpt)
+			if err != nil {
+				t.Fatalf("LoadCheckpoint failed: %v", err)
+			}
+			if saved != checkpoints[len(checkpoints)-1] {
+				t.Errorf("Sidecar %+v, last checkpoint %+v", saved, checkpoints[len(checkpoints)-1])
+			}
+
+			// Second run resumes from the sidecar and finishes
+			var ids []int
+			err = fileiterator.IterateJSONLTypedWithOptions(testFile, opts, func(rec struct{ ID int }) error {
+				ids = append(ids, rec.ID)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Resumed iteration failed: %v", err)
+			}
+			if len(ids) == 0 || ids[len(ids)-1] != 50 {
+				t.Fatalf("Unexpected resumed ids: %v", ids)
+			}
+			if seen+len(ids) < 50 || ids[0] > 30 {
+				t.Errorf("Records lost: %d before crash, resumed at id %d", seen, ids[0])
+			}
+			for i := 1; i < len(ids); i++ {
+				if ids[i] != ids[i-1]+1 {
+					t.Fatalf("Ids not consecutive: %v", ids)
+				}
+			}
+
+			// A complete run leaves the end position, so a rerun processes nothing
+			end, _ := fileiterator.LoadCheckpoint(ckpt)
+			if end.Records != 55 {
+				t.Errorf("End position %+v, want 55 lines", end)
+			}
+			err = fileiterator.IterateJSONLWithOptions(testFile, opts, func(map[string]any) error {
+				t.Error("Unexpected record after a complete run")
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Rerun failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestIterateJSONLWithOptionsSeekableZstd(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "ids.jsonl.zst")
+	writeJSONLinesEvery(t, testFile, 50000, 10, fileiterator.CreateOptions{Seekable: true})
+
+	var resume *fileiterator.Position
+	err := fileiterator.IterateJSONLWithOptions(testFile, fileiterator.CheckpointOptions{
+		Every: 1000,
+		Checkpoint: func(pos fileiterator.Position) error {
+			if resume == nil && pos.FrameOffset > 0 {
+				resume = &pos
+			}
+			return nil
+		},
+	}, func(map[string]any) error { return nil })
+	if err != nil {
+		t.Fatalf("Iteration failed: %v", err)
+	}
+	if resume == nil {
+		t.Fatal("Expected a checkpoint past the first zstd frame")
+	}
+
+	first, count := 0, 0
+	err = fileiterator.IterateJSONLTypedWithOptions(testFile, fileiterator.CheckpointOptions{Resume: resume}, func(rec struct{ ID int }) error {
+		if first == 0 {
+			first = rec.ID
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Resumed iteration failed: %v", err)
+	}
+	// 11 lines per 10 ids
+	if want := int(resume.Records/11*10+min(resume.Records%11, 10)) + 1; first != want {
+		t.Errorf("Resumed at id %d, want %d (position %+v)", first, want, *resume)
+	}
+	if first+count-1 != 50000 {
+		t.Errorf("Resumed run ended at id %d", first+count-1)
+	}
+}
+
+func TestIterateBinaryRecordsWithOptions(t *testing.T) {
+	for _, ext := range []string{".bin", ".bin.zst"} {
+		t.Run(ext, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			testFile := filepath.Join(tmpDir, "records"+ext)
+			w, err := fileiterator.Create(testFile, fileiterator.CreateOptions{Seekable: true})
+			if err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+			for i := uint32(0); i < 100; i++ {
+				binary.Write(w, binary.LittleEndian, i)
+			}
+			w.Close()
+
+			resume := fileiterator.Position{Records: 40, Offset: 160}
+			next := uint32(40)
+			err = fileiterator.IterateBinaryRecordsWithOptions(testFile, 4, fileiterator.CheckpointOptions{
+				Resume: &resume,
+				Every:  25,
+				Checkpoint: func(pos fileiterator.Position) error {
+					if pos.Offset != pos.Records*4 {
+						t.Errorf("Inconsistent position %+v", pos)
+					}
+					return nil
+				},
+			}, func(rec []byte) error {
+				if v := binary.LittleEndian.Uint32(rec); v != next {
+					t.Fatalf("Record %d, want %d", v, next)
+				}
+				next++
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Iteration failed: %v", err)
+			}
+			if next != 100 {
+				t.Errorf("Stopped at record %d", next)
+			}
+
+			bad := fileiterator.Position{Records: 3, Offset: 10}
+			err = fileiterator.IterateBinaryRecordsWithOptions(testFile, 4, fileiterator.CheckpointOptions{Resume: &bad}, func([]byte) error { return nil })
+			if err == nil {
+				t.Error("Expected error for a position of another record size")
+			}
+		})
+	}
+}
+
+func TestSaveCheckpoint(t *testing.T) {
+	tmpDir := t.TempDir()
+	ckpt := filepath.Join(tmpDir, "data.jsonl.ckpt")
+	for i := int64(1); i <= 3; i++ {
+		if err := fileiterator.SaveCheckpoint(ckpt, fileiterator.Position{Records: i, Offset: i * 10}); err != nil {
+			t.Fatalf("SaveCheckpoint failed: %v", err)
+		}
+	}
+	pos, err := fileiterator.LoadCheckpoint(ckpt)
+	if err != nil || pos.Records != 3 || pos.Offset != 30 {
+		t.Errorf("LoadCheckpoint = %+v, %v", pos, err)
+	}
+	// The temporary files are renamed into place
+	if entries, _ := os.ReadDir(tmpDir); len(entries) != 1 {
+		t.Errorf("Expected only the checkpoint file, got %v", entries)
+	}
+}
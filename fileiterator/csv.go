@@ -1,6 +1,7 @@
 package fileiterator
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"io"
@@ -39,7 +40,10 @@ func DefaultCSVOptions() CSVOptions {
 //	    return nil
 //	})
 func IterateCSV(filename string, opts CSVOptions, processor func([]string) error) error {
-	fi := FUOpen(filename) // Auto-detects compression
+	fi, err := Open(context.Background(), filename) // Auto-detects compression
+	if err != nil {
+		return err
+	}
 	defer fi.Close()
 
 	reader := csv.NewReader(fi)
@@ -96,7 +100,10 @@ func IterateCSV(filename string, opts CSVOptions, processor func([]string) error
 //	    return nil
 //	})
 func IterateCSVMap(filename string, opts CSVOptions, processor func(map[string]string) error) error {
-	fi := FUOpen(filename) // Auto-detects compression
+	fi, err := Open(context.Background(), filename) // Auto-detects compression
+	if err != nil {
+		return err
+	}
 	defer fi.Close()
 
 	reader := csv.NewReader(fi)
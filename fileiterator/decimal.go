@@ -0,0 +1,85 @@
+package fileiterator
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact decimal number: Unscaled * 10^-Scale.
+// WriteParquetAny stores Decimal values as Parquet DECIMAL columns,
+// IterateParquetAny returns DECIMAL columns as Decimal.
+//
+// Example:
+//
+//	price, _ := fileiterator.ParseDecimal("19.99") // Unscaled 1999, Scale 2
+type Decimal struct {
+	Unscaled *big.Int
+	Scale    int32
+}
+
+// ParseDecimal parses a decimal string such as "-123.4500"; trailing zeros keep their scale
+func ParseDecimal(s string) (Decimal, error) {
+	digits := strings.TrimSpace(s)
+	scale := int32(0)
+	if dot := strings.IndexByte(digits, '.'); dot >= 0 {
+		scale = int32(len(digits) - dot - 1)
+		digits = digits[:dot] + digits[dot+1:]
+	}
+	unscaled, ok := new(big.Int).SetString(digits, 10)
+	if !ok || strings.ContainsAny(digits, "eE_") {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	return Decimal{Unscaled: unscaled, Scale: scale}, nil
+}
+
+// String formats d with exactly Scale fractional digits
+func (d Decimal) String() string {
+	if d.Unscaled == nil {
+		return "0"
+	}
+	s := new(big.Int).Abs(d.Unscaled).String()
+	if d.Scale > 0 {
+		if pad := int(d.Scale) + 1 - len(s); pad > 0 {
+			s = strings.Repeat("0", pad) + s
+		}
+		s = s[:len(s)-int(d.Scale)] + "." + s[len(s)-int(d.Scale):]
+	}
+	if d.Unscaled.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// Float64 returns the nearest float64 value of d
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// precision returns the number of digits needed to store d (at least Scale+1)
+func (d Decimal) precision() int32 {
+	n := int32(1)
+	if d.Unscaled != nil && d.Unscaled.Sign() != 0 {
+		n = int32(len(new(big.Int).Abs(d.Unscaled).String()))
+	}
+	return max(n, d.Scale+1)
+}
+
+// rescale returns the unscaled value of d at the given scale, failing if digits would be lost
+func (d Decimal) rescale(scale int32) (*big.Int, error) {
+	v := new(big.Int)
+	if d.Unscaled != nil {
+		v.Set(d.Unscaled)
+	}
+	if scale >= d.Scale {
+		return v.Mul(v, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale-d.Scale)), nil)), nil
+	}
+	div := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.Scale-scale)), nil)
+	q, r := new(big.Int).QuoRem(v, div, new(big.Int))
+	if r.Sign() != 0 {
+		return nil, fmt.Errorf("decimal %s does not fit scale %d", d, scale)
+	}
+	return q, nil
+}
@@ -0,0 +1,45 @@
+package fileiterator
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// ErrNotFound is reported (via errors.Is) when a local file does not exist
+// or a URL returns HTTP 404 / 410.
+// It is fs.ErrNotExist, so plain os errors match it as well.
+var ErrNotFound = fs.ErrNotExist
+
+// ErrChecksum is reported (via errors.Is) when a record does not match its stored checksum
+var ErrChecksum = errors.New("checksum mismatch")
+
+// ErrHTTPStatus is returned when a URL responds with an unexpected HTTP status code
+type ErrHTTPStatus struct {
+	URL  string
+	Code int
+}
+
+func (e *ErrHTTPStatus) Error() string {
+	return fmt.Sprintf("Url: %s - Unexpected HTTP Code %d", e.URL, e.Code)
+}
+
+// Is reports 404 and 410 responses as ErrNotFound
+func (e *ErrHTTPStatus) Is(target error) bool {
+	return target == ErrNotFound && (e.Code == 404 || e.Code == 410)
+}
+
+// ErrCorruptStream is returned when compressed data cannot be decoded:
+// bad header, checksum mismatch or truncated stream
+type ErrCorruptStream struct {
+	Codec string // gzip, zstd, zlib, lz4, brotli, xz
+	Err   error
+}
+
+func (e *ErrCorruptStream) Error() string {
+	return fmt.Sprintf("corrupt %s stream: %v", e.Codec, e.Err)
+}
+
+func (e *ErrCorruptStream) Unwrap() error {
+	return e.Err
+}
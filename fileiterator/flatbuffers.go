@@ -2,7 +2,10 @@ package fileiterator
 
 import (
 	"bufio"
+	"encoding/binary"
 	"fmt"
+	"hash/crc32"
+	"io"
 	"os"
 
 	flatbuffers "github.com/google/flatbuffers/go"
@@ -13,26 +16,45 @@ const bufferSize = 4 * 1024 * 1024 // 4MB buffer for max speed
 // SaveFlatBuffer saves a FlatBuffer to a file with buffered IO
 // Uses 4MB buffer for maximum performance
 func SaveFlatBuffer(filename string, builder *flatbuffers.Builder) error {
-	file, err := os.Create(filename)
+	if err := saveFlatBuffer(filename, builder, CreateOptions{Codec: CodecNone}); err != nil {
+		return err
+	}
+	fmt.Printf("FlatBuffer saved: %s (%d bytes)\n", filename, len(builder.FinishedBytes()))
+	return nil
+}
+
+// SaveFlatBufferWithOptions saves a FlatBuffer to a file created with opts:
+// compression (opts.Codec, by extension if empty) and atomic writes (opts.Atomic)
+func SaveFlatBufferWithOptions(filename string, builder *flatbuffers.Builder, opts CreateOptions) error {
+	if err := saveFlatBuffer(filename, builder, opts); err != nil {
+		return err
+	}
+	fmt.Printf("FlatBuffer saved: %s (%d bytes)\n", filename, len(builder.FinishedBytes()))
+	return nil
+}
+
+func saveFlatBuffer(filename string, builder *flatbuffers.Builder, opts CreateOptions) error {
+	file, err := Create(filename, opts)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
-	defer file.Close()
 
 	// Use buffered writer for max speed
 	writer := bufio.NewWriterSize(file, bufferSize)
-	defer writer.Flush()
 
 	_, err = writer.Write(builder.FinishedBytes())
 	if err != nil {
+		Abort(file)
 		return fmt.Errorf("failed to write buffer: %w", err)
 	}
 
 	if err := writer.Flush(); err != nil {
+		Abort(file)
 		return fmt.Errorf("failed to flush buffer: %w", err)
 	}
-
-	fmt.Printf("FlatBuffer saved: %s (%d bytes)\n", filename, len(builder.FinishedBytes()))
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
 	return nil
 }
 
@@ -70,22 +92,9 @@ func LoadFlatBuffer(filename string) ([]byte, error) {
 // Supports all compression formats via file extension:
 // .gz (gzip), .zst (zstd), .zlib/.zz (zlib), .lz4 (lz4), .br (brotli), .xz (xz)
 func SaveFlatBufferCompressed(filename string, builder *flatbuffers.Builder) error {
-	writer := FUCreate(filename) // Auto-detects compression from extension
-	defer writer.Close()
-
-	// Use buffered writer for max speed
-	bufWriter := bufio.NewWriterSize(writer, bufferSize)
-	defer bufWriter.Flush()
-
-	_, err := bufWriter.Write(builder.FinishedBytes())
-	if err != nil {
-		return fmt.Errorf("failed to write buffer: %w", err)
-	}
-
-	if err := bufWriter.Flush(); err != nil {
-		return fmt.Errorf("failed to flush buffer: %w", err)
+	if err := saveFlatBuffer(filename, builder, CreateOptions{}); err != nil { // Auto-detects compression from extension
+		return err
 	}
-
 	fmt.Printf("FlatBuffer saved (compressed): %s (%d bytes)\n", filename, len(builder.FinishedBytes()))
 	return nil
 }
@@ -123,8 +132,12 @@ func LoadFlatBufferCompressed(filename string) ([]byte, error) {
 // IterateFlatBufferList iterates over a FlatBuffer file containing multiple records
 // Each record should be prefixed with a 4-byte length (uint32, little-endian)
 // Supports compressed files via FUOpen auto-detection (.fb, .fb.gz, .fb.zst, .fb.lz4, etc.)
+// For random access by record number use FlatBufferListReader.
 //
 // File format: [length1:uint32][record1:bytes][length2:uint32][record2:bytes]...
+// followed by an optional offset index (see FlatBufferListWriter), which is skipped.
+// Records written with FlatBufferListOptions.CRC are verified; mismatches return ErrChecksum.
+// A list written with Index or CRC that ends before its end marker returns io.ErrUnexpectedEOF.
 func IterateFlatBufferList(filename string, processor func([]byte) error) error {
 	reader := FUOpen(filename) // Auto-detects compression
 	defer reader.Close()
@@ -134,28 +147,46 @@ func IterateFlatBufferList(filename string, processor func([]byte) error) error
 
 	count := 0
 	lengthBuf := make([]byte, 4)
+	framed := false // list header seen, the end marker is required
 
 	for {
 		// Read length prefix (4 bytes, little-endian uint32)
-		_, err := bufReader.Read(lengthBuf)
+		_, err := io.ReadFull(bufReader, lengthBuf)
 		if err != nil {
-			if err.Error() == "EOF" {
+			if err == io.EOF {
+				if framed {
+					return fmt.Errorf("record %d: missing end marker: %w", count+1, io.ErrUnexpectedEOF)
+				}
 				break
 			}
 			return fmt.Errorf("record %d: failed to read length: %w", count+1, err)
 		}
 
-		// Decode length (little-endian)
-		length := uint32(lengthBuf[0]) | uint32(lengthBuf[1])<<8 | uint32(lengthBuf[2])<<16 | uint32(lengthBuf[3])<<24
+		word := binary.LittleEndian.Uint32(lengthBuf)
+		if word == flatBufferIndexMarker {
+			// Offset index follows the last record
+			break
+		}
+		if word == flatBufferHeader && count == 0 && !framed {
+			framed = true
+			continue
+		}
+		length, hasCRC := flatBufferLength(lengthBuf)
+		var sum uint32
+		if hasCRC {
+			if _, err := io.ReadFull(bufReader, lengthBuf); err != nil {
+				return fmt.Errorf("record %d: failed to read checksum: %w", count+1, err)
+			}
+			sum = binary.LittleEndian.Uint32(lengthBuf)
+		}
 
 		// Read record data
-		recordData := make([]byte, length)
-		n, err := bufReader.Read(recordData)
+		recordData, err := readFlatBufferRecord(bufReader, int(length))
 		if err != nil {
 			return fmt.Errorf("record %d: failed to read data: %w", count+1, err)
 		}
-		if uint32(n) != length {
-			return fmt.Errorf("record %d: incomplete read: got %d bytes, expected %d", count+1, n, length)
+		if hasCRC && crc32.Checksum(recordData, crc32c) != sum {
+			return fmt.Errorf("record %d: %w", count+1, ErrChecksum)
 		}
 
 		count++
@@ -168,60 +199,50 @@ func IterateFlatBufferList(filename string, processor func([]byte) error) error
 	return nil
 }
 
+// readFlatBufferRecord reads a record of length bytes. Large records are read
+// in chunks, so a garbage length in a corrupted file fails with
+// io.ErrUnexpectedEOF instead of allocating up to 2GB.
+func readFlatBufferRecord(r io.Reader, length int) ([]byte, error) {
+	if length <= bufferSize {
+		data := make([]byte, length)
+		_, err := io.ReadFull(r, data)
+		return data, err
+	}
+	data, err := io.ReadAll(io.LimitReader(r, int64(length)))
+	if err == nil && len(data) < length {
+		err = io.ErrUnexpectedEOF
+	}
+	return data, err
+}
+
 // SaveFlatBufferList saves multiple FlatBuffer records to a file with length prefixes
 // Each record is prefixed with a 4-byte length (uint32, little-endian)
 // Supports compression via file extension (.fb.gz, .fb.zst, .fb.lz4, etc.)
+// .zst files are seekable zstd, so FlatBufferListReader can open them.
+// Use FlatBufferListWriter to write records one at a time, with an offset index or with checksums.
 func SaveFlatBufferList(filename string, records [][]byte) error {
-	var writer interface {
-		Write([]byte) (int, error)
-		Close() error
-	}
+	return SaveFlatBufferListWithOptions(filename, records, FlatBufferListOptions{})
+}
 
-	// Determine if compression is needed based on extension
-	if filename[len(filename)-3:] == ".fb" {
-		// Plain file
-		file, err := os.Create(filename)
-		if err != nil {
-			return fmt.Errorf("failed to create file: %w", err)
-		}
-		writer = file
-	} else {
-		// Compressed file
-		writer = FUCreate(filename)
+// SaveFlatBufferListWithOptions is SaveFlatBufferList with an offset index, checksums
+// or atomic writes (see FlatBufferListOptions)
+func SaveFlatBufferListWithOptions(filename string, records [][]byte, opts FlatBufferListOptions) error {
+	writer, err := CreateFlatBufferListWriter(filename, opts)
+	if err != nil {
+		return err
 	}
-	defer writer.Close()
-
-	// Use buffered writer for max speed
-	bufWriter := bufio.NewWriterSize(writer, bufferSize)
-	defer bufWriter.Flush()
 
-	lengthBuf := make([]byte, 4)
 	totalBytes := 0
-
 	for i, record := range records {
-		// Write length prefix (little-endian uint32)
-		length := uint32(len(record))
-		lengthBuf[0] = byte(length)
-		lengthBuf[1] = byte(length >> 8)
-		lengthBuf[2] = byte(length >> 16)
-		lengthBuf[3] = byte(length >> 24)
-
-		_, err := bufWriter.Write(lengthBuf)
-		if err != nil {
-			return fmt.Errorf("record %d: failed to write length: %w", i+1, err)
+		if err := writer.Write(record); err != nil {
+			writer.Abort()
+			return fmt.Errorf("record %d: %w", i+1, err)
 		}
-
-		// Write record data
-		_, err = bufWriter.Write(record)
-		if err != nil {
-			return fmt.Errorf("record %d: failed to write data: %w", i+1, err)
-		}
-
 		totalBytes += 4 + len(record)
 	}
 
-	if err := bufWriter.Flush(); err != nil {
-		return fmt.Errorf("failed to flush buffer: %w", err)
+	if err := writer.Close(); err != nil {
+		return err
 	}
 
 	fmt.Printf("FlatBuffer list saved: %s (%d records, %d bytes)\n", filename, len(records), totalBytes)
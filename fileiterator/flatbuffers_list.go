@@ -0,0 +1,446 @@
+package fileiterator
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"slices"
+	"sync"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// FlatBuffer list files written with FlatBufferListOptions.Index end with an offset index
+// after the last record:
+//
+//	[0xFFFFFFFF][offset1:uint64]...[offsetN:uint64][N:uint64]["FBLINDEX"]
+//
+// The 0xFFFFFFFF length prefix ends the records for sequential readers;
+// offsets point to the length prefix of each record. Readers older than the index
+// take the marker for a record length and fail, so it is opt-in.
+// A length prefix with the high bit set is followed by the CRC-32C of the record:
+//
+//	[length|0x80000000:uint32][crc:uint32][record:bytes]
+//
+// Lists written with Index or CRC start with a 0xFFFFFFFE header and always end with
+// the 0xFFFFFFFF marker, so readers detect a list truncated between records.
+const (
+	flatBufferHeader      = 0xFFFFFFFE
+	flatBufferIndexMarker = 0xFFFFFFFF
+	flatBufferIndexMagic  = "FBLINDEX"
+	flatBufferFooterSize  = 16 // record count, magic
+	flatBufferCRCFlag     = 1 << 31
+	maxFlatBufferRecord   = flatBufferCRCFlag - 2 // FlatBuffers are limited to 2GB; the header is not a length
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// FlatBufferListOptions controls how FlatBuffer lists are written
+type FlatBufferListOptions struct {
+	// Index appends an offset index, so FlatBufferListReader opens the list without
+	// scanning it. Indexed lists cannot be read by versions without FlatBufferListReader.
+	Index bool
+	// CRC stores a CRC-32C checksum with every record. Readers verify it and
+	// report ErrChecksum for corrupted records instead of returning garbage.
+	CRC bool
+	// Atomic writes a temporary file that is renamed into place on success (see CreateOptions.Atomic)
+	Atomic bool
+}
+
+// flatBufferBuilders recycles builders for FlatBufferListWriter.Builder
+var flatBufferBuilders = sync.Pool{
+	New: func() any { return flatbuffers.NewBuilder(1024) },
+}
+
+// FlatBufferListWriter writes length-prefixed FlatBuffer records one at a time.
+// With FlatBufferListOptions.Index it appends the offset index on Close, so
+// FlatBufferListReader opens the list without scanning it; record offsets are
+// then kept in memory (8 bytes per record).
+//
+// Example:
+//
+//	w, err := fileiterator.CreateFlatBufferListWriter("events.fb.zst", fileiterator.FlatBufferListOptions{Index: true, CRC: true})
+//	if err != nil {
+//	    return err
+//	}
+//	for _, event := range events {
+//	    b := w.Builder()
+//	    b.Finish(buildEvent(b, event))
+//	    if err := w.Append(b); err != nil {
+//	        w.Close()
+//	        return err
+//	    }
+//	}
+//	return w.Close()
+type FlatBufferListWriter struct {
+	opts    FlatBufferListOptions
+	w       *bufio.Writer
+	out     io.Closer // closed by Close, nil for NewFlatBufferListWriter
+	offsets []byte    // with Index
+	off     int64
+	n       int
+	err     error
+	closed  bool
+}
+
+// CreateFlatBufferListWriter creates filename for writing a FlatBuffer list.
+// Compression is selected by extension, same as FUCreate. .zst files are
+// written as seekable zstd (independent 1MB frames plus a seek table), so they
+// stay randomly accessible; other compressions (.gz, .lz4, ...) can only be read sequentially.
+func CreateFlatBufferListWriter(filename string, opts FlatBufferListOptions) (*FlatBufferListWriter, error) {
+	out, err := Create(filename, CreateOptions{Seekable: true, Atomic: opts.Atomic})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	w := NewFlatBufferListWriter(out, opts)
+	w.out = out
+	return w, nil
+}
+
+// NewFlatBufferListWriter writes a FlatBuffer list to w; Close does not close w
+func NewFlatBufferListWriter(w io.Writer, opts FlatBufferListOptions) *FlatBufferListWriter {
+	fw := &FlatBufferListWriter{opts: opts, w: bufio.NewWriterSize(w, bufferSize)}
+	if opts.Index || opts.CRC {
+		// buffered - cannot fail
+		fw.w.Write(binary.LittleEndian.AppendUint32(nil, flatBufferHeader))
+		fw.off = 4
+	}
+	return fw
+}
+
+// Builder returns an empty builder from a shared pool; pass it to Append when finished
+func (w *FlatBufferListWriter) Builder() *flatbuffers.Builder {
+	b := flatBufferBuilders.Get().(*flatbuffers.Builder)
+	b.Reset()
+	return b
+}
+
+// Append writes the finished bytes of b and returns b to the pool used by Builder.
+// b must not be used afterwards.
+func (w *FlatBufferListWriter) Append(b *flatbuffers.Builder) error {
+	err := w.Write(b.FinishedBytes())
+	flatBufferBuilders.Put(b)
+	return err
+}
+
+// Write appends one record
+func (w *FlatBufferListWriter) Write(record []byte) error {
+	if w.closed {
+		return errors.New("write to closed FlatBuffer list")
+	}
+	if w.err != nil {
+		return w.err
+	}
+	if len(record) >= maxFlatBufferRecord {
+		return fmt.Errorf("record too large: %d bytes", len(record))
+	}
+
+	var head [8]byte
+	prefix := head[:4]
+	length := uint32(len(record))
+	if w.opts.CRC {
+		length |= flatBufferCRCFlag
+		binary.LittleEndian.PutUint32(head[4:], crc32.Checksum(record, crc32c))
+		prefix = head[:]
+	}
+	binary.LittleEndian.PutUint32(head[:], length)
+	if _, err := w.w.Write(prefix); err != nil {
+		w.err = fmt.Errorf("failed to write length: %w", err)
+		return w.err
+	}
+	if _, err := w.w.Write(record); err != nil {
+		w.err = fmt.Errorf("failed to write data: %w", err)
+		return w.err
+	}
+	if w.opts.Index {
+		w.offsets = binary.LittleEndian.AppendUint64(w.offsets, uint64(w.off))
+	}
+	w.off += int64(len(prefix) + len(record))
+	w.n++
+	return nil
+}
+
+// Len returns the number of records written
+func (w *FlatBufferListWriter) Len() int {
+	return w.n
+}
+
+// Close writes the offset index (with Index) and closes the file
+func (w *FlatBufferListWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	err := w.err
+	if err == nil {
+		err = w.writeIndex()
+	}
+	if w.out == nil {
+		return err
+	}
+	if err != nil {
+		Abort(w.out)
+		return err
+	}
+	return w.out.Close()
+}
+
+// Abort closes the writer without writing the index; in atomic mode
+// (see CreateOptions.Atomic) the destination is left untouched
+func (w *FlatBufferListWriter) Abort() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.out != nil {
+		return Abort(w.out)
+	}
+	return nil
+}
+
+func (w *FlatBufferListWriter) writeIndex() error {
+	if w.opts.Index || w.opts.CRC {
+		footer := binary.LittleEndian.AppendUint32(nil, flatBufferIndexMarker)
+		if _, err := w.w.Write(footer); err != nil {
+			return fmt.Errorf("failed to write index: %w", err)
+		}
+	}
+	if w.opts.Index {
+		if _, err := w.w.Write(w.offsets); err != nil {
+			return fmt.Errorf("failed to write index: %w", err)
+		}
+		footer := binary.LittleEndian.AppendUint64(nil, uint64(w.Len()))
+		footer = append(footer, flatBufferIndexMagic...)
+		if _, err := w.w.Write(footer); err != nil {
+			return fmt.Errorf("failed to write index: %w", err)
+		}
+	}
+	if err := w.w.Flush(); err != nil {
+		return fmt.Errorf("failed to flush buffer: %w", err)
+	}
+	return nil
+}
+
+// flatBufferSource is random access to the (decompressed) bytes of a list file
+type flatBufferSource interface {
+	// readAt returns n bytes at off, valid at least until the next call
+	readAt(off, n int64) ([]byte, error)
+	size() int64
+	Close() error
+}
+
+// mmapSource reads uncompressed files through a memory mapping
+type mmapSource struct {
+	*MmapFile
+}
+
+func (m mmapSource) readAt(off, n int64) ([]byte, error) { return m.at(off, n) }
+func (m mmapSource) size() int64                         { return m.Size() }
+
+// FlatBufferListReader gives random access to the records of a FlatBuffer list
+// file: uncompressed files are memory-mapped, .zst files must be seekable zstd
+// (as written by CreateFlatBufferListWriter and SaveFlatBufferList).
+// Lists without an offset index (see FlatBufferListOptions.Index) are scanned once on open.
+//
+// Example:
+//
+//	r, err := fileiterator.OpenFlatBufferListReader("events.fb")
+//	if err != nil {
+//	    return err
+//	}
+//	defer r.Close()
+//	rec, err := r.At(9_000_000)
+type FlatBufferListReader struct {
+	src   flatBufferSource
+	index []byte // 8-byte record offsets
+}
+
+// OpenFlatBufferListReader opens a FlatBuffer list file for random access
+func OpenFlatBufferListReader(filename string) (*FlatBufferListReader, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if fi.Size() == 0 {
+		f.Close()
+		return &FlatBufferListReader{}, nil
+	}
+
+	var src flatBufferSource
+	switch {
+	case isSeekableZstd(f, fi.Size()):
+		zr, err := openSeekableZstd(f, fi.Size())
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		src = zr
+	case codecFromSuffix(filename) != CodecNone:
+		f.Close()
+		return nil, fmt.Errorf("%s: random access needs an uncompressed or seekable zstd file", filename)
+	default:
+		f.Close()
+		m, err := MmapOpenWithOptions(filename, MmapOptions{Advice: MmapRandom})
+		if err != nil {
+			return nil, err
+		}
+		src = mmapSource{m}
+	}
+
+	r := &FlatBufferListReader{src: src}
+	if err := r.loadIndex(); err != nil {
+		src.Close()
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return r, nil
+}
+
+// loadIndex reads the offset index, or builds it by scanning files written without one
+func (r *FlatBufferListReader) loadIndex() error {
+	size := r.src.size()
+	if size >= 4+flatBufferFooterSize {
+		footer, err := r.src.readAt(size-flatBufferFooterSize, flatBufferFooterSize)
+		if err != nil {
+			return err
+		}
+		if string(footer[8:]) == flatBufferIndexMagic {
+			n := binary.LittleEndian.Uint64(footer)
+			if n > uint64(size-4-flatBufferFooterSize)/8 {
+				return errors.New("corrupt FlatBuffer list index")
+			}
+			start := size - flatBufferFooterSize - int64(n)*8
+			marker, err := r.src.readAt(start-4, 4)
+			if err != nil {
+				return err
+			}
+			if binary.LittleEndian.Uint32(marker) != flatBufferIndexMarker {
+				return errors.New("corrupt FlatBuffer list index")
+			}
+			index, err := r.src.readAt(start, int64(n)*8)
+			if err != nil {
+				return err
+			}
+			if _, ok := r.src.(mmapSource); !ok {
+				index = slices.Clone(index)
+			}
+			r.index = index
+			return nil
+		}
+	}
+
+	var index []byte
+	off := int64(0)
+	framed, ended := false, false // list header seen, end marker seen
+	for off < size {
+		head, err := r.src.readAt(off, min(4, size-off))
+		if err != nil {
+			return err
+		}
+		if len(head) < 4 {
+			return fmt.Errorf("record %d: truncated length: %w", len(index)/8+1, io.ErrUnexpectedEOF)
+		}
+		word := binary.LittleEndian.Uint32(head)
+		if word == flatBufferIndexMarker {
+			ended = true
+			break
+		}
+		if word == flatBufferHeader && off == 0 {
+			off, framed = 4, true
+			continue
+		}
+		length, hasCRC := flatBufferLength(head)
+		next := off + 4 + int64(length)
+		if hasCRC {
+			next += 4
+		}
+		if next > size {
+			return fmt.Errorf("record %d: truncated data: %w", len(index)/8+1, io.ErrUnexpectedEOF)
+		}
+		index = binary.LittleEndian.AppendUint64(index, uint64(off))
+		off = next
+	}
+	if framed && !ended {
+		return fmt.Errorf("record %d: missing end marker: %w", len(index)/8+1, io.ErrUnexpectedEOF)
+	}
+	r.index = index
+	return nil
+}
+
+// Len returns the number of records
+func (r *FlatBufferListReader) Len() int {
+	return len(r.index) / 8
+}
+
+// At returns record i (0-based). For uncompressed files the record is a
+// read-only slice of the mapping, valid until Close; for zstd files it is
+// valid until the next At call. Copy it to keep it.
+func (r *FlatBufferListReader) At(i int) ([]byte, error) {
+	if i < 0 || i >= r.Len() {
+		return nil, fmt.Errorf("record %d out of range [0, %d)", i, r.Len())
+	}
+	off := int64(binary.LittleEndian.Uint64(r.index[i*8:]))
+	head, err := r.src.readAt(off, 4)
+	if err != nil {
+		return nil, fmt.Errorf("record %d: failed to read length: %w", i, err)
+	}
+	length, hasCRC := flatBufferLength(head)
+	var sum uint32
+	if hasCRC {
+		if head, err = r.src.readAt(off+4, 4); err != nil {
+			return nil, fmt.Errorf("record %d: failed to read checksum: %w", i, err)
+		}
+		sum = binary.LittleEndian.Uint32(head)
+		off += 4
+	}
+	rec, err := r.src.readAt(off+4, int64(length))
+	if err != nil {
+		return nil, fmt.Errorf("record %d: failed to read data: %w", i, err)
+	}
+	if hasCRC && crc32.Checksum(rec, crc32c) != sum {
+		return nil, fmt.Errorf("record %d: %w", i, ErrChecksum)
+	}
+	return rec, nil
+}
+
+// Range calls processor for records start..end-1 in order; record slices follow the rules of At
+func (r *FlatBufferListReader) Range(start, end int, processor func(i int, record []byte) error) error {
+	if start < 0 || end > r.Len() || start > end {
+		return fmt.Errorf("range [%d, %d) out of range [0, %d)", start, end, r.Len())
+	}
+	for i := start; i < end; i++ {
+		rec, err := r.At(i)
+		if err != nil {
+			return err
+		}
+		if err := processor(i, rec); err != nil {
+			return fmt.Errorf("record %d: processor error: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Close releases the file
+func (r *FlatBufferListReader) Close() error {
+	if r.src == nil {
+		return nil
+	}
+	err := r.src.Close()
+	r.src, r.index = nil, nil
+	return err
+}
+
+// flatBufferLength decodes a record length prefix
+func flatBufferLength(prefix []byte) (length uint32, hasCRC bool) {
+	v := binary.LittleEndian.Uint32(prefix)
+	return v &^ flatBufferCRCFlag, v&flatBufferCRCFlag != 0
+}
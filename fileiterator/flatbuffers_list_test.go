@@ -2,7 +2,13 @@ package fileiterator_test
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	flatbuffers "github.com/google/flatbuffers/go"
@@ -177,3 +183,274 @@ func TestIterateFlatBufferListLZ4(t *testing.T) {
 
 	t.Logf("Successfully saved and loaded 100 FlatBuffer records with LZ4 compression")
 }
+
+func listRecord(i int) []byte {
+	return []byte(fmt.Sprintf("record-%d-%s", i, strings.Repeat("x", i%50)))
+}
+
+func writeList(t *testing.T, path string, n int) {
+	t.Helper()
+	w, err := fileiterator.CreateFlatBufferListWriter(path, fileiterator.FlatBufferListOptions{Index: true})
+	if err != nil {
+		t.Fatalf("CreateFlatBufferListWriter failed: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		if err := w.Write(listRecord(i)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if w.Len() != n {
+		t.Errorf("Expected %d records written, got %d", n, w.Len())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestFlatBufferListReader(t *testing.T) {
+	tmpDir := t.TempDir()
+	// 100K records of ~40 bytes: several 1MB zstd frames, records cross frame boundaries
+	for _, name := range []string{"list.fb", "list.fb.zst", "list.fb.zst1"} {
+		t.Run(name, func(t *testing.T) {
+			testFile := filepath.Join(tmpDir, name)
+			writeList(t, testFile, 100000)
+
+			r, err := fileiterator.OpenFlatBufferListReader(testFile)
+			if err != nil {
+				t.Fatalf("OpenFlatBufferListReader failed: %v", err)
+			}
+			defer r.Close()
+
+			if r.Len() != 100000 {
+				t.Fatalf("Expected 100000 records, got %d", r.Len())
+			}
+			for _, i := range []int{99999, 0, 54321, 1, 26000, 99998} {
+				rec, err := r.At(i)
+				if err != nil || !bytes.Equal(rec, listRecord(i)) {
+					t.Errorf("At(%d) = %q, %v", i, rec, err)
+				}
+			}
+			if _, err := r.At(100000); err == nil {
+				t.Error("Expected out of range error")
+			}
+
+			next := 500
+			err = r.Range(500, 1500, func(i int, rec []byte) error {
+				if i != next || !bytes.Equal(rec, listRecord(i)) {
+					t.Fatalf("Range: unexpected record %d %q", i, rec)
+				}
+				next++
+				return nil
+			})
+			if err != nil || next != 1500 {
+				t.Errorf("Range stopped at %d: %v", next, err)
+			}
+
+			// Sequential readers skip the index
+			count := 0
+			err = fileiterator.IterateFlatBufferList(testFile, func(rec []byte) error {
+				if !bytes.Equal(rec, listRecord(count)) {
+					t.Fatalf("Unexpected record %d %q", count, rec)
+				}
+				count++
+				return nil
+			})
+			if err != nil || count != 100000 {
+				t.Errorf("IterateFlatBufferList: %d records, %v", count, err)
+			}
+		})
+	}
+}
+
+func TestFlatBufferListReaderWithoutIndex(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "legacy.fb")
+
+	// Written by older versions: length-prefixed records only
+	var data []byte
+	for i := 0; i < 1000; i++ {
+		rec := listRecord(i)
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(rec)))
+		data = append(data, rec...)
+	}
+	os.WriteFile(testFile, data, 0644)
+
+	r, err := fileiterator.OpenFlatBufferListReader(testFile)
+	if err != nil {
+		t.Fatalf("OpenFlatBufferListReader failed: %v", err)
+	}
+	defer r.Close()
+	if rec, err := r.At(999); r.Len() != 1000 || err != nil || !bytes.Equal(rec, listRecord(999)) {
+		t.Errorf("Unexpected last record %q, %v (%d records)", rec, err, r.Len())
+	}
+
+	// Truncated record
+	os.WriteFile(testFile, data[:len(data)-3], 0644)
+	if _, err := fileiterator.OpenFlatBufferListReader(testFile); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	err = fileiterator.IterateFlatBufferList(testFile, func([]byte) error { return nil })
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("IterateFlatBufferList: expected io.ErrUnexpectedEOF, got %v", err)
+	}
+
+	// Random access is not possible in stream-compressed files
+	gzFile := filepath.Join(tmpDir, "list.fb.gz")
+	writeList(t, gzFile, 10)
+	if _, err := fileiterator.OpenFlatBufferListReader(gzFile); err == nil {
+		t.Error("Expected error for gzip file")
+	}
+}
+
+func TestFlatBufferListWriterStream(t *testing.T) {
+	var buf bytes.Buffer
+	w := fileiterator.NewFlatBufferListWriter(&buf, fileiterator.FlatBufferListOptions{})
+	w.Write([]byte("a"))
+	w.Write([]byte{})
+	w.Write([]byte("ccc"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := w.Write([]byte("d")); err == nil {
+		t.Error("Expected error writing to closed writer")
+	}
+
+	testFile := filepath.Join(t.TempDir(), "stream.fb")
+	os.WriteFile(testFile, buf.Bytes(), 0644)
+	r, err := fileiterator.OpenFlatBufferListReader(testFile)
+	if err != nil {
+		t.Fatalf("OpenFlatBufferListReader failed: %v", err)
+	}
+	defer r.Close()
+	var got []string
+	r.Range(0, r.Len(), func(i int, rec []byte) error {
+		got = append(got, string(rec))
+		return nil
+	})
+	if strings.Join(got, ",") != "a,,ccc" {
+		t.Errorf("Unexpected records %q", got)
+	}
+
+	// Without Index the list is plain length-prefixed records, readable by older versions
+	if want := "\x01\x00\x00\x00a\x00\x00\x00\x00\x03\x00\x00\x00ccc"; buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.fb.zst")
+	writeList(t, empty, 0)
+	r, err = fileiterator.OpenFlatBufferListReader(empty)
+	if err != nil || r.Len() != 0 {
+		t.Fatalf("Empty list: %v", err)
+	}
+	r.Close()
+}
+
+func TestFlatBufferListWriterBuildersAndCRC(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts := fileiterator.FlatBufferListOptions{CRC: true}
+	for _, name := range []string{"crc.fb", "crc.fb.zst", "crc.fb.gz"} {
+		testFile := filepath.Join(tmpDir, name)
+		w, err := fileiterator.CreateFlatBufferListWriter(testFile, opts)
+		if err != nil {
+			t.Fatalf("%s: CreateFlatBufferListWriter failed: %v", name, err)
+		}
+		for i := 0; i < 1000; i++ {
+			b := w.Builder()
+			b.Finish(b.CreateByteVector(listRecord(i)))
+			if err := w.Append(b); err != nil {
+				t.Fatalf("%s: Append failed: %v", name, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("%s: Close failed: %v", name, err)
+		}
+
+		count := 0
+		err = fileiterator.IterateFlatBufferList(testFile, func(data []byte) error {
+			if !bytes.Contains(data, listRecord(count)) {
+				t.Fatalf("%s: unexpected record %d", name, count)
+			}
+			count++
+			return nil
+		})
+		if err != nil || count != 1000 {
+			t.Errorf("%s: IterateFlatBufferList: %d records, %v", name, count, err)
+		}
+
+		if name == "crc.fb.gz" {
+			continue
+		}
+		r, err := fileiterator.OpenFlatBufferListReader(testFile)
+		if err != nil {
+			t.Fatalf("%s: OpenFlatBufferListReader failed: %v", name, err)
+		}
+		if rec, err := r.At(777); err != nil || !bytes.Contains(rec, listRecord(777)) {
+			t.Errorf("%s: At(777) = %q, %v", name, rec, err)
+		}
+		r.Close()
+	}
+}
+
+func TestFlatBufferListCorruption(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "corrupt.fb")
+	w, _ := fileiterator.CreateFlatBufferListWriter(testFile, fileiterator.FlatBufferListOptions{CRC: true})
+	for i := 0; i < 10; i++ {
+		w.Write(listRecord(i))
+	}
+	w.Close()
+
+	data, _ := os.ReadFile(testFile)
+	data[12+2] ^= 0xFF // inside record 0, after header, length and checksum
+	os.WriteFile(testFile, data, 0644)
+
+	err := fileiterator.IterateFlatBufferList(testFile, func([]byte) error { return nil })
+	if !errors.Is(err, fileiterator.ErrChecksum) {
+		t.Errorf("IterateFlatBufferList: expected ErrChecksum, got %v", err)
+	}
+	r, err := fileiterator.OpenFlatBufferListReader(testFile)
+	if err != nil {
+		t.Fatalf("OpenFlatBufferListReader failed: %v", err)
+	}
+	defer r.Close()
+	if _, err := r.At(0); !errors.Is(err, fileiterator.ErrChecksum) {
+		t.Errorf("At: expected ErrChecksum, got %v", err)
+	}
+	if _, err := r.At(1); err != nil {
+		t.Errorf("At(1) failed: %v", err)
+	}
+
+	// Truncated between records: the end marker is missing
+	w, _ = fileiterator.CreateFlatBufferListWriter(testFile, fileiterator.FlatBufferListOptions{CRC: true})
+	for i := 0; i < 10; i++ {
+		w.Write(listRecord(i))
+	}
+	w.Close()
+	data, _ = os.ReadFile(testFile)
+	os.WriteFile(testFile, data[:len(data)-4], 0644) // without the marker
+	count := 0
+	err = fileiterator.IterateFlatBufferList(testFile, func([]byte) error { count++; return nil })
+	if !errors.Is(err, io.ErrUnexpectedEOF) || count != 10 {
+		t.Errorf("IterateFlatBufferList: expected io.ErrUnexpectedEOF after 10 records, got %v after %d", err, count)
+	}
+	if _, err := fileiterator.OpenFlatBufferListReader(testFile); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("OpenFlatBufferListReader: expected io.ErrUnexpectedEOF, got %v", err)
+	}
+
+	indexed := filepath.Join(tmpDir, "indexed.fb")
+	writeList(t, indexed, 10)
+	data, _ = os.ReadFile(indexed)
+	os.WriteFile(indexed, data[:len(data)-(4+10*8+16)], 0644) // without marker and index
+	err = fileiterator.IterateFlatBufferList(indexed, func([]byte) error { return nil })
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Indexed list: expected io.ErrUnexpectedEOF, got %v", err)
+	}
+
+	// A garbage length fails without allocating it
+	garbage := filepath.Join(tmpDir, "garbage.fb")
+	os.WriteFile(garbage, binary.LittleEndian.AppendUint32(nil, 0x7FFFFF00), 0644)
+	err = fileiterator.IterateFlatBufferList(garbage, func([]byte) error { return nil })
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
@@ -2,99 +2,38 @@ package fileiterator
 
 import (
 	"database/sql"
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"io"
-	"path/filepath"
 	"strconv"
-	"strings"
 
-	_ "github.com/lib/pq" // PostgreSQL driver
-	msgpack "github.com/vmihailenco/msgpack/v5"
-	hbsql "github.com/parf/homebase-go-lib/sql"
+	_ "github.com/go-sql-driver/mysql" // MySQL driver
+	_ "github.com/lib/pq"              // PostgreSQL driver
 )
 
-// ReadInput reads any supported format and returns generic records
+// ReadInput reads any supported format and returns generic records.
+// filename may be a glob pattern or a directory (see OpenRecordReader).
+// It loads the whole file into memory - use OpenRecordReader to stream large files.
 func ReadInput(filename string) ([]map[string]any, error) {
-	ext := strings.ToLower(filepath.Ext(filename))
-	// Remove compression extension if present
-	if ext == ".gz" || ext == ".zst" || ext == ".lz4" || ext == ".br" || ext == ".xz" {
-		base := strings.TrimSuffix(filename, ext)
-		ext = strings.ToLower(filepath.Ext(base))
-	}
-
-	switch ext {
-	case ".jsonl", ".ndjson":
-		return readJSONL(filename)
-	case ".msgpack", ".mp":
-		return readMsgPack(filename)
-	case ".csv":
-		return readCSV(filename)
-	case ".parquet", ".pk":
-		return readParquetGeneric(filename)
-	default:
-		return nil, fmt.Errorf("unsupported input format: %s (supported: .jsonl, .csv, .msgpack, .parquet/.pk)", ext)
-	}
-}
-
-func readJSONL(filename string) ([]map[string]any, error) {
-	var records []map[string]any
-	err := IterateJSONL(filename, func(record map[string]any) error {
-		records = append(records, record)
-		return nil
-	})
-	return records, err
-}
-
-func readMsgPack(filename string) ([]map[string]any, error) {
-	var records []map[string]any
-	err := IterateMsgPack(filename, func(data any) error {
-		if m, ok := data.(map[string]any); ok {
-			records = append(records, m)
-		}
-		return nil
-	})
-	return records, err
-}
-
-func readCSV(filename string) ([]map[string]any, error) {
-	r := FUOpen(filename)
-	defer r.Close()
-
-	csvReader := csv.NewReader(r)
-
-	// Read header
-	headerRow, err := csvReader.Read()
+	r, err := OpenRecordReader(filename)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+		return nil, err
 	}
+	defer r.Close()
+	return readAllRecords(r)
+}
 
-	// Copy header to avoid issues with ReuseRecord
-	header := make([]string, len(headerRow))
-	copy(header, headerRow)
-
+func readAllRecords(r RecordReader) ([]map[string]any, error) {
 	var records []map[string]any
 	for {
-		row, err := csvReader.Read()
+		record, err := r.Read()
 		if err == io.EOF {
-			break
+			return records, nil
 		}
 		if err != nil {
-			return nil, fmt.Errorf("failed to read CSV row: %w", err)
-		}
-
-		record := make(map[string]any)
-		for i, value := range row {
-			if i >= len(header) {
-				continue
-			}
-			record[header[i]] = inferValue(value)
+			return records, err
 		}
 		records = append(records, record)
 	}
-
-	return records, nil
 }
 
 func inferValue(s string) any {
@@ -120,103 +59,50 @@ func inferValue(s string) any {
 	return s
 }
 
-func readParquetGeneric(filename string) ([]map[string]any, error) {
-	var records []map[string]any
-	err := IterateParquetAny(filename, func(record map[string]any) error {
-		records = append(records, record)
-		return nil
-	})
-	return records, err
-}
-
-// WriteOutput writes records to any supported format
+// WriteOutput writes records to any supported format.
+// Use CreateRecordWriter to write records one at a time.
 func WriteOutput(filename string, records []map[string]any) error {
-	ext := strings.ToLower(filepath.Ext(filename))
-	// Remove compression extension if present
-	baseFilename := filename
-	if ext == ".gz" || ext == ".zst" || ext == ".lz4" || ext == ".br" || ext == ".xz" {
-		baseFilename = strings.TrimSuffix(filename, ext)
-		ext = strings.ToLower(filepath.Ext(baseFilename))
-	}
-
-	switch ext {
-	case ".jsonl", ".ndjson":
-		return writeJSONL(filename, records)
-	case ".msgpack", ".mp":
-		return writeMsgPack(filename, records)
-	case ".csv":
-		return writeCSV(filename, records)
-	case ".parquet", ".pk":
-		return WriteParquetAny(filename, records)
-	default:
-		return fmt.Errorf("unsupported output format: %s (supported: .jsonl, .csv, .msgpack, .parquet/.pk)", ext)
-	}
-}
-
-func writeJSONL(filename string, records []map[string]any) error {
-	w := FUCreate(filename)
-	defer w.Close()
-
-	encoder := json.NewEncoder(w)
-	for _, record := range records {
-		if err := encoder.Encode(record); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func writeMsgPack(filename string, records []map[string]any) error {
-	w := FUCreate(filename)
-	defer w.Close()
-
-	encoder := msgpack.NewEncoder(w)
-	for _, record := range records {
-		if err := encoder.Encode(record); err != nil {
-			return err
-		}
-	}
-	return nil
+	return WriteOutputWithOptions(filename, records, RecordWriterOptions{})
 }
 
-func writeCSV(filename string, records []map[string]any) error {
+// WriteOutputWithOptions is WriteOutput with atomic writes (see RecordWriterOptions);
+// SampleSize is ignored - all records build the CSV header / Parquet schema
+func WriteOutputWithOptions(filename string, records []map[string]any, opts RecordWriterOptions) error {
 	if len(records) == 0 {
-		return fmt.Errorf("no records to write")
-	}
-
-	w := FUCreate(filename)
-	defer w.Close()
-
-	csvWriter := csv.NewWriter(w)
-	defer csvWriter.Flush()
-
-	// Get headers from first record
-	var headers []string
-	for key := range records[0] {
-		headers = append(headers, key)
+		if format, err := DetectFormat(filename); err == nil && (format == FormatCSV || format == FormatParquet) {
+			return fmt.Errorf("no records to write")
+		}
 	}
 
-	// Write header
-	if err := csvWriter.Write(headers); err != nil {
+	// All records are already in memory - use them all to build CSV header / Parquet schema
+	opts.SampleSize = len(records)
+	w, err := CreateRecordWriterWithOptions(filename, opts)
+	if err != nil {
 		return err
 	}
-
-	// Write records
 	for _, record := range records {
-		row := make([]string, len(headers))
-		for i, key := range headers {
-			row[i] = fmt.Sprintf("%v", record[key])
-		}
-		if err := csvWriter.Write(row); err != nil {
+		if err := w.Write(record); err != nil {
+			Abort(w)
 			return err
 		}
 	}
-
-	return nil
+	return w.Close()
 }
 
-// ReadSQLInput executes a SQL query and returns generic records
+// ReadSQLInput executes a SQL query and returns generic records.
+// Use OpenSQLRecordReader to stream large result sets.
 func ReadSQLInput(driver, dsn, query string) ([]map[string]any, error) {
+	r, err := OpenSQLRecordReader(driver, dsn, query)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readAllRecords(r)
+}
+
+// OpenSQLRecordReader executes a SQL query and streams rows as generic records.
+// Values are returned as strings (NULL as nil), same as hbsql.WildSqlQuery.
+func OpenSQLRecordReader(driver, dsn, query string) (RecordReader, error) {
 	// Normalize driver name
 	if driver == "postgre" || driver == "postgresql" {
 		driver = "postgres"
@@ -227,24 +113,68 @@ func ReadSQLInput(driver, dsn, query string) ([]map[string]any, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
-	defer db.Close()
 
 	// Test connection
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	// Execute query using existing WildSqlQuery
-	rows, err := hbsql.WildSqlQuery(db, query)
+	rows, err := db.Query(query)
 	if err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
 
-	// Convert SqlRows to []map[string]any
-	records := make([]map[string]any, len(rows))
-	for i, row := range rows {
-		records[i] = map[string]any(row)
+	columns, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		db.Close()
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]any, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
 	}
 
-	return records, nil
+	return &sqlRecordReader{db: db, rows: rows, columns: columns, values: values, scanArgs: scanArgs}, nil
+}
+
+type sqlRecordReader struct {
+	db       *sql.DB
+	rows     *sql.Rows
+	columns  []string
+	values   []sql.RawBytes
+	scanArgs []any
+}
+
+func (r *sqlRecordReader) Read() (map[string]any, error) {
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	if err := r.rows.Scan(r.scanArgs...); err != nil {
+		return nil, err
+	}
+	record := make(map[string]any, len(r.columns))
+	for i, col := range r.values {
+		if col == nil {
+			record[r.columns[i]] = nil
+			continue
+		}
+		record[r.columns[i]] = string(col)
+	}
+	return record, nil
+}
+
+func (r *sqlRecordReader) Close() error {
+	err := r.rows.Close()
+	if cerr := r.db.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
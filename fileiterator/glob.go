@@ -0,0 +1,282 @@
+package fileiterator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// GlobOptions configures the IterateGlob functions
+type GlobOptions struct {
+	// Parallel is the number of files iterated concurrently (0 or 1 - one file at a time, in sorted order).
+	// With Parallel > 1 the processor is called concurrently for different files and must be goroutine-safe;
+	// records of one file still arrive in order.
+	Parallel int
+}
+
+// GlobStats aggregates a glob iteration
+type GlobStats struct {
+	Files   int           // files iterated to the end
+	Records int64         // records passed to the processor
+	Bytes   int64         // size of the iterated files on disk
+	Elapsed time.Duration // wall time of the whole iteration
+}
+
+// RecordSource identifies a record of a glob iteration
+type RecordSource struct {
+	File   string // file the record came from
+	Record int64  // 1-based number of the record within File
+}
+
+// errGlobStopped stops the other files after the first error of a parallel iteration
+var errGlobStopped = errors.New("stopped")
+
+// IsGlobInput reports whether path is a glob pattern or a directory rather than a single file.
+// URLs and "-" (stdin) are never glob inputs; an existing file is used literally,
+// even if its name contains glob characters (report[1].jsonl).
+func IsGlobInput(path string) bool {
+	if path == "-" || isURL(path) {
+		return false
+	}
+	if fi, err := os.Stat(path); err == nil {
+		return fi.IsDir()
+	}
+	return strings.ContainsAny(path, "*?[")
+}
+
+// GlobFiles expands pattern into a sorted list of files:
+// a glob pattern ("logs/events-2026-10-*.jsonl.zst") returns its matches,
+// a directory returns all files below it (hidden files and directories are skipped),
+// any other path is returned as is. A pattern without matches is reported as ErrNotFound.
+func GlobFiles(pattern string) ([]string, error) {
+	if !IsGlobInput(pattern) {
+		return []string{pattern}, nil
+	}
+
+	var matches []string
+	if fi, err := os.Stat(pattern); err == nil && fi.IsDir() {
+		matches = []string{pattern} // existing directory - glob characters in its name are literal
+	} else if matches, err = filepath.Glob(pattern); err != nil {
+		return nil, fmt.Errorf("%s: %w", pattern, err)
+	}
+	var files []string
+	for _, m := range matches {
+		fi, err := os.Stat(m)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, m)
+			continue
+		}
+		err = filepath.WalkDir(m, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path != m && strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.Type().IsRegular() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s: no files match: %w", pattern, ErrNotFound)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// recordFiles expands a glob input for OpenRecordReader;
+// files of unknown format found in directories are skipped
+func recordFiles(pattern string) ([]string, error) {
+	files, err := GlobFiles(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := os.Stat(pattern); err != nil || !fi.IsDir() {
+		return files, nil
+	}
+	known := files[:0]
+	for _, f := range files {
+		if _, err := DetectFormat(f); err == nil {
+			known = append(known, f)
+		}
+	}
+	if len(known) == 0 {
+		return nil, fmt.Errorf("%s: no files of a supported format: %w", pattern, ErrNotFound)
+	}
+	return known, nil
+}
+
+// iterateGlob runs iterate over every file matching pattern and aggregates statistics
+func iterateGlob[T any](pattern string, opts GlobOptions, iterate func(string, func(T) error) error, processor func(RecordSource, T) error) (GlobStats, error) {
+	start := time.Now()
+	files, err := GlobFiles(pattern)
+	if err != nil {
+		return GlobStats{}, err
+	}
+
+	var (
+		stats    GlobStats
+		mu       sync.Mutex
+		stopped  atomic.Bool
+		firstErr error
+		errOnce  sync.Once
+		wg       sync.WaitGroup
+	)
+	run := func(file string) {
+		defer wg.Done()
+		var n int64
+		err := iterate(file, func(v T) error {
+			if stopped.Load() {
+				return errGlobStopped
+			}
+			n++
+			return processor(RecordSource{File: file, Record: n}, v)
+		})
+
+		mu.Lock()
+		stats.Records += n
+		if err == nil {
+			stats.Files++
+			if fi, err := os.Stat(file); err == nil {
+				stats.Bytes += fi.Size()
+			}
+		}
+		mu.Unlock()
+
+		if err != nil {
+			errOnce.Do(func() { firstErr = fmt.Errorf("%s: %w", file, err) })
+			stopped.Store(true)
+		}
+	}
+
+	sem := make(chan struct{}, max(opts.Parallel, 1))
+	for _, file := range files {
+		sem <- struct{}{}
+		if stopped.Load() {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem }()
+			run(file)
+		}()
+	}
+	wg.Wait()
+
+	stats.Elapsed = time.Since(start)
+	return stats, firstErr
+}
+
+// IterateGlobLines is IterateLinesE over every file matching pattern (see GlobFiles).
+//
+// Example:
+//
+//	stats, err := fileiterator.IterateGlobLines("logs/access-*.log.gz", fileiterator.GlobOptions{Parallel: 4},
+//	    func(src fileiterator.RecordSource, line string) error {
+//	        ...
+//	        return nil
+//	    })
+//	fmt.Printf("%d files, %d lines in %v\n", stats.Files, stats.Records, stats.Elapsed)
+func IterateGlobLines(pattern string, opts GlobOptions, processor func(RecordSource, string) error) (GlobStats, error) {
+	return iterateGlob(pattern, opts, IterateLinesE, processor)
+}
+
+// IterateGlobJSONL is IterateJSONL over every file matching pattern (see GlobFiles)
+func IterateGlobJSONL(pattern string, opts GlobOptions, processor func(RecordSource, map[string]any) error) (GlobStats, error) {
+	return iterateGlob(pattern, opts, IterateJSONL, processor)
+}
+
+// IterateGlobJSONLTyped is IterateJSONLTyped over every file matching pattern (see GlobFiles)
+func IterateGlobJSONLTyped[T any](pattern string, opts GlobOptions, processor func(RecordSource, T) error) (GlobStats, error) {
+	return iterateGlob(pattern, opts, IterateJSONLTyped[T], processor)
+}
+
+// IterateGlobCSV is IterateCSV over every file matching pattern (see GlobFiles)
+func IterateGlobCSV(pattern string, csvOpts CSVOptions, opts GlobOptions, processor func(RecordSource, []string) error) (GlobStats, error) {
+	return iterateGlob(pattern, opts, func(file string, p func([]string) error) error {
+		return IterateCSV(file, csvOpts, p)
+	}, processor)
+}
+
+// IterateGlobCSVMap is IterateCSVMap over every file matching pattern (see GlobFiles)
+func IterateGlobCSVMap(pattern string, csvOpts CSVOptions, opts GlobOptions, processor func(RecordSource, map[string]string) error) (GlobStats, error) {
+	return iterateGlob(pattern, opts, func(file string, p func(map[string]string) error) error {
+		return IterateCSVMap(file, csvOpts, p)
+	}, processor)
+}
+
+// IterateGlobMsgPack is IterateMsgPack over every file matching pattern (see GlobFiles)
+func IterateGlobMsgPack(pattern string, opts GlobOptions, processor func(RecordSource, any) error) (GlobStats, error) {
+	return iterateGlob(pattern, opts, IterateMsgPack, processor)
+}
+
+// IterateGlobParquetAny is IterateParquetAny over every file matching pattern (see GlobFiles)
+func IterateGlobParquetAny(pattern string, opts GlobOptions, processor func(RecordSource, map[string]any) error) (GlobStats, error) {
+	return iterateGlob(pattern, opts, IterateParquetAny, processor)
+}
+
+// multiRecordReader reads the records of several files in order, one file open at a time
+type multiRecordReader struct {
+	files []string
+	cur   RecordReader
+	name  string
+}
+
+func (m *multiRecordReader) Read() (map[string]any, error) {
+	for {
+		if m.cur == nil {
+			if len(m.files) == 0 {
+				return nil, io.EOF
+			}
+			m.name, m.files = m.files[0], m.files[1:]
+			r, err := openRecordFile(m.name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", m.name, err)
+			}
+			m.cur = r
+		}
+
+		record, err := m.cur.Read()
+		if err == io.EOF {
+			err = m.cur.Close()
+			m.cur = nil
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", m.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.name, err)
+		}
+		return record, nil
+	}
+}
+
+func (m *multiRecordReader) Close() error {
+	m.files = nil
+	if m.cur == nil {
+		return nil
+	}
+	err := m.cur.Close()
+	m.cur = nil
+	return err
+}
@@ -0,0 +1,171 @@
+package fileiterator_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/parf/homebase-go-lib/fileiterator"
+)
+
+// writeShards writes n JSONL shards of 10 records each into dir
+func writeShards(t *testing.T, dir string, n int) {
+	t.Helper()
+	for s := 1; s <= n; s++ {
+		w, err := fileiterator.Create(filepath.Join(dir, fmt.Sprintf("events-2026-10-%02d.jsonl.zst", s)), fileiterator.CreateOptions{})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		for i := 1; i <= 10; i++ {
+			fmt.Fprintf(w, "{\"shard\":%d,\"n\":%d}\n", s, i)
+		}
+		w.Close()
+	}
+}
+
+func TestGlobFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeShards(t, tmpDir, 3)
+	os.WriteFile(filepath.Join(tmpDir, ".hidden.jsonl"), []byte("{}\n"), 0644)
+	os.Mkdir(filepath.Join(tmpDir, "sub"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, "sub", "more.jsonl"), []byte("{}\n"), 0644)
+
+	files, err := fileiterator.GlobFiles(filepath.Join(tmpDir, "events-*.jsonl.zst"))
+	if err != nil {
+		t.Fatalf("GlobFiles failed: %v", err)
+	}
+	if len(files) != 3 || filepath.Base(files[0]) != "events-2026-10-01.jsonl.zst" {
+		t.Errorf("Unexpected matches: %v", files)
+	}
+
+	files, err = fileiterator.GlobFiles(tmpDir)
+	if err != nil {
+		t.Fatalf("GlobFiles on directory failed: %v", err)
+	}
+	if len(files) != 4 {
+		t.Errorf("Expected 3 shards and sub/more.jsonl, got %v", files)
+	}
+
+	_, err = fileiterator.GlobFiles(filepath.Join(tmpDir, "nothing-*.jsonl"))
+	if !errors.Is(err, fileiterator.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestIterateGlobJSONL(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeShards(t, tmpDir, 4)
+	pattern := filepath.Join(tmpDir, "events-*.jsonl.zst")
+
+	// Sequential: files in sorted order, records numbered per file
+	var sources []fileiterator.RecordSource
+	stats, err := fileiterator.IterateGlobJSONL(pattern, fileiterator.GlobOptions{}, func(src fileiterator.RecordSource, obj map[string]any) error {
+		if int64(obj["n"].(float64)) != src.Record {
+			t.Errorf("Record %d of %s holds n=%v", src.Record, src.File, obj["n"])
+		}
+		sources = append(sources, src)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateGlobJSONL failed: %v", err)
+	}
+	if stats.Files != 4 || stats.Records != 40 || stats.Bytes == 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if filepath.Base(sources[0].File) != "events-2026-10-01.jsonl.zst" || filepath.Base(sources[39].File) != "events-2026-10-04.jsonl.zst" {
+		t.Errorf("Files not in sorted order: first %s, last %s", sources[0].File, sources[39].File)
+	}
+
+	// Parallel
+	var mu sync.Mutex
+	perFile := map[string]int{}
+	stats, err = fileiterator.IterateGlobJSONLTyped(pattern, fileiterator.GlobOptions{Parallel: 3}, func(src fileiterator.RecordSource, rec struct{ N int }) error {
+		mu.Lock()
+		defer mu.Unlock()
+		perFile[src.File]++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Parallel IterateGlobJSONLTyped failed: %v", err)
+	}
+	if stats.Records != 40 || len(perFile) != 4 {
+		t.Errorf("Unexpected parallel result: %+v, %v", stats, perFile)
+	}
+
+	// The first error stops the iteration and names the file
+	boom := errors.New("boom")
+	_, err = fileiterator.IterateGlobLines(pattern, fileiterator.GlobOptions{Parallel: 2}, func(src fileiterator.RecordSource, line string) error {
+		if src.Record == 5 {
+			return boom
+		}
+		return nil
+	})
+	if !errors.Is(err, boom) {
+		t.Errorf("Expected boom, got %v", err)
+	}
+}
+
+func TestReadInputGlobAndDirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeShards(t, tmpDir, 2)
+	os.WriteFile(filepath.Join(tmpDir, "notes.txt"), []byte("not a record file\n"), 0644)
+	if err := fileiterator.WriteOutput(filepath.Join(tmpDir, "extra.csv"), []map[string]any{{"shard": 9, "n": 1}}); err != nil {
+		t.Fatalf("WriteOutput failed: %v", err)
+	}
+
+	records, err := fileiterator.ReadInput(filepath.Join(tmpDir, "events-*.jsonl.zst"))
+	if err != nil {
+		t.Fatalf("ReadInput of glob failed: %v", err)
+	}
+	if len(records) != 20 {
+		t.Errorf("Expected 20 records, got %d", len(records))
+	}
+
+	// Directories skip files of unknown format (notes.txt)
+	records, err = fileiterator.ReadInput(tmpDir)
+	if err != nil {
+		t.Fatalf("ReadInput of directory failed: %v", err)
+	}
+	if len(records) != 21 {
+		t.Errorf("Expected 21 records, got %d", len(records))
+	}
+}
+
+func TestIsGlobInputLiteralPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+	os.WriteFile("report[1].jsonl", []byte("{\"a\":1}\n"), 0644)
+	os.WriteFile("httpd.jsonl", []byte("{\"a\":2}\n"), 0644)
+	os.Mkdir("logs[2026]", 0755)
+	os.WriteFile(filepath.Join("logs[2026]", "x.jsonl"), []byte("{\"a\":3}\n"), 0644)
+
+	tests := []struct {
+		path string
+		glob bool
+	}{
+		{"report[1].jsonl", false}, // existing file with glob characters
+		{"httpd.jsonl", false},
+		{"logs[2026]", true}, // existing directory
+		{"report[0-9].jsonl.gz", true},
+		{"http://host/*.jsonl", false},
+		{"-", false},
+	}
+	for _, tt := range tests {
+		if got := fileiterator.IsGlobInput(tt.path); got != tt.glob {
+			t.Errorf("IsGlobInput(%q) = %v, want %v", tt.path, got, tt.glob)
+		}
+	}
+
+	for path, want := range map[string]float64{"report[1].jsonl": 1, "httpd.jsonl": 2, "logs[2026]": 3} {
+		records, err := fileiterator.ReadInput(path)
+		if err != nil {
+			t.Fatalf("ReadInput(%q) failed: %v", path, err)
+		}
+		if len(records) != 1 || records[0]["a"] != want {
+			t.Errorf("ReadInput(%q) = %v", path, records)
+		}
+	}
+}
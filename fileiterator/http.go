@@ -0,0 +1,283 @@
+package fileiterator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPOptions configures how Open fetches http(s) sources
+type HTTPOptions struct {
+	Client        *http.Client  // nil - http.DefaultClient
+	Header        http.Header   // Extra request headers
+	BearerToken   string        // Sent as "Authorization: Bearer <token>"
+	Timeout       time.Duration // Max wait for response headers, per attempt; 0 - no limit
+	ReadTimeout   time.Duration // Max stall of the body before the connection is dropped and resumed; 0 - no limit
+	MaxRetries    int           // Retries after consecutive failures (5xx, 429, network errors, dropped body)
+	RetryDelay    time.Duration // First retry delay, doubled on every retry
+	MaxRetryDelay time.Duration // Retry delay cap
+}
+
+// DefaultHTTPOptions returns default HTTP options: 3 retries starting at 500ms,
+// 30s header timeout and 60s read timeout
+func DefaultHTTPOptions() HTTPOptions {
+	return HTTPOptions{
+		Timeout:       30 * time.Second,
+		ReadTimeout:   60 * time.Second,
+		MaxRetries:    3,
+		RetryDelay:    500 * time.Millisecond,
+		MaxRetryDelay: 30 * time.Second,
+	}
+}
+
+// ErrSourceChanged is returned when a download cannot be resumed
+// because the remote content changed (ETag / Last-Modified mismatch)
+var ErrSourceChanged = errors.New("remote source changed during download")
+
+// httpSource is a GET response body that transparently resumes
+// with Range requests when the connection drops
+type httpSource struct {
+	ctx  context.Context
+	url  string
+	opts HTTPOptions
+
+	body   io.ReadCloser
+	cancel context.CancelFunc
+	idle   *time.Timer
+	err    error // sticky error, returned by all following Reads
+
+	offset    int64  // bytes delivered so far
+	size      int64  // total size, -1 - unknown
+	validator string // ETag or Last-Modified for If-Range
+	failures  int    // consecutive failures without progress
+}
+
+// openHTTP issues the first request (with retries) and returns the resumable body
+func openHTTP(ctx context.Context, url string, opts HTTPOptions) (io.ReadCloser, error) {
+	s := &httpSource{ctx: ctx, url: url, opts: opts, size: -1}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *httpSource) Read(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	for {
+		if s.idle != nil {
+			s.idle.Reset(s.opts.ReadTimeout)
+		}
+		n, err := s.body.Read(p)
+		if s.idle != nil {
+			s.idle.Stop() // only stalls inside Read count, not slow consumers
+		}
+		s.offset += int64(n)
+		if n > 0 {
+			s.failures = 0
+		}
+		switch {
+		case err == nil:
+			return n, nil
+		case err == io.EOF && (s.size < 0 || s.offset >= s.size):
+			return n, io.EOF
+		case s.ctx.Err() != nil:
+			s.err = s.ctx.Err()
+			return n, s.err
+		}
+
+		// Connection dropped - resume from s.offset
+		s.closeBody()
+		if s.failures >= s.opts.MaxRetries {
+			s.err = fmt.Errorf("Url: %s - download failed at byte %d: %w", s.url, s.offset, err)
+			return n, s.err
+		}
+		if err := s.wait(); err != nil {
+			s.err = err
+			return n, err
+		}
+		if err := s.connect(); err != nil {
+			s.err = err
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (s *httpSource) Close() error {
+	s.closeBody()
+	if s.err == nil {
+		s.err = errors.New("read from closed http source")
+	}
+	return nil
+}
+
+func (s *httpSource) closeBody() {
+	if s.idle != nil {
+		s.idle.Stop()
+		s.idle = nil
+	}
+	if s.body != nil {
+		s.body.Close()
+		s.body = nil
+	}
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+}
+
+// connect requests the content from s.offset, retrying with backoff
+func (s *httpSource) connect() error {
+	err := s.request()
+	for err != nil && s.retryable(err) && s.failures < s.opts.MaxRetries {
+		if werr := s.wait(); werr != nil {
+			return werr
+		}
+		err = s.request()
+	}
+	return err
+}
+
+// retryable reports whether a failed request should be repeated
+func (s *httpSource) retryable(err error) bool {
+	if s.ctx.Err() != nil || errors.Is(err, ErrSourceChanged) {
+		return false
+	}
+	var statusErr *ErrHTTPStatus
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= 500 || statusErr.Code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// wait sleeps for the next backoff delay
+func (s *httpSource) wait() error {
+	delay := s.opts.RetryDelay
+	for i := 0; i < s.failures && (s.opts.MaxRetryDelay == 0 || delay < s.opts.MaxRetryDelay); i++ {
+		delay *= 2
+	}
+	if s.opts.MaxRetryDelay > 0 && delay > s.opts.MaxRetryDelay {
+		delay = s.opts.MaxRetryDelay
+	}
+	s.failures++
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// request issues a single GET from s.offset and installs the response body
+func (s *httpSource) request() error {
+	ctx, cancel := context.WithCancel(s.ctx)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		cancel()
+		return err
+	}
+	for k, v := range s.opts.Header {
+		req.Header[k] = v
+	}
+	if s.opts.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.opts.BearerToken)
+	}
+	// Transparent gzip would make byte offsets meaningless for Range
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", "identity")
+	}
+	if s.offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(s.offset, 10)+"-")
+		if s.validator != "" {
+			req.Header.Set("If-Range", s.validator)
+		}
+	}
+
+	client := s.opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var headerTimer *time.Timer
+	if s.opts.Timeout > 0 {
+		headerTimer = time.AfterFunc(s.opts.Timeout, cancel)
+	}
+	resp, err := client.Do(req)
+	if headerTimer != nil {
+		headerTimer.Stop()
+	}
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	if err := s.accept(resp); err != nil {
+		resp.Body.Close()
+		cancel()
+		return err
+	}
+
+	s.body = resp.Body
+	s.cancel = cancel
+	if s.opts.ReadTimeout > 0 {
+		s.idle = time.AfterFunc(s.opts.ReadTimeout, cancel)
+	}
+	return nil
+}
+
+// accept validates a response and positions its body at s.offset
+func (s *httpSource) accept(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusOK && s.offset == 0:
+		s.size = resp.ContentLength
+		s.validator = resp.Header.Get("ETag")
+		if s.validator == "" || strings.HasPrefix(s.validator, "W/") {
+			// If-Range requires a strong validator
+			s.validator = resp.Header.Get("Last-Modified")
+		}
+		return nil
+
+	case resp.StatusCode == http.StatusPartialContent && s.offset > 0:
+		start, err := contentRangeStart(resp.Header.Get("Content-Range"))
+		if err != nil || start != s.offset {
+			return fmt.Errorf("Url: %s - bad Content-Range %q for offset %d", s.url, resp.Header.Get("Content-Range"), s.offset)
+		}
+		return nil
+
+	case resp.StatusCode == http.StatusOK && s.offset > 0:
+		if s.validator != "" {
+			// If-Range did not match - the content is different now
+			return ErrSourceChanged
+		}
+		// Server ignores Range - skip what was already delivered
+		if _, err := io.CopyN(io.Discard, resp.Body, s.offset); err != nil {
+			return err
+		}
+		return nil
+	}
+	return &ErrHTTPStatus{URL: s.url, Code: resp.StatusCode}
+}
+
+// contentRangeStart parses the first byte position of "bytes START-END/SIZE"
+func contentRangeStart(header string) (int64, error) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, errors.New("not a byte range")
+	}
+	start, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, errors.New("malformed range")
+	}
+	return strconv.ParseInt(start, 10, 64)
+}
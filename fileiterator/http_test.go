@@ -0,0 +1,240 @@
+package fileiterator_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/parf/homebase-go-lib/fileiterator"
+)
+
+// fastRetries keeps retry tests quick
+func fastRetries() *fileiterator.HTTPOptions {
+	opts := fileiterator.DefaultHTTPOptions()
+	opts.RetryDelay = time.Millisecond
+	opts.MaxRetryDelay = 10 * time.Millisecond
+	return &opts
+}
+
+func testPayload(size int) []byte {
+	var buf bytes.Buffer
+	for i := 0; buf.Len() < size; i++ {
+		fmt.Fprintf(&buf, "line %d\n", i)
+	}
+	return buf.Bytes()[:size]
+}
+
+func TestOpenHTTPResumesDroppedConnection(t *testing.T) {
+	payload := testPayload(1 << 20)
+	var requests, ranged atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("Range") != "" {
+			ranged.Add(1)
+			if r.Header.Get("If-Range") != `"v1"` {
+				t.Errorf("Expected If-Range with ETag, got %q", r.Header.Get("If-Range"))
+			}
+		}
+		if n <= 2 {
+			// Announce the rest of the body, send 100000 bytes of it, then drop the connection
+			start := offsetFromRange(r)
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)-start))
+			if start > 0 {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(payload)-1, len(payload)))
+				w.WriteHeader(http.StatusPartialContent)
+			}
+			w.Write(payload[start : start+100000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "data.txt", time.Time{}, bytes.NewReader(payload))
+	}))
+	defer srv.Close()
+
+	r, err := fileiterator.OpenWithOptions(context.Background(), srv.URL+"/data.txt", fileiterator.OpenOptions{HTTP: fastRetries()})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(data, payload) {
+		t.Errorf("Resumed body differs: got %d bytes, want %d", len(data), len(payload))
+	}
+	if ranged.Load() != 2 {
+		t.Errorf("Expected 2 range requests, got %d", ranged.Load())
+	}
+}
+
+func offsetFromRange(r *http.Request) int {
+	var start int
+	fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &start)
+	return start
+}
+
+func TestOpenHTTPSourceChanged(t *testing.T) {
+	payload := testPayload(200000)
+	var requests atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+			w.Write(payload[:50000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "data.txt", time.Time{}, bytes.NewReader(payload))
+	}))
+	defer srv.Close()
+
+	r, err := fileiterator.OpenWithOptions(context.Background(), srv.URL+"/data.txt", fileiterator.OpenOptions{HTTP: fastRetries()})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+
+	_, err = io.ReadAll(r)
+	if !errors.Is(err, fileiterator.ErrSourceChanged) {
+		t.Errorf("Expected ErrSourceChanged, got %v", err)
+	}
+}
+
+func TestOpenHTTPRetriesAndHeaders(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("X-Client") != "test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	opts := fastRetries()
+	opts.BearerToken = "secret"
+	opts.Header = http.Header{"X-Client": {"test"}}
+
+	r, err := fileiterator.OpenWithOptions(context.Background(), srv.URL, fileiterator.OpenOptions{HTTP: opts})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "ok" || requests.Load() != 3 {
+		t.Errorf("Expected ok after 3 requests, got %q after %d", data, requests.Load())
+	}
+
+	// No auth - 401 is not retried
+	requests.Store(0)
+	_, err = fileiterator.OpenWithOptions(context.Background(), srv.URL, fileiterator.OpenOptions{HTTP: fastRetries()})
+	var statusErr *fileiterator.ErrHTTPStatus
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected ErrHTTPStatus{401}, got %v", err)
+	}
+	if requests.Load() != 0 {
+		t.Errorf("Expected no retries for 401")
+	}
+}
+
+func TestOpenHTTPRetriesExhausted(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	opts := fastRetries()
+	opts.MaxRetries = 2
+	_, err := fileiterator.OpenWithOptions(context.Background(), srv.URL, fileiterator.OpenOptions{HTTP: opts})
+	var statusErr *fileiterator.ErrHTTPStatus
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusBadGateway {
+		t.Errorf("Expected ErrHTTPStatus{502}, got %v", err)
+	}
+	if requests.Load() != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests.Load())
+	}
+}
+
+func TestOpenHTTPContextCancel(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testPayload(100))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r, err := fileiterator.OpenWithOptions(ctx, srv.URL, fileiterator.OpenOptions{HTTP: fastRetries()})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+
+	buf := make([]byte, 5)
+	io.ReadFull(r, buf)
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err = io.ReadAll(r)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestOpenHTTPReadTimeout(t *testing.T) {
+	payload := testPayload(10000)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			// Stall after the first part
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+			w.Write(payload[:1000])
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		http.ServeContent(w, r, "data.txt", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC), bytes.NewReader(payload))
+	}))
+	defer srv.Close()
+
+	opts := fastRetries()
+	opts.ReadTimeout = 50 * time.Millisecond
+	r, err := fileiterator.OpenWithOptions(context.Background(), srv.URL, fileiterator.OpenOptions{HTTP: opts})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(data, payload) {
+		t.Errorf("Got %d bytes, want %d", len(data), len(payload))
+	}
+}
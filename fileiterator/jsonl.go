@@ -2,6 +2,7 @@ package fileiterator
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -20,7 +21,10 @@ import (
 //	    return nil
 //	})
 func IterateJSONL(filename string, processor func(map[string]any) error) error {
-	fi := FUOpen(filename) // Auto-detects compression
+	fi, err := Open(context.Background(), filename) // Auto-detects compression
+	if err != nil {
+		return err
+	}
 	defer fi.Close()
 
 	scanner := bufio.NewScanner(fi)
@@ -71,7 +75,10 @@ func IterateJSONL(filename string, processor func(map[string]any) error) error {
 //	    return nil
 //	})
 func IterateJSONLTyped[T any](filename string, processor func(T) error) error {
-	fi := FUOpen(filename) // Auto-detects compression
+	fi, err := Open(context.Background(), filename) // Auto-detects compression
+	if err != nil {
+		return err
+	}
 	defer fi.Close()
 
 	scanner := bufio.NewScanner(fi)
@@ -103,3 +110,72 @@ func IterateJSONLTyped[T any](filename string, processor func(T) error) error {
 	fmt.Printf("File %s. Lines processed: %d\n", filename, lineNum)
 	return nil
 }
+
+// IterateJSONLWithOptions is IterateJSONL with checkpoints and resume (see CheckpointOptions).
+// Open errors are returned instead of panicking; line numbers continue from the resume position.
+//
+// Example:
+//
+//	opts := fileiterator.CheckpointOptions{Every: 100000, File: "events.jsonl.zst.ckpt"}
+//	err := fileiterator.IterateJSONLWithOptions("events.jsonl.zst", opts, func(obj map[string]any) error {
+//	    return store(obj) // restarted jobs continue after the last checkpoint
+//	})
+func IterateJSONLWithOptions(filename string, opts CheckpointOptions, processor func(map[string]any) error) error {
+	return iterateJSONLCheckpointed(filename, opts, processor)
+}
+
+// IterateJSONLTypedWithOptions is IterateJSONLTyped with checkpoints and resume (see CheckpointOptions).
+func IterateJSONLTypedWithOptions[T any](filename string, opts CheckpointOptions, processor func(T) error) error {
+	return iterateJSONLCheckpointed(filename, opts, processor)
+}
+
+func iterateJSONLCheckpointed[T any](filename string, opts CheckpointOptions, processor func(T) error) error {
+	c, err := openCheckpointed(filename, "", opts)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// consumed is the size of the last line including its line ending
+	consumed := 0
+	scanner := bufio.NewScanner(c.src)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		consumed = advance
+		return advance, token, err
+	})
+	lineNum := c.pos.Records
+
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Bytes()
+
+		// Skip empty lines
+		if len(line) > 0 {
+			var obj T
+			if err := json.Unmarshal(line, &obj); err != nil {
+				return fmt.Errorf("line %d: JSON parse error: %w", lineNum, err)
+			}
+
+			if err := processor(obj); err != nil {
+				return fmt.Errorf("line %d: processor error: %w", lineNum, err)
+			}
+		}
+
+		if err := c.advance(consumed); err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("scanner error: %w", err)
+	}
+	if c.since > 0 {
+		if err := c.checkpoint(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("File %s. Lines processed: %d\n", filename, lineNum)
+	return nil
+}
@@ -2,16 +2,53 @@ package fileiterator
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/edsrzf/mmap-go"
+	"github.com/parf/homebase-go-lib/clistat"
 )
 
+// MmapAdvice is an access pattern hint for the kernel (madvise).
+// Hints are ignored on platforms without madvise.
+type MmapAdvice int
+
+const (
+	MmapNormal     MmapAdvice = iota // no special treatment
+	MmapSequential                   // read ahead aggressively, drop pages after use
+	MmapRandom                       // no read ahead
+	MmapWillNeed                     // start loading pages now
+	MmapDontNeed                     // pages may be dropped
+)
+
+// mmapAlign is the alignment of window offsets: a multiple of the page size
+// on all supported systems and of the Windows allocation granularity
+var mmapAlign = int64(max(os.Getpagesize(), 64<<10))
+
+// MmapOptions controls how a file is mapped
+type MmapOptions struct {
+	// RecordSize is the fixed record size used by Record (0 = Record is not available)
+	RecordSize int
+	// Advice is applied to every mapped region (MmapNormal = no hint)
+	Advice MmapAdvice
+	// WindowSize maps at most this many bytes at a time (0 = map the whole file).
+	// Use it for files larger than the address space budget; Record and
+	// MapWindow move the window as needed.
+	WindowSize int64
+	// Writable maps the file read-write and shared: writes to Data go to the
+	// file and are visible to other processes mapping it
+	Writable bool
+}
+
 // MmapFile represents a memory-mapped file with lifecycle management
 type MmapFile struct {
+	// Data is the mapped region: the whole file, or the current window starting at Offset()
 	Data []byte
 	mmap mmap.MMap
 	file *os.File
+	opts MmapOptions
+	size int64 // file size
+	off  int64 // file offset of Data
 }
 
 // Close unmaps the memory and closes the file
@@ -22,12 +59,14 @@ func (m *MmapFile) Close() error {
 		if err := m.mmap.Unmap(); err != nil {
 			errs = append(errs, fmt.Errorf("failed to unmap: %w", err))
 		}
+		m.Data = nil
 	}
 
 	if m.file != nil {
 		if err := m.file.Close(); err != nil {
 			errs = append(errs, fmt.Errorf("failed to close file: %w", err))
 		}
+		m.file = nil
 	}
 
 	if len(errs) > 0 {
@@ -42,24 +81,153 @@ func (m *MmapFile) Close() error {
 // Best for: Large read-heavy files where random access is needed
 // Not suitable for: Compressed files, streaming, or write operations
 func MmapOpen(filename string) (*MmapFile, error) {
-	file, err := os.Open(filename)
+	return MmapOpenWithOptions(filename, MmapOptions{})
+}
+
+// MmapOpenWithOptions opens a file with memory mapping, with fixed-size record
+// access, access pattern hints, windowed mapping and optional write access.
+//
+// Example:
+//
+//	m, err := fileiterator.MmapOpenWithOptions("points.bin", fileiterator.MmapOptions{
+//	    RecordSize: 16,
+//	    Advice:     fileiterator.MmapRandom,
+//	    WindowSize: 1 << 30, // map 1GB at a time
+//	})
+//	if err != nil {
+//	    return err
+//	}
+//	defer m.Close()
+//	rec, err := m.Record(m.NumRecords(16) - 1)
+func MmapOpenWithOptions(filename string, opts MmapOptions) (*MmapFile, error) {
+	if opts.RecordSize < 0 || opts.WindowSize < 0 {
+		return nil, fmt.Errorf("invalid mmap options: record size %d, window size %d", opts.RecordSize, opts.WindowSize)
+	}
+
+	flag := os.O_RDONLY
+	if opts.Writable {
+		flag = os.O_RDWR
+	}
+	file, err := os.OpenFile(filename, flag, 0)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
-
-	mmapData, err := mmap.Map(file, mmap.RDONLY, 0)
+	fi, err := file.Stat()
 	if err != nil {
 		file.Close()
-		return nil, fmt.Errorf("failed to map file: %w", err)
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if fi.Size() == 0 && !opts.Writable {
+		file.Close()
+		return nil, fmt.Errorf("failed to map file: %s is empty", filename)
+	}
+
+	m := &MmapFile{file: file, opts: opts, size: fi.Size()}
+	if err := m.mapRegion(0, 0); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// Size returns the size of the mapped file in bytes
+func (m *MmapFile) Size() int64 {
+	return m.size
+}
+
+// Offset returns the file offset of Data (always 0 unless WindowSize is set)
+func (m *MmapFile) Offset() int64 {
+	return m.off
+}
+
+// NumRecords returns the number of complete records of recordSize in the file
+func (m *MmapFile) NumRecords(recordSize int) int {
+	if recordSize <= 0 {
+		return 0
+	}
+	return int(m.size / int64(recordSize))
+}
+
+// Record returns record i (0-based) of RecordSize bytes without copying.
+// The slice is valid until Close; with WindowSize set, only until the window
+// moves. It is read-only unless the file was opened with Writable.
+func (m *MmapFile) Record(i int) ([]byte, error) {
+	size := int64(m.opts.RecordSize)
+	if size == 0 {
+		return nil, fmt.Errorf("mmap: RecordSize is not set")
+	}
+	if i < 0 || i >= m.NumRecords(m.opts.RecordSize) {
+		return nil, fmt.Errorf("mmap: record %d out of range [0, %d)", i, m.NumRecords(m.opts.RecordSize))
+	}
+	rec, err := m.at(int64(i)*size, size)
+	if err != nil {
+		return nil, err
+	}
+	return rec[:size:size], nil
+}
+
+// MapWindow moves the window so Data starts at or shortly before offset
+// (window offsets are aligned to 64KB). Data from the previous window must not be used afterwards.
+func (m *MmapFile) MapWindow(offset int64) error {
+	if offset < 0 || offset >= m.size {
+		return fmt.Errorf("mmap: offset %d out of range [0, %d)", offset, m.size)
 	}
+	return m.mapRegion(offset, 0)
+}
+
+// Advise applies an access pattern hint to the mapped region and to windows mapped later
+func (m *MmapFile) Advise(advice MmapAdvice) error {
+	m.opts.Advice = advice
+	return madvise(m.Data, advice)
+}
 
-	fmt.Printf("Memory-mapped file: %s (%d bytes)\n", filename, len(mmapData))
+// mapRegion maps the window that starts at offset (aligned down) and holds
+// at least need bytes from offset; without WindowSize the whole file is mapped once
+func (m *MmapFile) mapRegion(offset, need int64) error {
+	start, length := int64(0), m.size
+	if m.opts.WindowSize > 0 {
+		start = offset - offset%mmapAlign
+		length = min(max(m.opts.WindowSize, offset-start+need), m.size-start)
+	}
+	if m.mmap != nil && start == m.off && length == int64(len(m.mmap)) {
+		return nil
+	}
+
+	if err := m.unmap(); err != nil {
+		return err
+	}
+	if length == 0 {
+		// Empty writable file, mapped by Grow
+		return nil
+	}
+	prot := mmap.RDONLY
+	if m.opts.Writable {
+		prot = mmap.RDWR
+	}
+	data, err := mmap.MapRegion(m.file, int(length), prot, 0, start)
+	if err != nil {
+		return fmt.Errorf("failed to map file: %w", err)
+	}
+	m.mmap, m.Data, m.off = data, data, start
 
-	return &MmapFile{
-		Data: mmapData,
-		mmap: mmapData,
-		file: file,
-	}, nil
+	if m.opts.Advice != MmapNormal {
+		if err := madvise(m.Data, m.opts.Advice); err != nil {
+			return fmt.Errorf("madvise: %w", err)
+		}
+	}
+	return nil
+}
+
+func (m *MmapFile) unmap() error {
+	if m.mmap == nil {
+		return nil
+	}
+	err := m.mmap.Unmap()
+	m.mmap, m.Data = nil, nil
+	if err != nil {
+		return fmt.Errorf("failed to unmap: %w", err)
+	}
+	return nil
 }
 
 // LoadMmap loads a file using memory mapping for ultra-fast access
@@ -82,3 +250,48 @@ func LoadMmap(filename string) ([]byte, error) {
 	// The OS will handle cleanup, but for explicit control use MmapOpen/Close
 	return mmapFile.Data, nil
 }
+
+// mmapIterateWindow bounds the address space used by IterateBinaryRecordsMmap
+const mmapIterateWindow = 256 << 20
+
+// IterateBinaryRecordsMmap iterates over an uncompressed local file of binary
+// records of fixed recordSize without copying: records are slices of the mapping.
+// Same as IterateBinaryRecordsE, but much faster for large files. The record
+// slice is read-only and valid only during the processor call.
+// A truncated trailing record is reported as io.ErrUnexpectedEOF.
+func IterateBinaryRecordsMmap(filename string, recordSize int, processor func([]byte) error) error {
+	if recordSize <= 0 {
+		return fmt.Errorf("invalid record size %d", recordSize)
+	}
+	if fi, err := os.Stat(filename); err == nil && fi.Size() == 0 {
+		return nil
+	}
+	m, err := MmapOpenWithOptions(filename, MmapOptions{
+		RecordSize: recordSize,
+		Advice:     MmapSequential,
+		WindowSize: mmapIterateWindow,
+	})
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	stat := clistat.New(10)
+	fmt.Printf("Loading: %v\n", filename)
+	defer stat.Finish()
+	n := m.NumRecords(recordSize)
+	for i := 0; i < n; i++ {
+		rec, err := m.Record(i)
+		if err != nil {
+			return fmt.Errorf("cnt: %d: %w", stat.Cnt, err)
+		}
+		stat.Hit()
+		if err := processor(rec); err != nil {
+			return fmt.Errorf("record %d: processor error: %w", stat.Cnt, err)
+		}
+	}
+	if tail := m.Size() % int64(recordSize); tail != 0 {
+		return fmt.Errorf("cnt: %d read:%d: %w", stat.Cnt, tail, io.ErrUnexpectedEOF)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package fileiterator
+
+import "github.com/edsrzf/mmap-go"
+
+// madvise is a no-op on platforms without madvise
+func madvise(b []byte, advice MmapAdvice) error {
+	return nil
+}
+
+// msyncAsync falls back to a synchronous flush
+func msyncAsync(m mmap.MMap) error {
+	return m.Flush()
+}
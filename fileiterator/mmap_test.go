@@ -1,6 +1,9 @@
 package fileiterator_test
 
 import (
+	"encoding/binary"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
@@ -124,3 +127,233 @@ func TestMmapRandomAccess(t *testing.T) {
 		}
 	}
 }
+
+func writeRecordFile(t *testing.T, path string, n, recordSize int) {
+	t.Helper()
+	data := make([]byte, n*recordSize)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint32(data[i*recordSize:], uint32(i))
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+}
+
+func TestMmapRecords(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "records.bin")
+	writeRecordFile(t, testFile, 1000, 12)
+
+	m, err := fileiterator.MmapOpenWithOptions(testFile, fileiterator.MmapOptions{RecordSize: 12, Advice: fileiterator.MmapRandom})
+	if err != nil {
+		t.Fatalf("MmapOpenWithOptions failed: %v", err)
+	}
+	defer m.Close()
+
+	if m.NumRecords(12) != 1000 || m.NumRecords(7) != 1714 || m.Size() != 12000 {
+		t.Errorf("Unexpected sizes: %d records, %d bytes", m.NumRecords(12), m.Size())
+	}
+	for _, i := range []int{0, 1, 500, 999} {
+		rec, err := m.Record(i)
+		if err != nil || len(rec) != 12 || binary.LittleEndian.Uint32(rec) != uint32(i) {
+			t.Errorf("Record(%d) = %v, %v", i, rec, err)
+		}
+	}
+	if _, err := m.Record(1000); err == nil {
+		t.Error("Expected out of range error")
+	}
+	if err := m.Advise(fileiterator.MmapWillNeed); err != nil {
+		t.Errorf("Advise failed: %v", err)
+	}
+}
+
+func TestMmapWindow(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "large.bin")
+	// 100 bytes per record: records straddle the 64KB window boundaries
+	writeRecordFile(t, testFile, 10000, 100)
+
+	m, err := fileiterator.MmapOpenWithOptions(testFile, fileiterator.MmapOptions{RecordSize: 100, WindowSize: 64 << 10})
+	if err != nil {
+		t.Fatalf("MmapOpenWithOptions failed: %v", err)
+	}
+	defer m.Close()
+
+	if len(m.Data) != 64<<10 {
+		t.Errorf("Expected a 64KB window, got %d bytes", len(m.Data))
+	}
+	for _, i := range []int{9999, 655, 656, 0, 5000} {
+		rec, err := m.Record(i)
+		if err != nil || len(rec) != 100 || binary.LittleEndian.Uint32(rec) != uint32(i) {
+			t.Errorf("Record(%d) = %v, %v", i, rec, err)
+		}
+	}
+
+	if err := m.MapWindow(200000); err != nil {
+		t.Fatalf("MapWindow failed: %v", err)
+	}
+	if m.Offset() != 196608 || binary.LittleEndian.Uint32(m.Data[200000-m.Offset():]) != 2000 {
+		t.Errorf("Unexpected window at offset %d", m.Offset())
+	}
+	if err := m.MapWindow(m.Size()); err == nil {
+		t.Error("Expected out of range error")
+	}
+}
+
+func TestIterateBinaryRecordsMmap(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "records.bin")
+	writeRecordFile(t, testFile, 5000, 16)
+
+	next := uint32(0)
+	err := fileiterator.IterateBinaryRecordsMmap(testFile, 16, func(rec []byte) error {
+		if len(rec) != 16 || binary.LittleEndian.Uint32(rec) != next {
+			t.Fatalf("Unexpected record %v, want %d", rec, next)
+		}
+		next++
+		return nil
+	})
+	if err != nil || next != 5000 {
+		t.Errorf("IterateBinaryRecordsMmap: %d records, err %v", next, err)
+	}
+
+	// Truncated trailing record
+	err = fileiterator.IterateBinaryRecordsMmap(testFile, 3000, func([]byte) error { return nil })
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+
+	stop := errors.New("stop")
+	err = fileiterator.IterateBinaryRecordsMmap(testFile, 16, func([]byte) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Errorf("Expected processor error, got %v", err)
+	}
+
+	empty := filepath.Join(tmpDir, "empty.bin")
+	os.WriteFile(empty, nil, 0644)
+	if err := fileiterator.IterateBinaryRecordsMmap(empty, 16, func([]byte) error { return stop }); err != nil {
+		t.Errorf("Expected no records in empty file, got %v", err)
+	}
+}
+
+func TestMmapCreateAndAccessors(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "table.bin")
+	m, err := fileiterator.MmapCreate(testFile, 64)
+	if err != nil {
+		t.Fatalf("MmapCreate failed: %v", err)
+	}
+	defer m.Close()
+
+	m.PutUint16At(0, 0xBEEF)
+	m.PutInt32At(2, -5)
+	m.PutUint64At(8, 1<<40)
+	if err := m.PutInt64At(56, -1); err != nil {
+		t.Fatalf("PutInt64At failed: %v", err)
+	}
+	if err := m.PutUint32At(62, 1); err == nil {
+		t.Error("Expected out of range error")
+	}
+
+	// Another reader of the same file sees the writes before Flush
+	r, err := fileiterator.MmapOpen(testFile)
+	if err != nil {
+		t.Fatalf("MmapOpen failed: %v", err)
+	}
+	defer r.Close()
+	if v, _ := r.Uint16At(0); v != 0xBEEF {
+		t.Errorf("Uint16At = %x", v)
+	}
+	if v, _ := r.Int32At(2); v != -5 {
+		t.Errorf("Int32At = %d", v)
+	}
+	if v, _ := r.Uint64At(8); v != 1<<40 {
+		t.Errorf("Uint64At = %d", v)
+	}
+	if v, _ := r.Int64At(56); v != -1 {
+		t.Errorf("Int64At = %d", v)
+	}
+	if err := r.PutUint16At(0, 1); err == nil {
+		t.Error("Expected error writing to read-only mapping")
+	}
+
+	if err := m.FlushAsync(); err != nil {
+		t.Errorf("FlushAsync failed: %v", err)
+	}
+	if err := m.Flush(); err != nil {
+		t.Errorf("Flush failed: %v", err)
+	}
+	data, _ := os.ReadFile(testFile)
+	if len(data) != 64 || binary.LittleEndian.Uint64(data[8:]) != 1<<40 {
+		t.Errorf("Unexpected file contents %v", data)
+	}
+}
+
+func TestMmapGrowTruncate(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "grow.bin")
+	os.WriteFile(testFile, nil, 0644)
+
+	m, err := fileiterator.MmapOpenWithOptions(testFile, fileiterator.MmapOptions{RecordSize: 8, Writable: true})
+	if err != nil {
+		t.Fatalf("MmapOpenWithOptions failed: %v", err)
+	}
+	defer m.Close()
+	if m.NumRecords(8) != 0 {
+		t.Fatalf("Expected empty file, got %d records", m.NumRecords(8))
+	}
+
+	for i := 0; i < 100; i++ {
+		if err := m.Grow(int64(i+1) * 8); err != nil {
+			t.Fatalf("Grow failed: %v", err)
+		}
+		rec, err := m.Record(i)
+		if err != nil {
+			t.Fatalf("Record(%d) failed: %v", i, err)
+		}
+		binary.LittleEndian.PutUint64(rec, uint64(i*i))
+	}
+	if err := m.Grow(8); err != nil || m.Size() != 800 {
+		t.Errorf("Grow to a smaller size should do nothing: size %d, %v", m.Size(), err)
+	}
+	if err := m.Truncate(80); err != nil || m.NumRecords(8) != 10 {
+		t.Fatalf("Truncate: %d records, %v", m.NumRecords(8), err)
+	}
+	if v, _ := m.Uint64At(9 * 8); v != 81 {
+		t.Errorf("Expected 81, got %d", v)
+	}
+	m.Close()
+
+	if fi, _ := os.Stat(testFile); fi.Size() != 80 {
+		t.Errorf("Expected 80 bytes on disk, got %d", fi.Size())
+	}
+
+	ro, _ := fileiterator.MmapOpen(testFile)
+	defer ro.Close()
+	if err := ro.Grow(1000); err == nil {
+		t.Error("Expected error growing read-only mapping")
+	}
+}
+
+func TestMmapOpenRWWindow(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "window.bin")
+	writeRecordFile(t, testFile, 10000, 100)
+
+	m, err := fileiterator.MmapOpenRW(testFile)
+	if err != nil {
+		t.Fatalf("MmapOpenRW failed: %v", err)
+	}
+	m.Close()
+
+	m, err = fileiterator.MmapOpenWithOptions(testFile, fileiterator.MmapOptions{WindowSize: 64 << 10, Writable: true})
+	if err != nil {
+		t.Fatalf("MmapOpenWithOptions failed: %v", err)
+	}
+	defer m.Close()
+	// Offsets outside the first window remap it
+	if err := m.PutUint32At(999000, 7); err != nil {
+		t.Fatalf("PutUint32At failed: %v", err)
+	}
+	if v, _ := m.Uint32At(100); v != 1 {
+		t.Errorf("Expected 1, got %d", v)
+	}
+	if v, _ := m.Uint32At(999000); v != 7 {
+		t.Errorf("Expected 7, got %d", v)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package fileiterator
+
+import (
+	"fmt"
+
+	"github.com/edsrzf/mmap-go"
+	"golang.org/x/sys/unix"
+)
+
+var madviseFlags = map[MmapAdvice]int{
+	MmapNormal:     unix.MADV_NORMAL,
+	MmapSequential: unix.MADV_SEQUENTIAL,
+	MmapRandom:     unix.MADV_RANDOM,
+	MmapWillNeed:   unix.MADV_WILLNEED,
+	MmapDontNeed:   unix.MADV_DONTNEED,
+}
+
+func madvise(b []byte, advice MmapAdvice) error {
+	flag, ok := madviseFlags[advice]
+	if !ok {
+		return fmt.Errorf("unknown mmap advice %d", advice)
+	}
+	if len(b) == 0 {
+		return nil
+	}
+	return unix.Madvise(b, flag)
+}
+
+// msyncAsync schedules writes of m without waiting for them
+func msyncAsync(m mmap.MMap) error {
+	return unix.Msync(m, unix.MS_ASYNC)
+}
@@ -0,0 +1,218 @@
+package fileiterator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// MmapCreate creates (or truncates) filename with size zero bytes and maps it read-write.
+// Together with the typed accessors this gives an on-disk array that other
+// processes can map and read while it is being written.
+//
+// Example:
+//
+//	m, err := fileiterator.MmapCreate("counters.bin", 8*1000)
+//	if err != nil {
+//	    return err
+//	}
+//	defer m.Close()
+//	if err := m.PutUint64At(8*42, 7); err != nil {
+//	    return err
+//	}
+//	return m.Flush()
+func MmapCreate(filename string, size int64) (*MmapFile, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid mmap size %d", size)
+	}
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to resize file: %w", err)
+	}
+
+	m := &MmapFile{file: file, opts: MmapOptions{Writable: true}, size: size}
+	if err := m.mapRegion(0, 0); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// MmapOpenRW maps an existing file read-write; empty files can be grown with Grow.
+// Use MmapOpenWithOptions with Writable set for record access or windowed mapping.
+func MmapOpenRW(filename string) (*MmapFile, error) {
+	return MmapOpenWithOptions(filename, MmapOptions{Writable: true})
+}
+
+// Grow extends the file to at least size bytes; new bytes are zero.
+// The file is remapped, so slices taken from Data before must not be used afterwards.
+func (m *MmapFile) Grow(size int64) error {
+	if size <= m.size {
+		return nil
+	}
+	return m.Truncate(size)
+}
+
+// Truncate changes the file size to size bytes and remaps it.
+// Slices taken from Data before must not be used afterwards.
+func (m *MmapFile) Truncate(size int64) error {
+	if !m.opts.Writable {
+		return fmt.Errorf("mmap: file is read-only")
+	}
+	if size < 0 {
+		return fmt.Errorf("invalid mmap size %d", size)
+	}
+	if err := m.unmap(); err != nil {
+		return err
+	}
+	if err := m.file.Truncate(size); err != nil {
+		return fmt.Errorf("failed to resize file: %w", err)
+	}
+	m.size = size
+
+	offset := m.off
+	if offset >= size {
+		offset = 0
+	}
+	return m.mapRegion(offset, 0)
+}
+
+// Flush writes changes in the mapped region to disk and waits for completion (msync MS_SYNC)
+func (m *MmapFile) Flush() error {
+	if m.mmap == nil {
+		return nil
+	}
+	if err := m.mmap.Flush(); err != nil {
+		return fmt.Errorf("failed to flush: %w", err)
+	}
+	return nil
+}
+
+// FlushAsync schedules changes in the mapped region to be written to disk
+// without waiting (msync MS_ASYNC). Other processes see writes immediately
+// either way; flushing only matters for durability.
+func (m *MmapFile) FlushAsync() error {
+	if m.mmap == nil {
+		return nil
+	}
+	if err := msyncAsync(m.mmap); err != nil {
+		return fmt.Errorf("failed to flush: %w", err)
+	}
+	return nil
+}
+
+// at returns n bytes at file offset off, moving the window if needed
+func (m *MmapFile) at(off, n int64) ([]byte, error) {
+	if off < 0 || off+n > m.size {
+		return nil, fmt.Errorf("mmap: %d bytes at offset %d out of range [0, %d)", n, off, m.size)
+	}
+	if off < m.off || off+n > m.off+int64(len(m.Data)) {
+		if err := m.mapRegion(off, n); err != nil {
+			return nil, err
+		}
+	}
+	start := off - m.off
+	return m.Data[start : start+n], nil
+}
+
+// writable is at for writes
+func (m *MmapFile) writable(off, n int64) ([]byte, error) {
+	if !m.opts.Writable {
+		return nil, fmt.Errorf("mmap: file is read-only")
+	}
+	return m.at(off, n)
+}
+
+// Uint16At returns the little-endian uint16 at file offset off
+func (m *MmapFile) Uint16At(off int64) (uint16, error) {
+	b, err := m.at(off, 2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(b), nil
+}
+
+// Uint32At returns the little-endian uint32 at file offset off
+func (m *MmapFile) Uint32At(off int64) (uint32, error) {
+	b, err := m.at(off, 4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+// Uint64At returns the little-endian uint64 at file offset off
+func (m *MmapFile) Uint64At(off int64) (uint64, error) {
+	b, err := m.at(off, 8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+// Int16At returns the little-endian int16 at file offset off
+func (m *MmapFile) Int16At(off int64) (int16, error) {
+	v, err := m.Uint16At(off)
+	return int16(v), err
+}
+
+// Int32At returns the little-endian int32 at file offset off
+func (m *MmapFile) Int32At(off int64) (int32, error) {
+	v, err := m.Uint32At(off)
+	return int32(v), err
+}
+
+// Int64At returns the little-endian int64 at file offset off
+func (m *MmapFile) Int64At(off int64) (int64, error) {
+	v, err := m.Uint64At(off)
+	return int64(v), err
+}
+
+// PutUint16At stores v little-endian at file offset off
+func (m *MmapFile) PutUint16At(off int64, v uint16) error {
+	b, err := m.writable(off, 2)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint16(b, v)
+	return nil
+}
+
+// PutUint32At stores v little-endian at file offset off
+func (m *MmapFile) PutUint32At(off int64, v uint32) error {
+	b, err := m.writable(off, 4)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(b, v)
+	return nil
+}
+
+// PutUint64At stores v little-endian at file offset off
+func (m *MmapFile) PutUint64At(off int64, v uint64) error {
+	b, err := m.writable(off, 8)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint64(b, v)
+	return nil
+}
+
+// PutInt16At stores v little-endian at file offset off
+func (m *MmapFile) PutInt16At(off int64, v int16) error {
+	return m.PutUint16At(off, uint16(v))
+}
+
+// PutInt32At stores v little-endian at file offset off
+func (m *MmapFile) PutInt32At(off int64, v int32) error {
+	return m.PutUint32At(off, uint32(v))
+}
+
+// PutInt64At stores v little-endian at file offset off
+func (m *MmapFile) PutInt64At(off int64, v int64) error {
+	return m.PutUint64At(off, uint64(v))
+}
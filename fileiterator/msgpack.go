@@ -2,35 +2,97 @@ package fileiterator
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"math/big"
 	"os"
+	"reflect"
 
 	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
 )
 
+// MsgPackExtDecimal is the MessagePack extension type of Decimal values.
+// Payload: scale (int32, big-endian), sign byte (1 = negative), magnitude (big-endian bytes).
+// time.Time values use the standard timestamp extension (-1).
+const MsgPackExtDecimal int8 = 1
+
+func init() {
+	msgpack.RegisterExtEncoder(MsgPackExtDecimal, Decimal{}, func(e *msgpack.Encoder, v reflect.Value) ([]byte, error) {
+		d := v.Interface().(Decimal)
+		b := binary.BigEndian.AppendUint32(nil, uint32(d.Scale))
+		if d.Unscaled == nil {
+			return append(b, 0), nil
+		}
+		sign := byte(0)
+		if d.Unscaled.Sign() < 0 {
+			sign = 1
+		}
+		return append(append(b, sign), d.Unscaled.Bytes()...), nil
+	})
+	msgpack.RegisterExtDecoder(MsgPackExtDecimal, Decimal{}, func(dec *msgpack.Decoder, v reflect.Value, extLen int) error {
+		if extLen < 5 {
+			return fmt.Errorf("msgpack: invalid decimal length %d", extLen)
+		}
+		b := make([]byte, extLen)
+		if err := dec.ReadFull(b); err != nil {
+			return err
+		}
+		unscaled := new(big.Int).SetBytes(b[5:])
+		if b[4] == 1 {
+			unscaled.Neg(unscaled)
+		}
+		v.Set(reflect.ValueOf(Decimal{Unscaled: unscaled, Scale: int32(binary.BigEndian.Uint32(b))}))
+		return nil
+	})
+}
+
 // SaveMsgPack saves data to a MessagePack file with buffered IO
 // Uses 4MB buffer for maximum performance
 func SaveMsgPack(filename string, data any) error {
-	file, err := os.Create(filename)
+	if err := saveMsgPack(filename, data, CreateOptions{Codec: CodecNone}); err != nil {
+		return err
+	}
+	fmt.Printf("MessagePack saved: %s\n", filename)
+	return nil
+}
+
+// SaveMsgPackWithOptions saves data to a MessagePack file created with opts:
+// compression (opts.Codec, by extension if empty) and atomic writes (opts.Atomic)
+func SaveMsgPackWithOptions(filename string, data any, opts CreateOptions) error {
+	if err := saveMsgPack(filename, data, opts); err != nil {
+		return err
+	}
+	fmt.Printf("MessagePack saved: %s\n", filename)
+	return nil
+}
+
+func saveMsgPack(filename string, data any, opts CreateOptions) error {
+	file, err := Create(filename, opts)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
-	defer file.Close()
 
 	// Use buffered writer (4MB buffer)
 	writer := bufio.NewWriterSize(file, bufferSize)
-	defer writer.Flush()
 
 	encoder := msgpack.NewEncoder(writer)
 	if err := encoder.Encode(data); err != nil {
+		Abort(file)
 		return fmt.Errorf("failed to encode msgpack: %w", err)
 	}
 
 	if err := writer.Flush(); err != nil {
+		Abort(file)
 		return fmt.Errorf("failed to flush buffer: %w", err)
 	}
-
-	fmt.Printf("MessagePack saved: %s\n", filename)
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
 	return nil
 }
 
@@ -60,22 +122,9 @@ func LoadMsgPack(filename string, dest any) error {
 // .gz (gzip), .zst (zstd), .zlib/.zz (zlib), .lz4 (lz4), .br (brotli), .xz (xz)
 // Common usage: filename.msgpack.zst (MessagePack + Zstandard)
 func SaveMsgPackCompressed(filename string, data any) error {
-	writer := FUCreate(filename) // Auto-detects compression from extension
-	defer writer.Close()
-
-	// Use buffered writer for max speed
-	bufWriter := bufio.NewWriterSize(writer, bufferSize)
-	defer bufWriter.Flush()
-
-	encoder := msgpack.NewEncoder(bufWriter)
-	if err := encoder.Encode(data); err != nil {
-		return fmt.Errorf("failed to encode msgpack: %w", err)
-	}
-
-	if err := bufWriter.Flush(); err != nil {
-		return fmt.Errorf("failed to flush buffer: %w", err)
+	if err := saveMsgPack(filename, data, CreateOptions{}); err != nil { // Auto-detects compression from extension
+		return err
 	}
-
 	fmt.Printf("MessagePack saved (compressed): %s\n", filename)
 	return nil
 }
@@ -83,7 +132,10 @@ func SaveMsgPackCompressed(filename string, data any) error {
 // LoadMsgPackCompressed loads data from a compressed MessagePack file
 // Supports all compression formats via FUOpen auto-detection
 func LoadMsgPackCompressed(filename string, dest any) error {
-	reader := FUOpen(filename) // Auto-detects compression
+	reader, err := Open(context.Background(), filename) // Auto-detects compression
+	if err != nil {
+		return err
+	}
 	defer reader.Close()
 
 	// Use buffered reader for max speed
@@ -100,22 +152,25 @@ func LoadMsgPackCompressed(filename string, dest any) error {
 
 // IterateMsgPack iterates over a MessagePack stream file
 // Each record in the file is decoded and passed to the processor
+// A file holding a single top-level array (SaveMsgPack of a slice) is iterated element by element;
+// in a stream of several arrays every array is one record (the first one must be under 1MB)
 // Supports compressed files via FUOpen auto-detection
 func IterateMsgPack(filename string, processor func(any) error) error {
-	reader := FUOpen(filename) // Auto-detects compression
+	reader, err := Open(context.Background(), filename) // Auto-detects compression
+	if err != nil {
+		return err
+	}
 	defer reader.Close()
 
 	// Use buffered reader for max speed
-	bufReader := bufio.NewReaderSize(reader, bufferSize)
-
-	decoder := msgpack.NewDecoder(bufReader)
+	values := newMsgPackValues(bufio.NewReaderSize(reader, bufferSize), true)
 	count := 0
 
 	for {
 		var record any
-		err := decoder.Decode(&record)
+		err := values.next(&record)
 		if err != nil {
-			if err.Error() == "EOF" {
+			if err == io.EOF {
 				break
 			}
 			return fmt.Errorf("line %d: decode error: %w", count+1, err)
@@ -133,22 +188,28 @@ func IterateMsgPack(filename string, processor func(any) error) error {
 
 // IterateMsgPackTyped iterates over a MessagePack stream file with type safety
 // Each record is decoded into type T and passed to the processor
+// A file holding a single top-level array is iterated element by element, unless T is itself
+// a slice or array type (then every top-level array is one record)
 // Supports compressed files via FUOpen auto-detection
 func IterateMsgPackTyped[T any](filename string, processor func(T) error) error {
-	reader := FUOpen(filename) // Auto-detects compression
+	reader, err := Open(context.Background(), filename) // Auto-detects compression
+	if err != nil {
+		return err
+	}
 	defer reader.Close()
 
-	// Use buffered reader for max speed
-	bufReader := bufio.NewReaderSize(reader, bufferSize)
+	kind := reflect.TypeFor[T]().Kind()
+	unwrap := kind != reflect.Slice && kind != reflect.Array
 
-	decoder := msgpack.NewDecoder(bufReader)
+	// Use buffered reader for max speed
+	values := newMsgPackValues(bufio.NewReaderSize(reader, bufferSize), unwrap)
 	count := 0
 
 	for {
 		var record T
-		err := decoder.Decode(&record)
+		err := values.next(&record)
 		if err != nil {
-			if err.Error() == "EOF" {
+			if err == io.EOF {
 				break
 			}
 			return fmt.Errorf("line %d: decode error: %w", count+1, err)
@@ -164,6 +225,206 @@ func IterateMsgPackTyped[T any](filename string, processor func(T) error) error
 	return nil
 }
 
+// msgpackArrayLookahead is the size of the leading elements of a top-level array
+// buffered to find out whether the array is the whole file
+const msgpackArrayLookahead = 1 << 20
+
+// errMsgPackAfterArray is returned when values follow a top-level array too large to buffer
+var errMsgPackAfterArray = errors.New("msgpack: values after a top-level array over 1MB (a stream of large arrays needs IterateMsgPackTyped with a slice type)")
+
+// msgpackValues decodes the records of a MessagePack file: a stream of
+// concatenated values or, with unwrap, the elements of a single top-level array
+type msgpackValues struct {
+	dec       *msgpack.Decoder
+	unwrap    bool
+	started   bool
+	pending   []msgpack.RawMessage // buffered records, returned first
+	remaining int                  // elements left in the top-level array, -1 for a stream
+}
+
+func newMsgPackValues(r io.Reader, unwrap bool) *msgpackValues {
+	return &msgpackValues{dec: msgpack.NewDecoder(r), unwrap: unwrap, remaining: -1}
+}
+
+// next decodes the next record into v and returns io.EOF after the last one
+func (m *msgpackValues) next(v any) error {
+	if !m.started {
+		m.started = true
+		if m.unwrap {
+			if err := m.start(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(m.pending) > 0 {
+		raw := m.pending[0]
+		m.pending = m.pending[1:]
+		return msgpack.Unmarshal(raw, v)
+	}
+	if m.remaining == 0 {
+		// the array was taken for the whole file
+		if _, err := m.dec.PeekCode(); err != io.EOF {
+			if err == nil {
+				err = errMsgPackAfterArray
+			}
+			return err
+		}
+		return io.EOF
+	}
+	if m.remaining > 0 {
+		m.remaining--
+	}
+	return m.dec.Decode(v)
+}
+
+// start reads ahead into a leading top-level array. An array that ends within
+// msgpackArrayLookahead bytes is unwrapped only when it is the whole file, otherwise
+// it is the first record of a stream (concatenated arrays are one record each).
+// A larger array is taken for the whole file and its elements are streamed;
+// values after it are reported as errMsgPackAfterArray.
+func (m *msgpackValues) start() error {
+	code, err := m.dec.PeekCode()
+	if err != nil {
+		return err
+	}
+	if !msgpcode.IsFixedArray(code) && code != msgpcode.Array16 && code != msgpcode.Array32 {
+		return nil
+	}
+	n, err := m.dec.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+	size := 0
+	for i := range n {
+		if size >= msgpackArrayLookahead {
+			m.remaining = n - i
+			return nil
+		}
+		var raw msgpack.RawMessage
+		if err := m.dec.Decode(&raw); err != nil {
+			return err
+		}
+		m.pending = append(m.pending, raw)
+		size += len(raw)
+	}
+
+	if _, err := m.dec.PeekCode(); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		// more values follow - the array is one record of a stream
+		var array bytes.Buffer
+		if err := msgpack.NewEncoder(&array).EncodeArrayLen(n); err != nil {
+			return err
+		}
+		for _, raw := range m.pending {
+			array.Write(raw)
+		}
+		m.pending = []msgpack.RawMessage{array.Bytes()}
+	}
+	return nil
+}
+
+// MsgPackWriter writes a stream of MessagePack values one at a time -
+// the format read by IterateMsgPack and OpenRecordReader.
+//
+// Example:
+//
+//	w, err := fileiterator.CreateMsgPackWriter("events.msgpack.zst")
+//	if err != nil {
+//	    return err
+//	}
+//	for _, event := range events {
+//	    if err := w.Write(event); err != nil {
+//	        w.Close()
+//	        return err
+//	    }
+//	}
+//	return w.Close()
+type MsgPackWriter struct {
+	out     io.Closer // closed by Close, nil for NewMsgPackWriter
+	buf     *bufio.Writer
+	encoder *msgpack.Encoder
+	count   int
+	closed  bool
+}
+
+// CreateMsgPackWriter creates filename for writing a MessagePack stream;
+// compression is selected by extension (.msgpack.zst, .msgpack.gz, ...)
+func CreateMsgPackWriter(filename string) (*MsgPackWriter, error) {
+	return CreateMsgPackWriterWithOptions(filename, CreateOptions{})
+}
+
+// CreateMsgPackWriterWithOptions is CreateMsgPackWriter with explicit compression
+// or atomic writes (see CreateOptions)
+func CreateMsgPackWriterWithOptions(filename string, opts CreateOptions) (*MsgPackWriter, error) {
+	out, err := Create(filename, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	w := NewMsgPackWriter(out)
+	w.out = out
+	return w, nil
+}
+
+// NewMsgPackWriter writes a MessagePack stream to w; Close flushes but does not close w
+func NewMsgPackWriter(w io.Writer) *MsgPackWriter {
+	buf := bufio.NewWriterSize(w, bufferSize)
+	return &MsgPackWriter{buf: buf, encoder: msgpack.NewEncoder(buf)}
+}
+
+// Write encodes one value
+func (w *MsgPackWriter) Write(v any) error {
+	if w.closed {
+		return errors.New("write to closed MsgPackWriter")
+	}
+	if err := w.encoder.Encode(v); err != nil {
+		return fmt.Errorf("record %d: failed to encode msgpack: %w", w.count+1, err)
+	}
+	w.count++
+	return nil
+}
+
+// Count returns the number of values written
+func (w *MsgPackWriter) Count() int {
+	return w.count
+}
+
+// Close flushes buffered data and closes the file
+func (w *MsgPackWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	err := w.buf.Flush()
+	if err != nil {
+		err = fmt.Errorf("failed to flush buffer: %w", err)
+		if w.out != nil {
+			Abort(w.out)
+		}
+		return err
+	}
+	if w.out != nil {
+		err = w.out.Close()
+	}
+	return err
+}
+
+// Abort closes the writer without finishing the file; in atomic mode
+// (see CreateOptions.Atomic) the destination is left untouched
+func (w *MsgPackWriter) Abort() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.out != nil {
+		return Abort(w.out)
+	}
+	return nil
+}
+
 // SaveMsgPackMap saves a map to MessagePack file with buffered IO
 func SaveMsgPackMap(filename string, data map[string]any) error {
 	return SaveMsgPack(filename, data)
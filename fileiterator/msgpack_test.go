@@ -2,9 +2,12 @@ package fileiterator_test
 
 import (
 	"compress/gzip"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/klauspost/compress/zstd"
 	"github.com/parf/homebase-go-lib/fileiterator"
@@ -277,3 +280,204 @@ func TestIterateMsgPackCompressed(t *testing.T) {
 		t.Errorf("Expected %d records, got %d", len(records), count)
 	}
 }
+
+func TestIterateMsgPackTopLevelArray(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "array.msgpack")
+
+	type User struct {
+		ID   int    `msgpack:"id"`
+		Name string `msgpack:"name"`
+	}
+	users := []User{{ID: 1, Name: "Alice"}, {ID: 2, Name: "Bob"}}
+	if err := fileiterator.SaveMsgPack(testFile, users); err != nil {
+		t.Fatalf("Failed to save MessagePack: %v", err)
+	}
+
+	count := 0
+	err := fileiterator.IterateMsgPack(testFile, func(record any) error {
+		if _, ok := record.(map[string]any); !ok {
+			t.Errorf("Expected map element, got %T", record)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to iterate MessagePack array: %v", err)
+	}
+	if count != len(users) {
+		t.Errorf("Expected %d records, got %d", len(users), count)
+	}
+
+	var got []User
+	err = fileiterator.IterateMsgPackTyped(testFile, func(user User) error {
+		got = append(got, user)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to iterate typed MessagePack array: %v", err)
+	}
+	if len(got) != 2 || got[1].Name != "Bob" {
+		t.Errorf("Unexpected users: %+v", got)
+	}
+
+	// A slice type reads the whole array as one record
+	rows := 0
+	err = fileiterator.IterateMsgPackTyped(testFile, func(all []User) error {
+		rows++
+		if len(all) != len(users) {
+			t.Errorf("Expected %d users, got %d", len(users), len(all))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to iterate MessagePack as slices: %v", err)
+	}
+	if rows != 1 {
+		t.Errorf("Expected 1 record, got %d", rows)
+	}
+}
+
+func TestMsgPackWriter(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "stream.msgpack.zst")
+
+	created := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	price, _ := fileiterator.ParseDecimal("-19.99")
+
+	w, err := fileiterator.CreateMsgPackWriter(testFile)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	for i := 1; i <= 3; i++ {
+		if err := w.Write(map[string]any{"id": i, "created": created, "price": price}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if w.Count() != 3 {
+		t.Errorf("Expected count 3, got %d", w.Count())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := w.Write(1); err == nil {
+		t.Error("Expected error writing to closed writer")
+	}
+
+	count := 0
+	err = fileiterator.IterateMsgPack(testFile, func(record any) error {
+		m := record.(map[string]any)
+		if ts, ok := m["created"].(time.Time); !ok || !ts.Equal(created) {
+			t.Errorf("created = %v (%T), want %v", m["created"], m["created"], created)
+		}
+		if d, ok := m["price"].(fileiterator.Decimal); !ok || d.String() != "-19.99" {
+			t.Errorf("price = %v (%T), want -19.99", m["price"], m["price"])
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to iterate MessagePack stream: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 records, got %d", count)
+	}
+}
+
+func TestIterateMsgPackValuesAfterArray(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "arrays.msgpack")
+
+	w, err := fileiterator.CreateMsgPackWriter(testFile)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	w.Write([]int{1, 2})
+	w.Write([]int{3})
+	w.Close()
+
+	// Concatenated arrays are a stream - one record per array
+	var records []any
+	err = fileiterator.IterateMsgPack(testFile, func(record any) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to iterate a stream of arrays: %v", err)
+	}
+	if len(records) != 2 || len(records[0].([]any)) != 2 || len(records[1].([]any)) != 1 {
+		t.Errorf("Expected 2 array records, got %v", records)
+	}
+
+	// An array followed by maps: the maps are records, the array is skipped by record readers
+	mixedFile := filepath.Join(tmpDir, "mixed.msgpack")
+	w, err = fileiterator.CreateMsgPackWriter(mixedFile)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	w.Write([]map[string]any{{"id": 1}})
+	w.Write(map[string]any{"id": 2})
+	w.Close()
+	reader, err := fileiterator.OpenRecordReader(mixedFile)
+	if err != nil {
+		t.Fatalf("OpenRecordReader failed: %v", err)
+	}
+	defer reader.Close()
+	if rec, err := reader.Read(); err != nil || rec["id"] != int8(2) {
+		t.Errorf("Read = %v, %v; want the map after the array", rec, err)
+	}
+	if _, err := reader.Read(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+
+	rows := 0
+	err = fileiterator.IterateMsgPackTyped(testFile, func(v []int) error {
+		rows++
+		return nil
+	})
+	if err != nil || rows != 2 {
+		t.Errorf("Expected 2 array records, got %d (err %v)", rows, err)
+	}
+}
+
+func TestIterateMsgPackLargeTopLevelArray(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "large.msgpack")
+
+	// ~3MB array - past the read-ahead, so elements are streamed
+	records := make([]map[string]any, 50000)
+	for i := range records {
+		records[i] = map[string]any{"id": i, "payload": strings.Repeat("x", 50)}
+	}
+	if err := fileiterator.SaveMsgPack(testFile, records); err != nil {
+		t.Fatalf("Failed to save MessagePack: %v", err)
+	}
+	count := 0
+	err := fileiterator.IterateMsgPack(testFile, func(any) error { count++; return nil })
+	if err != nil || count != len(records) {
+		t.Fatalf("Expected %d records, got %d (err %v)", len(records), count, err)
+	}
+
+	// Truncated: the leading elements arrive before the error, the array is not buffered
+	data, _ := os.ReadFile(testFile)
+	os.WriteFile(testFile, data[:len(data)-10], 0644)
+	count = 0
+	err = fileiterator.IterateMsgPack(testFile, func(any) error { count++; return nil })
+	if err == nil || count < len(records)-1 {
+		t.Errorf("Expected an error after %d records, got %d (err %v)", len(records)-1, count, err)
+	}
+
+	// Values after a large array cannot make it one record
+	w, err := fileiterator.CreateMsgPackWriter(testFile)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	w.Write(records)
+	w.Write(map[string]any{"id": -1})
+	w.Close()
+	count = 0
+	err = fileiterator.IterateMsgPack(testFile, func(any) error { count++; return nil })
+	if err == nil || !strings.Contains(err.Error(), "top-level array over 1MB") || count != len(records) {
+		t.Errorf("Expected error after %d records, got %d (err %v)", len(records), count, err)
+	}
+}
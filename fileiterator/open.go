@@ -0,0 +1,489 @@
+package fileiterator
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// Compression codecs understood by Open and Create
+const (
+	CodecNone   = "none"
+	CodecGzip   = "gzip"
+	CodecZstd   = "zstd"
+	CodecZlib   = "zlib"
+	CodecLz4    = "lz4"
+	CodecBrotli = "brotli"
+	CodecXz     = "xz"
+	CodecBzip2  = "bzip2" // read-only
+)
+
+// codecFromSuffix maps a file extension to a codec:
+// .gz (gzip), .zst/.zst1/.zst2 (zstd), .zlib/.zz (zlib), .lz4 (lz4), .br (brotli), .xz (xz), .bz2 (bzip2)
+func codecFromSuffix(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		return CodecGzip
+	case strings.HasSuffix(name, ".zst") || strings.HasSuffix(name, ".zst1") || strings.HasSuffix(name, ".zst2"):
+		return CodecZstd
+	case strings.HasSuffix(name, ".zlib") || strings.HasSuffix(name, ".zz"):
+		return CodecZlib
+	case strings.HasSuffix(name, ".lz4"):
+		return CodecLz4
+	case strings.HasSuffix(name, ".br"):
+		return CodecBrotli
+	case strings.HasSuffix(name, ".xz"):
+		return CodecXz
+	case strings.HasSuffix(name, ".bz2"):
+		return CodecBzip2
+	}
+	return CodecNone
+}
+
+// isURL reports whether path is an http(s) URL rather than a local file (httpd.log is a file)
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// sourceName strips query string and fragment from URLs,
+// so "http://host/data.gz?token=x" is detected by its path
+func sourceName(path string) string {
+	if !isURL(path) {
+		return path
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return path
+	}
+	return u.Path
+}
+
+// magicLen is the number of leading bytes needed by sniffCodec
+const magicLen = 6
+
+// sniffCodec detects a codec by magic bytes; returns "" if none matched.
+// Brotli has no magic number and is never sniffed.
+func sniffCodec(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return CodecGzip
+	case bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return CodecZstd
+	case bytes.HasPrefix(head, []byte{0x04, 0x22, 0x4d, 0x18}):
+		return CodecLz4
+	case bytes.HasPrefix(head, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return CodecXz
+	case len(head) >= 4 && bytes.HasPrefix(head, []byte("BZh")) && head[3] >= '1' && head[3] <= '9':
+		return CodecBzip2
+	case isZlibHeader(head):
+		return CodecZlib
+	}
+	return ""
+}
+
+// isZlibHeader checks for a zlib header written at level 1, default or best (78 01, 78 9c, 78 da).
+// The generic check (header%31 == 0) matches too much plain text - "x^" is a valid header,
+// so files compressed at levels 2-5 are detected by extension only.
+func isZlibHeader(head []byte) bool {
+	if len(head) < 2 || head[0] != 0x78 {
+		return false
+	}
+	switch head[1] {
+	case 0x01, 0x9c, 0xda:
+		return true
+	}
+	return false
+}
+
+// OpenOptions configures OpenWithOptions
+type OpenOptions struct {
+	// Codec forces a decompressor; CodecNone reads raw bytes.
+	// "" - detect by magic bytes, falling back to the file extension.
+	Codec string
+
+	// HTTP configures http(s) sources; nil - DefaultHTTPOptions()
+	HTTP *HTTPOptions
+
+	// Concurrency is the number of parallel decoders for zstd, lz4 (block-independent frames)
+	// and gzip written as BGZF members (see CreateOptions.Concurrency).
+	// 0 - package default (SetDecodeConcurrency), 1 - sequential, < 0 - GOMAXPROCS
+	Concurrency int
+}
+
+var decodeConcurrency atomic.Int64
+
+// SetDecodeConcurrency sets the number of parallel decoders used when OpenOptions.Concurrency is 0,
+// so FUOpen, IterateLines, IterateJSONL and the other path-based functions decode in parallel.
+// 0 (default) - codec defaults (sequential gzip/lz4, zstd up to 4 inflight blocks), < 0 - GOMAXPROCS
+func SetDecodeConcurrency(n int) {
+	decodeConcurrency.Store(int64(n))
+}
+
+// workers resolves the effective decoder concurrency; 0 - codec defaults
+func (o OpenOptions) workers() int {
+	n := o.Concurrency
+	if n == 0 {
+		n = int(decodeConcurrency.Load())
+	}
+	if n < 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	return n
+}
+
+// Open opens a file, URL or "-" (stdin) and returns a decompressing io.ReadCloser.
+// This is the error-returning version of FUOpen.
+//
+// Compression is detected by magic bytes (gzip, zstd, lz4 frame, xz, zlib, bzip2);
+// when the content has no known magic the file extension decides (this is how brotli is detected).
+// A magic number wins over a contradicting extension. ReaderCodec reports the chosen codec.
+//
+// Errors:
+//   - missing file or HTTP 404/410 - errors.Is(err, ErrNotFound)
+//   - other HTTP status codes     - *ErrHTTPStatus
+//   - bad compressed header       - *ErrCorruptStream
+//
+// Decoding errors returned later by Read are reported as *ErrCorruptStream as well.
+func Open(ctx context.Context, path string) (io.ReadCloser, error) {
+	return OpenWithOptions(ctx, path, OpenOptions{})
+}
+
+// OpenWithOptions is Open with an explicit codec override and HTTP settings
+// (headers, auth, timeouts, retries).
+// HTTP downloads are resumed with Range requests when the connection drops.
+func OpenWithOptions(ctx context.Context, path string, opts OpenOptions) (io.ReadCloser, error) {
+	base, err := openSource(ctx, path, opts.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	codec := opts.Codec
+	if codec == "" {
+		base, codec = detectCodec(base, sourceName(path))
+	}
+	return newDecompressor(base, codec, opts.workers())
+}
+
+// ReaderCodec returns the codec chosen for a reader returned by Open / FUOpen,
+// or "" if r did not come from them
+func ReaderCodec(r io.Reader) string {
+	if c, ok := r.(interface{ Codec() string }); ok {
+		return c.Codec()
+	}
+	return ""
+}
+
+// detectCodec peeks at the first bytes of base and picks a codec.
+// zlib has a weak magic, so it only applies when the extension is unknown or says zlib.
+func detectCodec(base io.ReadCloser, name string) (io.ReadCloser, string) {
+	br := bufio.NewReader(base)
+	head, _ := br.Peek(magicLen) // short or failed reads surface on the first Read
+	return &readCloser{Reader: br, Closer: base}, chooseCodec(head, name)
+}
+
+// chooseCodec picks a codec from the leading bytes, falling back to the extension of name
+func chooseCodec(head []byte, name string) string {
+	suffix := codecFromSuffix(name)
+	sniffed := sniffCodec(head)
+	switch {
+	case sniffed == "":
+		return suffix
+	case sniffed == CodecZlib && suffix != CodecNone:
+		return suffix
+	}
+	return sniffed
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// openSource opens a local file, "-" (stdin) or an http(s) URL without decompression
+func openSource(ctx context.Context, path string, httpOpts *HTTPOptions) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	if isURL(path) {
+		opts := DefaultHTTPOptions()
+		if httpOpts != nil {
+			opts = *httpOpts
+		}
+		return openHTTP(ctx, path, opts)
+	}
+	return os.Open(path)
+}
+
+// newDecompressor wraps base with a decoder for codec using `workers` parallel decoders
+// (0 - codec default). base is closed on error.
+func newDecompressor(base io.ReadCloser, codec string, workers int) (io.ReadCloser, error) {
+	if codec == CodecNone {
+		return &plainReader{ReadCloser: base}, nil
+	}
+
+	src := &sourceReader{Reader: base}
+	dr := &decodingReader{src: src, codec: codec}
+
+	switch codec {
+	case CodecGzip:
+		gz, err := newGzipReader(src, workers)
+		if err != nil {
+			base.Close()
+			return nil, dr.wrap(err)
+		}
+		dr.Reader = gz
+		dr.closers = []io.Closer{gz}
+	case CodecZstd:
+		// All zstd levels use the same decoder
+		var zopts []zstd.DOption
+		if workers > 0 {
+			zopts = append(zopts, zstd.WithDecoderConcurrency(workers))
+		}
+		zr, err := zstd.NewReader(src, zopts...)
+		if err != nil {
+			base.Close()
+			return nil, dr.wrap(err)
+		}
+		dr.Reader = zr
+		dr.closers = []io.Closer{closerFunc(func() error { zr.Close(); return nil })}
+	case CodecZlib:
+		zr, err := zlib.NewReader(src)
+		if err != nil {
+			base.Close()
+			return nil, dr.wrap(err)
+		}
+		dr.Reader = zr
+		dr.closers = []io.Closer{zr}
+	case CodecLz4:
+		lzr := lz4.NewReader(src)
+		if workers > 1 {
+			// Silently sequential for frames with dependent blocks
+			if err := lzr.Apply(lz4.ConcurrencyOption(workers)); err != nil {
+				base.Close()
+				return nil, err
+			}
+		}
+		dr.Reader = lzr
+	case CodecBrotli:
+		dr.Reader = brotli.NewReader(src)
+	case CodecXz:
+		xzr, err := xz.NewReader(src)
+		if err != nil {
+			base.Close()
+			return nil, dr.wrap(err)
+		}
+		dr.Reader = xzr
+	case CodecBzip2:
+		dr.Reader = bzip2.NewReader(src)
+	default:
+		base.Close()
+		return nil, &ErrCorruptStream{Codec: codec, Err: errUnknownCodec}
+	}
+
+	dr.closers = append(dr.closers, base)
+	return dr, nil
+}
+
+var (
+	errUnknownCodec  = errors.New("unknown codec")
+	errBzip2ReadOnly = errors.New("bzip2 is supported for reading only")
+)
+
+// plainReader is an uncompressed source returned by Open
+type plainReader struct {
+	io.ReadCloser
+}
+
+func (p *plainReader) Codec() string { return CodecNone }
+
+// sourceReader remembers the last error of the underlying (compressed) source,
+// so decoder errors can be told apart from I/O errors
+type sourceReader struct {
+	io.Reader
+	err error
+}
+
+func (s *sourceReader) Read(p []byte) (int, error) {
+	n, err := s.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		s.err = err
+	}
+	return n, err
+}
+
+// decodingReader reports decoder failures as *ErrCorruptStream
+// and closes the decoder and the source on Close
+type decodingReader struct {
+	io.Reader
+	src     *sourceReader
+	codec   string
+	closers []io.Closer
+}
+
+func (d *decodingReader) Read(p []byte) (int, error) {
+	n, err := d.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		err = d.wrap(err)
+	}
+	return n, err
+}
+
+func (d *decodingReader) wrap(err error) error {
+	if d.src.err != nil && err == d.src.err {
+		return err // I/O error of the source, not a decoding problem
+	}
+	return &ErrCorruptStream{Codec: d.codec, Err: err}
+}
+
+func (d *decodingReader) Codec() string { return d.codec }
+
+func (d *decodingReader) Close() error {
+	var firstErr error
+	for _, closer := range d.closers {
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+// CreateOptions configures Create
+type CreateOptions struct {
+	Codec string      // Compression codec; "" - detect by extension, CodecNone - no compression
+	Perm  os.FileMode // File permissions (before umask); 0 - 0666
+
+	// Concurrency is the number of parallel encoders; 0 or 1 - sequential, < 0 - GOMAXPROCS.
+	// gzip is then written as BGZF members (bgzip compatible) that Open can decode in parallel.
+	Concurrency int
+
+	// Seekable writes zstd as seekable zstd: independent 1MB frames plus a seek table.
+	// Any zstd decoder reads it; checkpointed iterations resume without decoding from the start.
+	Seekable bool
+
+	// Atomic writes to "path.tmp.<pid>.<n>" and renames it over path on a successful Close;
+	// Abort removes it instead. Create then returns an *AtomicWriter.
+	Atomic bool
+}
+
+// Create creates a file and returns a compressing io.WriteCloser.
+// This is the error-returning version of FUCreate; compression is detected by extension
+// unless opts.Codec is set.
+func Create(path string, opts CreateOptions) (io.WriteCloser, error) {
+	perm := opts.Perm
+	if perm == 0 {
+		perm = 0666
+	}
+	var file io.WriteCloser
+	var tmp *atomicFile
+	var err error
+	if opts.Atomic {
+		tmp, err = createAtomic(path, perm)
+		file = tmp
+	} else {
+		file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	codec := opts.Codec
+	if codec == "" {
+		codec = codecFromSuffix(path)
+	}
+
+	// Zstd level by extension: .zst1 - fastest, .zst2/.zst - default (3)
+	level := zstd.SpeedDefault
+	if strings.HasSuffix(path, ".zst1") {
+		level = zstd.SpeedFastest
+	}
+
+	workers := opts.Concurrency
+	if workers < 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	var w io.WriteCloser
+	if opts.Seekable && codec == CodecZstd {
+		w, err = newSeekableZstdWriter(file, level)
+	} else {
+		w, err = newCompressor(file, codec, level, workers)
+	}
+	if err != nil {
+		file.Close()
+		if tmp != nil {
+			os.Remove(tmp.Name())
+		}
+		return nil, err
+	}
+	if tmp != nil {
+		return &AtomicWriter{WriteCloser: w, file: tmp, path: path}, nil
+	}
+	return w, nil
+}
+
+// newCompressor wraps file with an encoder for codec using `workers` parallel encoders (0/1 - sequential).
+// Closing the returned writer closes the encoder, then file.
+func newCompressor(file io.WriteCloser, codec string, level zstd.EncoderLevel, workers int) (io.WriteCloser, error) {
+	switch codec {
+	case CodecNone, "":
+		return file, nil
+	case CodecGzip:
+		if workers > 1 {
+			return newParallelGzipWriter(file, workers, gzip.DefaultCompression), nil
+		}
+		gzw := gzip.NewWriter(file)
+		return &combinedWriteCloser{Writer: gzw, closers: []io.Closer{gzw, file}}, nil
+	case CodecZstd:
+		zopts := []zstd.EOption{zstd.WithEncoderLevel(level)}
+		if workers > 1 {
+			zopts = append(zopts, zstd.WithEncoderConcurrency(workers))
+		}
+		zw, err := zstd.NewWriter(file, zopts...)
+		if err != nil {
+			return nil, err
+		}
+		return &zstdWriteCloser{encoder: zw, base: file}, nil
+	case CodecZlib:
+		zlibw := zlib.NewWriter(file)
+		return &combinedWriteCloser{Writer: zlibw, closers: []io.Closer{zlibw, file}}, nil
+	case CodecLz4:
+		lzw := lz4.NewWriter(file)
+		if workers > 1 {
+			if err := lzw.Apply(lz4.ConcurrencyOption(workers)); err != nil {
+				return nil, err
+			}
+		}
+		return &simpleWriteCloser{Writer: lzw, base: file}, nil
+	case CodecBrotli:
+		brw := brotli.NewWriter(file)
+		return &simpleWriteCloser{Writer: brw, base: file}, nil
+	case CodecXz:
+		xzw, err := xz.NewWriter(file)
+		if err != nil {
+			return nil, err
+		}
+		return &simpleWriteCloser{Writer: xzw, base: file}, nil
+	case CodecBzip2:
+		return nil, errBzip2ReadOnly
+	default:
+		return nil, errUnknownCodec
+	}
+}
@@ -0,0 +1,318 @@
+package fileiterator_test
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parf/homebase-go-lib/fileiterator"
+)
+
+func TestOpenNotFound(t *testing.T) {
+	_, err := fileiterator.Open(context.Background(), filepath.Join(t.TempDir(), "missing.txt"))
+	if !errors.Is(err, fileiterator.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestOpenHTTPStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	_, err := fileiterator.Open(context.Background(), srv.URL+"/missing")
+	if !errors.Is(err, fileiterator.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for 404, got %v", err)
+	}
+
+	_, err = fileiterator.Open(context.Background(), srv.URL+"/broken")
+	var statusErr *fileiterator.ErrHTTPStatus
+	if !errors.As(err, &statusErr) || statusErr.Code != 500 {
+		t.Errorf("Expected ErrHTTPStatus{500}, got %v", err)
+	}
+	if errors.Is(err, fileiterator.ErrNotFound) {
+		t.Error("500 must not match ErrNotFound")
+	}
+}
+
+func TestOpenCorruptStream(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "bad.gz")
+	os.WriteFile(testFile, []byte("this is not gzip"), 0644)
+
+	_, err := fileiterator.Open(context.Background(), testFile)
+	var corrupt *fileiterator.ErrCorruptStream
+	if !errors.As(err, &corrupt) || corrupt.Codec != fileiterator.CodecGzip {
+		t.Errorf("Expected ErrCorruptStream{gzip}, got %v", err)
+	}
+}
+
+func TestOpenTruncatedStream(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "data.txt.zst")
+	w := fileiterator.FUCreate(testFile)
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(w, "line %d with some payload\n", i)
+	}
+	w.Close()
+
+	data, _ := os.ReadFile(testFile)
+	os.WriteFile(testFile, data[:len(data)/2], 0644)
+
+	r, err := fileiterator.Open(context.Background(), testFile)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+
+	_, err = io.ReadAll(r)
+	var corrupt *fileiterator.ErrCorruptStream
+	if !errors.As(err, &corrupt) || corrupt.Codec != fileiterator.CodecZstd {
+		t.Errorf("Expected ErrCorruptStream{zstd}, got %v", err)
+	}
+}
+
+func TestCreateExplicitCodec(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "data.bin")
+
+	w, err := fileiterator.Create(testFile, fileiterator.CreateOptions{Codec: fileiterator.CodecGzip})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	w.Write([]byte("hello"))
+	w.Close()
+
+	raw, _ := os.ReadFile(testFile)
+	if len(raw) < 2 || raw[0] != 0x1f || raw[1] != 0x8b {
+		t.Errorf("Expected gzip header, got %x", raw[:2])
+	}
+
+	if _, err := fileiterator.Create(filepath.Join(t.TempDir(), "no", "such", "dir.txt"), fileiterator.CreateOptions{}); !errors.Is(err, fileiterator.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for missing directory, got %v", err)
+	}
+}
+
+func TestFUOpenPanicsOnMissingFile(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected FUOpen to panic")
+		}
+	}()
+	fileiterator.FUOpen(filepath.Join(t.TempDir(), "missing.txt"))
+}
+
+func TestIterateLinesE(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "lines.txt.gz")
+	w := fileiterator.FUCreate(testFile)
+	w.Write([]byte("a\nb\nc\n"))
+	w.Close()
+
+	stop := errors.New("stop")
+	err := fileiterator.IterateLinesE(testFile, func(line string) error {
+		if line == "b" {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("Expected processor error, got %v", err)
+	}
+
+	err = fileiterator.IterateLinesE(filepath.Join(t.TempDir(), "missing.txt"), func(string) error { return nil })
+	if !errors.Is(err, fileiterator.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestIterateIDTabFileEMalformed(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "ids.tsv")
+	os.WriteFile(testFile, []byte("1a\tAlice\nno-tab-here\n"), 0644)
+
+	count := 0
+	err := fileiterator.IterateIDTabFileE(testFile, func(id int32, name string) error {
+		count++
+		return nil
+	})
+	if err == nil {
+		t.Fatal("Expected error for malformed line")
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 processed line, got %d", count)
+	}
+}
+
+func TestIterateBinaryRecordsETruncated(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "records.bin")
+	os.WriteFile(testFile, make([]byte, 10), 0644)
+
+	count := 0
+	err := fileiterator.IterateBinaryRecordsE(testFile, 4, func([]byte) error {
+		count++
+		return nil
+	})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 records, got %d", count)
+	}
+}
+
+func TestLoadBinFileE(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "data.bin.xz")
+	w := fileiterator.FUCreate(testFile)
+	w.Write([]byte("payload"))
+	w.Close()
+
+	data, err := fileiterator.LoadBinFileE(testFile)
+	if err != nil {
+		t.Fatalf("LoadBinFileE failed: %v", err)
+	}
+	if string(data) != "payload" {
+		t.Errorf("Expected payload, got %q", data)
+	}
+}
+
+func TestOpenDetectsCodecByMagic(t *testing.T) {
+	tmpDir := t.TempDir()
+	codecs := []string{
+		fileiterator.CodecGzip,
+		fileiterator.CodecZstd,
+		fileiterator.CodecZlib,
+		fileiterator.CodecLz4,
+		fileiterator.CodecXz,
+	}
+
+	for _, codec := range codecs {
+		t.Run(codec, func(t *testing.T) {
+			// Unknown / contradicting suffixes must not matter
+			names := []string{"data.dat", "data.br"}
+			if codec == fileiterator.CodecZlib {
+				names = names[:1] // weak magic does not override an extension
+			}
+			for _, name := range names {
+				testFile := filepath.Join(tmpDir, codec+"-"+name)
+				w, err := fileiterator.Create(testFile, fileiterator.CreateOptions{Codec: codec})
+				if err != nil {
+					t.Fatalf("Create failed: %v", err)
+				}
+				w.Write([]byte("hello " + codec))
+				w.Close()
+
+				r, err := fileiterator.Open(context.Background(), testFile)
+				if err != nil {
+					t.Fatalf("Open %s failed: %v", name, err)
+				}
+				data, err := io.ReadAll(r)
+				r.Close()
+				if err != nil {
+					t.Fatalf("Read %s failed: %v", name, err)
+				}
+				if string(data) != "hello "+codec {
+					t.Errorf("%s: got %q", name, data)
+				}
+				if got := fileiterator.ReaderCodec(r); got != codec {
+					t.Errorf("%s: expected codec %s, got %s", name, codec, got)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenBzip2(t *testing.T) {
+	bz, _ := hex.DecodeString("425a6839314159265359ab6ba1f1000002d9800010400010001264c01020003100d34d04001ea3ef4e51a2078bb9229c284855b5d0f880")
+	testFile := filepath.Join(t.TempDir(), "data.bin")
+	os.WriteFile(testFile, bz, 0644)
+
+	r, err := fileiterator.Open(context.Background(), testFile)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+	data, _ := io.ReadAll(r)
+	if string(data) != "hello bzip2\n" {
+		t.Errorf("Expected decoded bzip2, got %q", data)
+	}
+	if fileiterator.ReaderCodec(r) != fileiterator.CodecBzip2 {
+		t.Errorf("Expected bzip2, got %s", fileiterator.ReaderCodec(r))
+	}
+
+	if _, err := fileiterator.Create(filepath.Join(t.TempDir(), "out.bz2"), fileiterator.CreateOptions{}); err == nil {
+		t.Error("Expected error creating bzip2 file")
+	}
+}
+
+func TestOpenFallsBackToSuffix(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	plain := filepath.Join(tmpDir, "plain.txt")
+	os.WriteFile(plain, []byte("x^ looks like zlib"), 0644)
+	r, _ := fileiterator.Open(context.Background(), plain)
+	if fileiterator.ReaderCodec(r) != fileiterator.CodecNone {
+		t.Errorf("Expected plain text, got %s", fileiterator.ReaderCodec(r))
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "x^ looks like zlib" {
+		t.Errorf("Unexpected content %q", data)
+	}
+
+	// Brotli has no magic - detected by extension
+	br := filepath.Join(tmpDir, "data.br")
+	w := fileiterator.FUCreate(br)
+	w.Write([]byte("brotli data"))
+	w.Close()
+	r = fileiterator.FUOpen(br)
+	data, _ = io.ReadAll(r)
+	r.Close()
+	if string(data) != "brotli data" || fileiterator.ReaderCodec(r) != fileiterator.CodecBrotli {
+		t.Errorf("Expected brotli, got %s %q", fileiterator.ReaderCodec(r), data)
+	}
+}
+
+func TestOpenWithOptionsOverride(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "data.gz")
+	w := fileiterator.FUCreate(testFile)
+	w.Write([]byte("compressed"))
+	w.Close()
+
+	r, err := fileiterator.OpenWithOptions(context.Background(), testFile, fileiterator.OpenOptions{Codec: fileiterator.CodecNone})
+	if err != nil {
+		t.Fatalf("OpenWithOptions failed: %v", err)
+	}
+	defer r.Close()
+	raw, _ := io.ReadAll(r)
+	if len(raw) < 2 || raw[0] != 0x1f || raw[1] != 0x8b {
+		t.Errorf("Expected raw gzip bytes, got %q", raw)
+	}
+}
+
+func TestOpenURLWithQuery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte("from url"))
+		gz.Close()
+	}))
+	defer srv.Close()
+
+	r, err := fileiterator.Open(context.Background(), srv.URL+"/export?token=abc")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+	data, _ := io.ReadAll(r)
+	if string(data) != "from url" {
+		t.Errorf("Expected decompressed body, got %q", data)
+	}
+}
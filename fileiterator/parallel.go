@@ -0,0 +1,511 @@
+package fileiterator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// Parallel iterators read the input on one goroutine, hand batches of lines / CSV records
+// to `workers` goroutines for parsing and report the first error with its line / row number.
+//
+// ordered = false - the processor is called concurrently from the workers and must be goroutine-safe.
+// ordered = true  - parsing is parallel, the processor is called from a single goroutine in input order.
+//
+// workers <= 0 uses GOMAXPROCS.
+
+const (
+	parallelBatchLines = 256     // lines / records per batch
+	parallelBatchBytes = 1 << 20 // max batch size in bytes
+)
+
+// numbered is a parsed item with its line / row number
+type numbered[T any] struct {
+	num int
+	v   T
+}
+
+// batchResult holds the items parsed from a batch; err is the parse error
+// that stopped the batch, after the last good item
+type batchResult[T any] struct {
+	items []numbered[T]
+	err   error
+}
+
+// parallelRun fans batches produced by produce out to workers.
+// decode parses one batch; unit ("line" / "row") is used in processor error messages.
+func parallelRun[B, T any](
+	workers int,
+	ordered bool,
+	unit string,
+	produce func(emit func(B) bool) error,
+	decode func(B) batchResult[T],
+	processor func(T) error,
+) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	done := make(chan struct{})
+	var (
+		stopOnce sync.Once
+		errMu    sync.Mutex
+		firstErr error
+	)
+	fail := func(err error) {
+		errMu.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		errMu.Unlock()
+		stopOnce.Do(func() { close(done) })
+	}
+	stopped := func() bool {
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}
+
+	type job struct {
+		batch B
+		out   chan batchResult[T] // ordered mode only
+	}
+	jobs := make(chan job, workers)
+	futures := make(chan chan batchResult[T], 2*workers)
+
+	var produceErr error
+	go func() {
+		defer close(futures)
+		defer close(jobs)
+		produceErr = produce(func(b B) bool {
+			j := job{batch: b}
+			if ordered {
+				j.out = make(chan batchResult[T], 1)
+				select {
+				case futures <- j.out:
+				case <-done:
+					return false
+				}
+			}
+			select {
+			case jobs <- j:
+				return true
+			case <-done:
+				return false
+			}
+		})
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if stopped() {
+					if j.out != nil {
+						j.out <- batchResult[T]{}
+					}
+					continue
+				}
+				res := decode(j.batch)
+				if ordered {
+					j.out <- res
+					continue
+				}
+				if err := processItems(res.items, unit, processor, stopped); err != nil {
+					fail(err)
+				} else if res.err != nil {
+					fail(res.err)
+				}
+			}
+		}()
+	}
+
+	if ordered {
+		for fut := range futures {
+			res := <-fut
+			if err := processItems(res.items, unit, processor, stopped); err != nil {
+				fail(err)
+				break
+			}
+			if res.err != nil {
+				fail(res.err)
+				break
+			}
+		}
+	}
+	wg.Wait()
+	for range futures {
+		// unblock the producer after an early exit
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return produceErr
+}
+
+// processItems calls processor for items until an error or cancellation
+func processItems[T any](items []numbered[T], unit string, processor func(T) error, stopped func() bool) error {
+	for _, it := range items {
+		if stopped() {
+			return nil
+		}
+		if err := processor(it.v); err != nil {
+			return fmt.Errorf("%s %d: processor error: %w", unit, it.num, err)
+		}
+	}
+	return nil
+}
+
+// lineBatch is a run of input lines starting at line number first
+type lineBatch struct {
+	first int
+	lines [][]byte
+}
+
+// produceLines splits r into lineBatches; *total receives the number of lines read
+func produceLines(r io.Reader, total *int) func(emit func(lineBatch) bool) error {
+	return func(emit func(lineBatch) bool) error {
+		br := bufio.NewReaderSize(r, 1<<16)
+		batch := lineBatch{first: 1}
+		size := 0
+		lineNum := 0
+		for {
+			line, err := br.ReadBytes('\n')
+			if len(line) > 0 {
+				lineNum++
+				batch.lines = append(batch.lines, trimEOL(line))
+				size += len(line)
+				if len(batch.lines) >= parallelBatchLines || size >= parallelBatchBytes {
+					if !emit(batch) {
+						return nil
+					}
+					batch = lineBatch{first: lineNum + 1}
+					size = 0
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("read error: %w", err)
+			}
+		}
+		*total = lineNum
+		if len(batch.lines) > 0 {
+			emit(batch)
+		}
+		return nil
+	}
+}
+
+// decodeJSONLines parses every non-empty line of a batch as JSON into T
+func decodeJSONLines[T any](b lineBatch) batchResult[T] {
+	res := batchResult[T]{items: make([]numbered[T], 0, len(b.lines))}
+	for i, line := range b.lines {
+		if len(line) == 0 {
+			continue
+		}
+		var obj T
+		if err := json.Unmarshal(line, &obj); err != nil {
+			res.err = fmt.Errorf("line %d: JSON parse error: %w", b.first+i, err)
+			return res
+		}
+		res.items = append(res.items, numbered[T]{num: b.first + i, v: obj})
+	}
+	return res
+}
+
+// iterateJSONLParallel is the shared implementation of the JSONL parallel iterators
+func iterateJSONLParallel[T any](filename string, workers int, ordered bool, processor func(T) error) error {
+	fi, err := Open(context.Background(), filename)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+
+	lines := 0
+	if err := parallelRun(workers, ordered, "line", produceLines(fi, &lines), decodeJSONLines[T], processor); err != nil {
+		return err
+	}
+	fmt.Printf("File %s. Lines processed: %d\n", filename, lines)
+	return nil
+}
+
+// IterateJSONLParallel is IterateJSONL with parsing split across workers.
+// ordered = false calls processor concurrently (it must be goroutine-safe);
+// ordered = true calls it from one goroutine in input order.
+// The first error stops the iteration and is reported with its line number.
+//
+// Example:
+//
+//	var mu sync.Mutex
+//	fileiterator.IterateJSONLParallel("events.jsonl.zst", 8, false, func(obj map[string]any) error {
+//	    info := lookup(obj["ip"]) // expensive, runs on 8 goroutines
+//	    mu.Lock()
+//	    defer mu.Unlock()
+//	    ...
+//	    return nil
+//	})
+func IterateJSONLParallel(filename string, workers int, ordered bool, processor func(map[string]any) error) error {
+	return iterateJSONLParallel(filename, workers, ordered, processor)
+}
+
+// IterateJSONLTypedParallel is IterateJSONLTyped with parsing split across workers.
+// See IterateJSONLParallel for the meaning of workers and ordered.
+func IterateJSONLTypedParallel[T any](filename string, workers int, ordered bool, processor func(T) error) error {
+	return iterateJSONLParallel(filename, workers, ordered, processor)
+}
+
+// csvBatch is a run of complete CSV records
+type csvBatch struct {
+	firstRow  int // row number of the first record
+	firstLine int // physical line of the first record (for csv.ParseError positions)
+	data      []byte
+}
+
+// csvSplitter reads whole CSV records (quoted fields may span lines) without parsing them.
+// Blank and comment lines are skipped the same way csv.Reader skips them.
+type csvSplitter struct {
+	br      *bufio.Reader
+	comment rune
+	line    int // physical lines read
+}
+
+// next returns the raw bytes of the next record and its first physical line
+func (s *csvSplitter) next() ([]byte, int, error) {
+	var record []byte
+	start := 0
+	inQuotes := false
+	for {
+		line, err := s.br.ReadBytes('\n')
+		if len(line) > 0 {
+			s.line++
+			if start == 0 && s.skip(line) {
+				// blank or comment line between records
+			} else {
+				if start == 0 {
+					start = s.line
+				}
+				record = append(record, line...)
+				if bytes.Count(line, []byte{'"'})%2 == 1 {
+					inQuotes = !inQuotes
+				}
+				if !inQuotes {
+					return record, start, nil
+				}
+			}
+		}
+		if err == io.EOF {
+			if start > 0 {
+				return record, start, nil // unterminated quote - csv.Reader reports it
+			}
+			return nil, 0, io.EOF
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("read error: %w", err)
+		}
+	}
+}
+
+// skip reports lines csv.Reader ignores outside of quoted fields
+func (s *csvSplitter) skip(line []byte) bool {
+	trimmed := trimEOL(line)
+	if len(trimmed) == 0 {
+		return true
+	}
+	return s.comment != 0 && bytes.HasPrefix(trimmed, []byte(string(s.comment)))
+}
+
+// csvParallel reads the optional header, then splits the rest of the file into csvBatches
+type csvParallel struct {
+	opts       CSVOptions
+	split      *csvSplitter
+	fields     int      // expected fields per record, taken from the first record
+	header     []string // first record when it is a header
+	firstRow   int
+	firstLine  int
+	first      []byte // first record when it is data
+	rows       int    // records read, including header
+	emptyInput bool
+}
+
+func newCSVParallel(r io.Reader, opts CSVOptions, hasHeader bool) (*csvParallel, error) {
+	c := &csvParallel{
+		opts:  opts,
+		split: &csvSplitter{br: bufio.NewReaderSize(r, 1<<16), comment: opts.Comment},
+	}
+	raw, line, err := c.split.next()
+	if err == io.EOF {
+		c.emptyInput = true
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	record, err := c.reader(raw).Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			c.emptyInput = true
+			return c, nil
+		}
+		if hasHeader {
+			return nil, fmt.Errorf("failed to read header: %w", err)
+		}
+		return nil, fmt.Errorf("row 1: CSV parse error: %w", err)
+	}
+	c.fields = len(record)
+	c.rows = 1
+	if hasHeader {
+		c.header = record
+	} else {
+		c.first, c.firstLine = raw, line
+	}
+	return c, nil
+}
+
+// reader returns a csv.Reader for raw records with the configured options
+func (c *csvParallel) reader(raw []byte) *csv.Reader {
+	reader := csv.NewReader(bytes.NewReader(raw))
+	reader.Comma = c.opts.Comma
+	reader.Comment = c.opts.Comment
+	reader.TrimLeadingSpace = c.opts.TrimLeadingSpace
+	reader.FieldsPerRecord = c.fields
+	return reader
+}
+
+func (c *csvParallel) produce(emit func(csvBatch) bool) error {
+	if c.emptyInput {
+		return nil
+	}
+	batch := csvBatch{firstRow: c.rows + 1}
+	count := 0
+	if c.first != nil {
+		batch = csvBatch{firstRow: 1, firstLine: c.firstLine, data: c.first}
+		count = 1
+	}
+	for {
+		raw, line, err := c.split.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		c.rows++
+		if count == 0 {
+			batch.firstLine = line
+		}
+		batch.data = append(batch.data, raw...)
+		count++
+		if count >= parallelBatchLines || len(batch.data) >= parallelBatchBytes {
+			if !emit(batch) {
+				return nil
+			}
+			batch = csvBatch{firstRow: c.rows + 1}
+			count = 0
+		}
+	}
+	if count > 0 {
+		emit(batch)
+	}
+	return nil
+}
+
+// decode parses all records of a batch
+func (c *csvParallel) decode(b csvBatch) batchResult[[]string] {
+	var res batchResult[[]string]
+	reader := c.reader(b.data)
+	for row := b.firstRow; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return res
+		}
+		if err != nil {
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				pe.StartLine += b.firstLine - 1
+				pe.Line += b.firstLine - 1
+			}
+			res.err = fmt.Errorf("row %d: CSV parse error: %w", row, err)
+			return res
+		}
+		res.items = append(res.items, numbered[[]string]{num: row, v: record})
+	}
+}
+
+// IterateCSVParallel is IterateCSV with parsing split across workers.
+// See IterateJSONLParallel for the meaning of workers and ordered.
+func IterateCSVParallel(filename string, opts CSVOptions, workers int, ordered bool, processor func([]string) error) error {
+	fi, err := Open(context.Background(), filename)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+
+	c, err := newCSVParallel(fi, opts, opts.SkipHeader)
+	if err != nil {
+		return err
+	}
+	if c.emptyInput {
+		fmt.Printf("File %s. Rows processed: 0 (empty file)\n", filename)
+		return nil
+	}
+	if err := parallelRun(workers, ordered, "row", c.produce, c.decode, processor); err != nil {
+		return err
+	}
+	fmt.Printf("File %s. Rows processed: %d\n", filename, c.rows)
+	return nil
+}
+
+// IterateCSVMapParallel is IterateCSVMap with parsing split across workers.
+// See IterateJSONLParallel for the meaning of workers and ordered.
+func IterateCSVMapParallel(filename string, opts CSVOptions, workers int, ordered bool, processor func(map[string]string) error) error {
+	fi, err := Open(context.Background(), filename)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+
+	c, err := newCSVParallel(fi, opts, true)
+	if err != nil {
+		return err
+	}
+	if c.emptyInput {
+		return fmt.Errorf("empty CSV file (no header)")
+	}
+
+	decode := func(b csvBatch) batchResult[map[string]string] {
+		rows := c.decode(b)
+		res := batchResult[map[string]string]{items: make([]numbered[map[string]string], len(rows.items)), err: rows.err}
+		for i, it := range rows.items {
+			rowMap := make(map[string]string, len(c.header))
+			for j, header := range c.header {
+				if j < len(it.v) {
+					rowMap[header] = it.v[j]
+				} else {
+					rowMap[header] = ""
+				}
+			}
+			res.items[i] = numbered[map[string]string]{num: it.num, v: rowMap}
+		}
+		return res
+	}
+	if err := parallelRun(workers, ordered, "row", c.produce, decode, processor); err != nil {
+		return err
+	}
+	fmt.Printf("File %s. Rows processed: %d (excluding header)\n", filename, c.rows-1)
+	return nil
+}
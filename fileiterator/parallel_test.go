@@ -0,0 +1,201 @@
+package fileiterator_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/parf/homebase-go-lib/fileiterator"
+)
+
+func writeJSONLines(t *testing.T, path string, n int) {
+	t.Helper()
+	writeJSONLinesEvery(t, path, n, 1000, fileiterator.CreateOptions{})
+}
+
+// writeJSONLinesEvery writes n records with an empty line after every blank-th one
+func writeJSONLinesEvery(t *testing.T, path string, n, blank int, opts fileiterator.CreateOptions) {
+	t.Helper()
+	w, err := fileiterator.Create(path, opts)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(w, "{\"id\": %d, \"name\": \"user%d\"}\n", i, i)
+		if i%blank == 0 {
+			fmt.Fprintln(w) // empty lines are skipped but counted
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestIterateJSONLParallelUnordered(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "data.jsonl.gz")
+	writeJSONLines(t, testFile, 10000)
+
+	var sum, count atomic.Int64
+	err := fileiterator.IterateJSONLParallel(testFile, 4, false, func(obj map[string]any) error {
+		sum.Add(int64(obj["id"].(float64)))
+		count.Add(1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateJSONLParallel failed: %v", err)
+	}
+	if count.Load() != 10000 || sum.Load() != 10000*10001/2 {
+		t.Errorf("Expected 10000 records, got %d (sum %d)", count.Load(), sum.Load())
+	}
+}
+
+func TestIterateJSONLParallelOrdered(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "data.jsonl")
+	writeJSONLines(t, testFile, 5000)
+
+	type User struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	next := 1
+	err := fileiterator.IterateJSONLTypedParallel(testFile, 8, true, func(u User) error {
+		if u.ID != next {
+			return fmt.Errorf("out of order: got %d, want %d", u.ID, next)
+		}
+		next++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateJSONLTypedParallel failed: %v", err)
+	}
+	if next != 5001 {
+		t.Errorf("Expected 5000 records, got %d", next-1)
+	}
+}
+
+func TestIterateJSONLParallelErrors(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "data.jsonl")
+	writeJSONLines(t, testFile, 3000)
+
+	// Line numbers include the empty lines after every 1000 records
+	stop := errors.New("stop")
+	for _, ordered := range []bool{false, true} {
+		var mu sync.Mutex
+		processed := 0
+		err := fileiterator.IterateJSONLParallel(testFile, 4, ordered, func(obj map[string]any) error {
+			if obj["id"].(float64) == 2500 {
+				return stop
+			}
+			mu.Lock()
+			processed++
+			mu.Unlock()
+			return nil
+		})
+		if !errors.Is(err, stop) || !strings.HasPrefix(err.Error(), "line 2502: processor error") {
+			t.Errorf("ordered=%v: expected line 2502 processor error, got %v", ordered, err)
+		}
+		if ordered && processed != 2499 {
+			t.Errorf("Expected 2499 records before the error, got %d", processed)
+		}
+	}
+
+	badFile := filepath.Join(t.TempDir(), "bad.jsonl")
+	os.WriteFile(badFile, []byte("{\"id\": 1}\n{\"id\": 2}\nnot json\n{\"id\": 4}\n"), 0644)
+	err := fileiterator.IterateJSONLParallel(badFile, 2, true, func(map[string]any) error { return nil })
+	if err == nil || !strings.HasPrefix(err.Error(), "line 3: JSON parse error") {
+		t.Errorf("Expected line 3 parse error, got %v", err)
+	}
+}
+
+func writeCSV(t *testing.T, path string, n int) {
+	t.Helper()
+	w := fileiterator.FUCreate(path)
+	fmt.Fprintln(w, "id,name,note")
+	for i := 1; i <= n; i++ {
+		if i%100 == 0 {
+			// Quoted field spanning lines, with an escaped quote
+			fmt.Fprintf(w, "%d,user%d,\"multi\nline \"\"%d\"\"\"\n", i, i, i)
+		} else {
+			fmt.Fprintf(w, "%d,user%d,plain\n", i, i)
+		}
+	}
+	w.Close()
+}
+
+func TestIterateCSVParallel(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "data.csv.zst")
+	writeCSV(t, testFile, 3000)
+
+	opts := fileiterator.DefaultCSVOptions()
+	opts.SkipHeader = true
+
+	next := 1
+	err := fileiterator.IterateCSVParallel(testFile, opts, 4, true, func(row []string) error {
+		if row[0] != fmt.Sprint(next) {
+			return fmt.Errorf("out of order: got %s, want %d", row[0], next)
+		}
+		if next%100 == 0 && row[2] != fmt.Sprintf("multi\nline \"%d\"", next) {
+			return fmt.Errorf("bad multi-line field %q", row[2])
+		}
+		next++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateCSVParallel failed: %v", err)
+	}
+	if next != 3001 {
+		t.Errorf("Expected 3000 rows, got %d", next-1)
+	}
+}
+
+func TestIterateCSVMapParallel(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "data.csv")
+	writeCSV(t, testFile, 2000)
+
+	var count atomic.Int64
+	err := fileiterator.IterateCSVMapParallel(testFile, fileiterator.DefaultCSVOptions(), 4, false, func(row map[string]string) error {
+		if row["name"] != "user"+row["id"] {
+			return fmt.Errorf("bad row %v", row)
+		}
+		count.Add(1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateCSVMapParallel failed: %v", err)
+	}
+	if count.Load() != 2000 {
+		t.Errorf("Expected 2000 rows, got %d", count.Load())
+	}
+
+	// Row numbers match IterateCSVMap: header is row 1
+	stop := errors.New("stop")
+	err = fileiterator.IterateCSVMapParallel(testFile, fileiterator.DefaultCSVOptions(), 4, false, func(row map[string]string) error {
+		if row["id"] == "1500" {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || !strings.HasPrefix(err.Error(), "row 1501: processor error") {
+		t.Errorf("Expected row 1501 processor error, got %v", err)
+	}
+}
+
+func TestIterateCSVParallelFieldCount(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "data.csv")
+	var b strings.Builder
+	for i := 1; i <= 1000; i++ {
+		fmt.Fprintf(&b, "%d,a\n", i)
+	}
+	b.WriteString("1001,a,extra\n")
+	os.WriteFile(testFile, []byte(b.String()), 0644)
+
+	err := fileiterator.IterateCSVParallel(testFile, fileiterator.DefaultCSVOptions(), 4, false, func([]string) error { return nil })
+	if err == nil || !strings.HasPrefix(err.Error(), "row 1001: CSV parse error") || !strings.Contains(err.Error(), "line 1001") {
+		t.Errorf("Expected row 1001 field count error, got %v", err)
+	}
+}
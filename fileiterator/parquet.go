@@ -1,17 +1,18 @@
 package fileiterator
 
 import (
-	"context"
+	"encoding/json"
 	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"slices"
 	"sort"
+	"time"
 
 	"github.com/apache/arrow/go/v14/arrow"
 	"github.com/apache/arrow/go/v14/arrow/array"
-	"github.com/apache/arrow/go/v14/arrow/memory"
-	"github.com/apache/arrow/go/v14/parquet"
-	"github.com/apache/arrow/go/v14/parquet/compress"
-	"github.com/apache/arrow/go/v14/parquet/file"
-	"github.com/apache/arrow/go/v14/parquet/pqarrow"
+	"github.com/apache/arrow/go/v14/arrow/decimal128"
 )
 
 // ParquetRecord represents a generic record for Parquet operations
@@ -28,61 +29,55 @@ type ParquetRecord struct {
 }
 
 // IterateParquet reads Parquet file and calls processor for each record
-// Automatically handles compression detection via FUOpen
+// Streams row groups in batches, so memory use does not grow with file size
 // Schema: id, name, email, age, score, active, category, timestamp
 func IterateParquet(filename string, processor func(ParquetRecord) error) error {
-	pf, err := file.OpenParquetFile(filename, false)
-	if err != nil {
-		return err
-	}
-	defer pf.Close()
-
-	reader, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{}, memory.NewGoAllocator())
-	if err != nil {
-		return err
-	}
-
-	tbl, err := reader.ReadTable(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tbl.Release()
-
-	numRows := int(tbl.NumRows())
-
-	// Extract columns
-	idCol := tbl.Column(0).Data().Chunk(0).(*array.Int64)
-	nameCol := tbl.Column(1).Data().Chunk(0).(*array.String)
-	emailCol := tbl.Column(2).Data().Chunk(0).(*array.String)
-	ageCol := tbl.Column(3).Data().Chunk(0).(*array.Int64)
-	scoreCol := tbl.Column(4).Data().Chunk(0).(*array.Float64)
-	activeCol := tbl.Column(5).Data().Chunk(0).(*array.Boolean)
-	categoryCol := tbl.Column(6).Data().Chunk(0).(*array.String)
-	timestampCol := tbl.Column(7).Data().Chunk(0).(*array.Int64)
-
-	for i := 0; i < numRows; i++ {
-		rec := ParquetRecord{
-			ID:        idCol.Value(i),
-			Name:      nameCol.Value(i),
-			Email:     emailCol.Value(i),
-			Age:       ageCol.Value(i),
-			Score:     scoreCol.Value(i),
-			Active:    activeCol.Value(i),
-			Category:  categoryCol.Value(i),
-			Timestamp: timestampCol.Value(i),
-		}
-		if err := processor(rec); err != nil {
-			return err
+	_, err := scanParquet(filename, DefaultParquetReadOptions(), func(rec arrow.Record) error {
+		if rec.NumCols() < 8 {
+			return fmt.Errorf("parquet: expected 8 columns, got %d", rec.NumCols())
+		}
+		idCol, ok1 := rec.Column(0).(*array.Int64)
+		nameCol, ok2 := rec.Column(1).(*array.String)
+		emailCol, ok3 := rec.Column(2).(*array.String)
+		ageCol, ok4 := rec.Column(3).(*array.Int64)
+		scoreCol, ok5 := rec.Column(4).(*array.Float64)
+		activeCol, ok6 := rec.Column(5).(*array.Boolean)
+		categoryCol, ok7 := rec.Column(6).(*array.String)
+		timestampCol, ok8 := rec.Column(7).(*array.Int64)
+		if !(ok1 && ok2 && ok3 && ok4 && ok5 && ok6 && ok7 && ok8) {
+			return fmt.Errorf("parquet: schema %v does not match ParquetRecord", rec.Schema())
 		}
-	}
 
-	return nil
+		for i := 0; i < int(rec.NumRows()); i++ {
+			record := ParquetRecord{
+				ID:        idCol.Value(i),
+				Name:      nameCol.Value(i),
+				Email:     emailCol.Value(i),
+				Age:       ageCol.Value(i),
+				Score:     scoreCol.Value(i),
+				Active:    activeCol.Value(i),
+				Category:  categoryCol.Value(i),
+				Timestamp: timestampCol.Value(i),
+			}
+			if err := processor(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err
 }
 
 // WriteParquet writes records to Parquet file with Snappy compression
-// Automatically handles additional compression via FUCreate (if filename has .gz/.zst/.lz4 extension)
+// A .gz/.zst/.lz4/.br suffix selects that Parquet page codec instead (see ParquetWriteOptions)
 // Schema: id, name, email, age, score, active, category, timestamp
 func WriteParquet(filename string, records []ParquetRecord) error {
+	return WriteParquetWithOptions(filename, records, DefaultParquetWriteOptions())
+}
+
+// WriteParquetWithOptions is WriteParquet with compression, layout and atomic write settings
+// (opts.Schema and opts.SampleSize are ignored)
+func WriteParquetWithOptions(filename string, records []ParquetRecord, opts ParquetWriteOptions) error {
 	// Create Arrow schema
 	schema := arrow.NewSchema(
 		[]arrow.Field{
@@ -98,25 +93,8 @@ func WriteParquet(filename string, records []ParquetRecord) error {
 		nil,
 	)
 
-	// Create output file with auto-compression detection
-	f := FUCreate(filename)
-	defer f.Close()
-
-	// Create Parquet writer with Snappy compression
-	writerProps := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy))
-	arrowProps := pqarrow.DefaultWriterProps()
-	writer, err := pqarrow.NewFileWriter(schema, f, writerProps, arrowProps)
-	if err != nil {
-		return err
-	}
-	defer writer.Close()
-
-	// Build Arrow record batch
-	pool := memory.NewGoAllocator()
-	builder := array.NewRecordBuilder(pool, schema)
-	defer builder.Release()
-
-	for _, record := range records {
+	return writeParquetRows(filename, schema, opts, len(records), func(builder *array.RecordBuilder, i int) error {
+		record := records[i]
 		builder.Field(0).(*array.Int64Builder).Append(record.ID)
 		builder.Field(1).(*array.StringBuilder).Append(record.Name)
 		builder.Field(2).(*array.StringBuilder).Append(record.Email)
@@ -125,105 +103,116 @@ func WriteParquet(filename string, records []ParquetRecord) error {
 		builder.Field(5).(*array.BooleanBuilder).Append(record.Active)
 		builder.Field(6).(*array.StringBuilder).Append(record.Category)
 		builder.Field(7).(*array.Int64Builder).Append(record.Timestamp)
-	}
-
-	rec := builder.NewRecord()
-	defer rec.Release()
+		return nil
+	})
+}
 
-	// Write record batch
-	if err := writer.Write(rec); err != nil {
-		return err
-	}
+// ParquetWriteOptions controls schema, compression and layout of written Parquet files
+type ParquetWriteOptions struct {
+	// Schema overrides schema inference; records may only contain its fields
+	Schema *arrow.Schema
+	// SampleSize is the number of leading records used to infer the schema
+	// (0 = all records; 10,000 for ParquetWriter, which adds fields seen later as new columns)
+	SampleSize int
+
+	// Compression is the page codec: ParquetSnappy, CodecZstd, CodecGzip, CodecLz4, CodecBrotli or CodecNone.
+	// "" - taken from the filename suffix (data.parquet.zst - zstd), otherwise snappy.
+	Compression string
+	// CompressionLevel for zstd, gzip and brotli (0 = codec default)
+	CompressionLevel int
+	// RowGroupSize is the number of rows per row group (0 = 64K)
+	RowGroupSize int
+	// PageSize is the target data page size in bytes (0 = 1MB)
+	PageSize int64
+	// DisableDictionary turns dictionary encoding off; DictionaryColumns overrides it per column
+	DisableDictionary bool
+	DictionaryColumns map[string]bool
+	// DisableStatistics omits column min/max/null-count statistics
+	// (used by ParquetReadOptions.Filter to skip row groups)
+	DisableStatistics bool
+	// Metadata is stored as key/value metadata in the file footer
+	Metadata map[string]string
+	// Atomic writes a temporary file that is renamed into place on success (see CreateOptions.Atomic)
+	Atomic bool
+}
 
-	return nil
+// DefaultParquetWriteOptions returns schema inference from all records,
+// snappy compression, 64K-row row groups, dictionary encoding and statistics
+func DefaultParquetWriteOptions() ParquetWriteOptions {
+	return ParquetWriteOptions{}
 }
 
 // WriteParquetAny writes generic records to Parquet file with Snappy compression
 // Automatically infers schema from data - supports ANY record structure
-// Handles compression via FUCreate (if filename has .gz/.zst/.lz4 extension)
-// Supported types: int64, float64, string, bool
+// A .gz/.zst/.lz4/.br suffix selects that Parquet page codec instead (see ParquetWriteOptions)
+// Supported types: ints, floats, string, bool, []byte, time.Time, arrow.Date32, Decimal,
+// slices (lists) and map[string]any (structs); all columns are nullable
 func WriteParquetAny(filename string, records []map[string]any) error {
+	return WriteParquetAnyWithOptions(filename, records, DefaultParquetWriteOptions())
+}
+
+// WriteParquetAnyWithOptions is WriteParquetAny with an explicit schema or a limited inference sample,
+// compression, row-group and page sizes, dictionary, statistics and metadata settings
+//
+// Example:
+//
+//	schema := arrow.NewSchema([]arrow.Field{
+//	    {Name: "id", Type: arrow.PrimitiveTypes.Int64},
+//	    {Name: "price", Type: &arrow.Decimal128Type{Precision: 10, Scale: 2}, Nullable: true},
+//	}, nil)
+//	err := fileiterator.WriteParquetAnyWithOptions("prices.parquet", records, fileiterator.ParquetWriteOptions{Schema: schema})
+func WriteParquetAnyWithOptions(filename string, records []map[string]any, opts ParquetWriteOptions) error {
 	if len(records) == 0 {
 		return fmt.Errorf("no records to write")
 	}
 
-	// Infer schema from all records
-	schema, fieldOrder, err := inferSchema(records)
-	if err != nil {
-		return err
+	schema := opts.Schema
+	if schema == nil {
+		sample := records
+		if opts.SampleSize > 0 && opts.SampleSize < len(sample) {
+			sample = sample[:opts.SampleSize]
+		}
+		var err error
+		if schema, _, err = inferSchema(sample); err != nil {
+			return err
+		}
 	}
-
-	// Create output file with auto-compression detection
-	f := FUCreate(filename)
-	defer f.Close()
-
-	// Create Parquet writer with Snappy compression
-	writerProps := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy))
-	arrowProps := pqarrow.DefaultWriterProps()
-	writer, err := pqarrow.NewFileWriter(schema, f, writerProps, arrowProps)
-	if err != nil {
-		return err
+	fieldOrder := make([]string, schema.NumFields())
+	fields := make(map[string]bool, len(fieldOrder))
+	for i, field := range schema.Fields() {
+		fieldOrder[i] = field.Name
+		fields[field.Name] = true
 	}
-	defer writer.Close()
 
-	// Build Arrow record batch
-	pool := memory.NewGoAllocator()
-	builder := array.NewRecordBuilder(pool, schema)
-	defer builder.Release()
-
-	// Append records
-	for _, record := range records {
+	return writeParquetRows(filename, schema, opts, len(records), func(builder *array.RecordBuilder, n int) error {
+		record := records[n]
+		for key := range record {
+			if !fields[key] {
+				return fmt.Errorf("record %d: field %q is not in Parquet schema", n, key)
+			}
+		}
 		for i, fieldName := range fieldOrder {
-			value := record[fieldName]
-			if err := appendValue(builder.Field(i), value); err != nil {
-				return fmt.Errorf("error appending field %s: %w", fieldName, err)
+			if err := appendValue(builder.Field(i), record[fieldName]); err != nil {
+				return fmt.Errorf("record %d: error appending field %s: %w", n, fieldName, err)
 			}
 		}
-	}
-
-	rec := builder.NewRecord()
-	defer rec.Release()
-
-	// Write record batch
-	if err := writer.Write(rec); err != nil {
-		return err
-	}
-
-	return nil
+		return nil
+	})
 }
 
 // inferSchema infers Arrow schema from records
-// Returns schema and field order for consistent field ordering
+// Every record is inspected; types of the same field are merged (see mergeTypes)
+// and all fields are nullable. Returns schema and field order for consistent field ordering
 func inferSchema(records []map[string]any) (*arrow.Schema, []string, error) {
 	if len(records) == 0 {
 		return nil, nil, fmt.Errorf("cannot infer schema from empty records")
 	}
 
-	// Collect all field names and infer types
+	// Collect all field names and merge types
 	fieldTypes := make(map[string]arrow.DataType)
-
-	// Sample first record to get field names
-	for key, value := range records[0] {
-		fieldTypes[key] = inferType(value)
-	}
-
-	// Verify all records have compatible types
-	for _, record := range records[1:] {
+	for _, record := range records {
 		for key, value := range record {
-			if value == nil {
-				continue // Skip nil values
-			}
-			inferredType := inferType(value)
-			if existing, ok := fieldTypes[key]; ok {
-				// Check type compatibility
-				if existing != inferredType {
-					// Promote to string if types don't match
-					fieldTypes[key] = arrow.BinaryTypes.String
-				}
-			} else {
-				// New field found in later record
-				fieldTypes[key] = inferredType
-			}
+			fieldTypes[key] = mergeTypes(fieldTypes[key], inferType(value))
 		}
 	}
 
@@ -238,8 +227,9 @@ func inferSchema(records []map[string]any) (*arrow.Schema, []string, error) {
 	fields := make([]arrow.Field, len(fieldNames))
 	for i, name := range fieldNames {
 		fields[i] = arrow.Field{
-			Name: name,
-			Type: fieldTypes[name],
+			Name:     name,
+			Type:     resolveNullType(fieldTypes[name]),
+			Nullable: true,
 		}
 	}
 
@@ -248,8 +238,11 @@ func inferSchema(records []map[string]any) (*arrow.Schema, []string, error) {
 }
 
 // inferType infers Arrow type from Go value
+// nil gives arrow.Null, which mergeTypes replaces with the type of other values
 func inferType(value any) arrow.DataType {
-	switch value.(type) {
+	switch v := value.(type) {
+	case nil:
+		return arrow.Null
 	case int, int8, int16, int32, int64:
 		return arrow.PrimitiveTypes.Int64
 	case uint, uint8, uint16, uint32, uint64:
@@ -260,14 +253,117 @@ func inferType(value any) arrow.DataType {
 		return arrow.FixedWidthTypes.Boolean
 	case string:
 		return arrow.BinaryTypes.String
-	default:
-		// Default to string for unknown types
+	case []byte:
+		return arrow.BinaryTypes.Binary
+	case time.Time:
+		return parquetTimestampType
+	case arrow.Date32:
+		return arrow.FixedWidthTypes.Date32
+	case Decimal:
+		return &arrow.Decimal128Type{Precision: v.precision(), Scale: v.Scale}
+	case map[string]any:
+		fields := make([]arrow.Field, 0, len(v))
+		for key, elem := range v {
+			fields = append(fields, arrow.Field{Name: key, Type: inferType(elem), Nullable: true})
+		}
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+		return arrow.StructOf(fields...)
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return arrow.Null
+		}
+		return inferType(rv.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		var elem arrow.DataType = arrow.Null
+		for i := 0; i < rv.Len(); i++ {
+			elem = mergeTypes(elem, inferType(rv.Index(i).Interface()))
+		}
+		return arrow.ListOf(elem)
+	}
+	// Default to string for unknown types
+	return arrow.BinaryTypes.String
+}
+
+// maxDecimalPrecision is the largest precision of Decimal128
+const maxDecimalPrecision = 38
+
+// mergeTypes returns a type that can hold values of both a and b:
+// NULL takes the other type, int widens to float, date widens to timestamp,
+// decimals widen to fit both, lists and structs merge element-wise;
+// anything else incompatible falls back to string
+func mergeTypes(a, b arrow.DataType) arrow.DataType {
+	switch {
+	case a == nil || a.ID() == arrow.NULL:
+		return b
+	case b.ID() == arrow.NULL:
+		return a
+	case arrow.TypeEqual(a, b):
+		return a
+	}
+
+	switch ids := [2]arrow.Type{a.ID(), b.ID()}; ids {
+	case [2]arrow.Type{arrow.INT64, arrow.FLOAT64}, [2]arrow.Type{arrow.FLOAT64, arrow.INT64}:
+		return arrow.PrimitiveTypes.Float64
+	case [2]arrow.Type{arrow.DATE32, arrow.TIMESTAMP}, [2]arrow.Type{arrow.TIMESTAMP, arrow.DATE32}:
+		return parquetTimestampType
+	case [2]arrow.Type{arrow.DECIMAL128, arrow.DECIMAL128}:
+		da, db := a.(*arrow.Decimal128Type), b.(*arrow.Decimal128Type)
+		scale := max(da.Scale, db.Scale)
+		precision := max(da.Precision-da.Scale, db.Precision-db.Scale) + scale
+		if precision <= maxDecimalPrecision {
+			return &arrow.Decimal128Type{Precision: precision, Scale: scale}
+		}
+	case [2]arrow.Type{arrow.LIST, arrow.LIST}:
+		return arrow.ListOf(mergeTypes(a.(*arrow.ListType).Elem(), b.(*arrow.ListType).Elem()))
+	case [2]arrow.Type{arrow.STRUCT, arrow.STRUCT}:
+		types := make(map[string]arrow.DataType)
+		for _, f := range a.(*arrow.StructType).Fields() {
+			types[f.Name] = f.Type
+		}
+		for _, f := range b.(*arrow.StructType).Fields() {
+			types[f.Name] = mergeTypes(types[f.Name], f.Type)
+		}
+		fields := make([]arrow.Field, 0, len(types))
+		for name, t := range types {
+			fields = append(fields, arrow.Field{Name: name, Type: t, Nullable: true})
+		}
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+		return arrow.StructOf(fields...)
+	}
+	return arrow.BinaryTypes.String
+}
+
+// resolveNullType replaces arrow.Null (fields that were always nil) with string
+func resolveNullType(t arrow.DataType) arrow.DataType {
+	switch t := t.(type) {
+	case *arrow.NullType:
 		return arrow.BinaryTypes.String
+	case *arrow.ListType:
+		return arrow.ListOf(resolveNullType(t.Elem()))
+	case *arrow.StructType:
+		fields := make([]arrow.Field, len(t.Fields()))
+		for i, f := range t.Fields() {
+			f.Type = resolveNullType(f.Type)
+			fields[i] = f
+		}
+		return arrow.StructOf(fields...)
 	}
+	return t
 }
 
 // appendValue appends a value to an Arrow array builder
 func appendValue(builder array.Builder, value any) error {
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			value = nil
+		} else {
+			value = rv.Elem().Interface()
+		}
+	}
 	if value == nil {
 		builder.AppendNull()
 		return nil
@@ -287,8 +383,14 @@ func appendValue(builder array.Builder, value any) error {
 		case int8:
 			b.Append(int64(v))
 		case uint:
+			if uint64(v) > math.MaxInt64 {
+				return fmt.Errorf("value %d overflows int64 column", v)
+			}
 			b.Append(int64(v))
 		case uint64:
+			if v > math.MaxInt64 {
+				return fmt.Errorf("value %d overflows int64 column", v)
+			}
 			b.Append(int64(v))
 		case uint32:
 			b.Append(int64(v))
@@ -297,6 +399,10 @@ func appendValue(builder array.Builder, value any) error {
 		case uint8:
 			b.Append(int64(v))
 		case float64:
+			// the schema was inferred from a sample - never truncate silently
+			if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+				return fmt.Errorf("value %v does not fit int64 column (schema inferred from a sample; set ParquetWriteOptions.Schema or a larger SampleSize)", v)
+			}
 			b.Append(int64(v))
 		default:
 			return fmt.Errorf("cannot convert %T to int64", value)
@@ -307,12 +413,14 @@ func appendValue(builder array.Builder, value any) error {
 			b.Append(v)
 		case float32:
 			b.Append(float64(v))
-		case int:
-			b.Append(float64(v))
-		case int64:
-			b.Append(float64(v))
+		case Decimal:
+			b.Append(v.Float64())
 		default:
-			return fmt.Errorf("cannot convert %T to float64", value)
+			n, ok := normalizeValue(value).(int64)
+			if !ok {
+				return fmt.Errorf("cannot convert %T to float64", value)
+			}
+			b.Append(float64(n))
 		}
 	case *array.BooleanBuilder:
 		switch v := value.(type) {
@@ -325,10 +433,97 @@ func appendValue(builder array.Builder, value any) error {
 		switch v := value.(type) {
 		case string:
 			b.Append(v)
+		case []byte:
+			b.Append(string(v))
+		case time.Time:
+			b.Append(v.Format(time.RFC3339Nano))
+		case fmt.Stringer:
+			b.Append(v.String())
 		default:
-			// Convert anything to string
+			// Nested values as JSON, anything else via %v
+			if kind := reflect.ValueOf(value).Kind(); kind == reflect.Map || kind == reflect.Slice || kind == reflect.Array {
+				if data, err := json.Marshal(value); err == nil {
+					b.Append(string(data))
+					return nil
+				}
+			}
 			b.Append(fmt.Sprintf("%v", value))
 		}
+	case *array.BinaryBuilder:
+		switch v := value.(type) {
+		case []byte:
+			b.Append(v)
+		case string:
+			b.AppendString(v)
+		default:
+			return fmt.Errorf("cannot convert %T to binary", value)
+		}
+	case *array.TimestampBuilder:
+		var t time.Time
+		switch v := value.(type) {
+		case time.Time:
+			t = v
+		case arrow.Date32:
+			t = v.ToTime()
+		default:
+			return fmt.Errorf("cannot convert %T to timestamp", value)
+		}
+		ts, err := arrow.TimestampFromTime(t, b.Type().(*arrow.TimestampType).Unit)
+		if err != nil {
+			return err
+		}
+		b.Append(ts)
+	case *array.Date32Builder:
+		switch v := value.(type) {
+		case arrow.Date32:
+			b.Append(v)
+		case time.Time:
+			b.Append(arrow.Date32FromTime(v))
+		default:
+			return fmt.Errorf("cannot convert %T to date", value)
+		}
+	case *array.Decimal128Builder:
+		d, err := toDecimal(value)
+		if err != nil {
+			return err
+		}
+		unscaled, err := d.rescale(b.Type().(*arrow.Decimal128Type).Scale)
+		if err != nil {
+			return err
+		}
+		num := decimal128.FromBigInt(unscaled)
+		if !num.FitsInPrecision(b.Type().(*arrow.Decimal128Type).Precision) {
+			return fmt.Errorf("decimal %s does not fit %s", d, b.Type())
+		}
+		b.Append(num)
+	case *array.ListBuilder:
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return fmt.Errorf("cannot convert %T to list", value)
+		}
+		b.Append(true)
+		for i := 0; i < rv.Len(); i++ {
+			if err := appendValue(b.ValueBuilder(), rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	case *array.StructBuilder:
+		m, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("cannot convert %T to struct", value)
+		}
+		st := b.Type().(*arrow.StructType)
+		for key := range m {
+			if _, ok := st.FieldIdx(key); !ok {
+				return fmt.Errorf("field %q is not in struct %s", key, st)
+			}
+		}
+		b.Append(true)
+		for i, f := range st.Fields() {
+			if err := appendValue(b.FieldBuilder(i), m[f.Name]); err != nil {
+				return fmt.Errorf("%s: %w", f.Name, err)
+			}
+		}
 	default:
 		return fmt.Errorf("unsupported builder type: %T", builder)
 	}
@@ -336,57 +531,60 @@ func appendValue(builder array.Builder, value any) error {
 	return nil
 }
 
-// IterateParquetAny reads Parquet file and calls processor for each record as map[string]any
-// Automatically handles compression detection via file extension
-// Supports ANY Parquet schema
-func IterateParquetAny(filename string, processor func(map[string]any) error) error {
-	pf, err := file.OpenParquetFile(filename, false)
-	if err != nil {
-		return err
+// toDecimal converts Decimal, integers and decimal strings to Decimal
+func toDecimal(value any) (Decimal, error) {
+	switch v := value.(type) {
+	case Decimal:
+		return v, nil
+	case string:
+		return ParseDecimal(v)
 	}
-	defer pf.Close()
-
-	reader, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{}, memory.NewGoAllocator())
-	if err != nil {
-		return err
+	if n, ok := normalizeValue(value).(int64); ok {
+		return Decimal{Unscaled: big.NewInt(n)}, nil
 	}
+	return Decimal{}, fmt.Errorf("cannot convert %T to decimal", value)
+}
 
-	tbl, err := reader.ReadTable(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tbl.Release()
+// IterateParquetAny reads Parquet file and calls processor for each record as map[string]any
+// Streams row groups in batches, so memory use does not grow with file size
+// Supports ANY Parquet schema
+// Use IterateParquetAnyWithOptions for batch size and parallel row-group decoding
+func IterateParquetAny(filename string, processor func(map[string]any) error) error {
+	_, err := IterateParquetAnyWithOptions(filename, DefaultParquetReadOptions(), processor)
+	return err
+}
 
-	numRows := int(tbl.NumRows())
-	numCols := int(tbl.NumCols())
-	schema := tbl.Schema()
+// processRecordMaps calls processor for every row of rec as map[string]any.
+// rowNum counts rows across batches.
+func processRecordMaps(rec arrow.Record, rowNum *int64, processor func(map[string]any) error) error {
+	schema := rec.Schema()
+	numCols := int(rec.NumCols())
 
-	// Process each row
-	for i := 0; i < numRows; i++ {
-		record := make(map[string]any)
+	for i := 0; i < int(rec.NumRows()); i++ {
+		record := make(map[string]any, numCols)
 
 		for colIdx := 0; colIdx < numCols; colIdx++ {
 			fieldName := schema.Field(colIdx).Name
-			col := tbl.Column(colIdx).Data().Chunk(0)
 
 			// Extract value based on column type
-			value, err := getValueFromColumn(col, i)
+			value, err := getValueFromColumn(rec.Column(colIdx), i)
 			if err != nil {
-				return fmt.Errorf("error reading column %s at row %d: %w", fieldName, i, err)
+				return fmt.Errorf("error reading column %s at row %d: %w", fieldName, *rowNum, err)
 			}
 
 			record[fieldName] = value
 		}
 
+		*rowNum++
 		if err := processor(record); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
 // getValueFromColumn extracts value from Arrow array at given index
+// Timestamps and dates become time.Time, decimals Decimal, lists []any and structs map[string]any
 func getValueFromColumn(arr arrow.Array, index int) (any, error) {
 	if arr.IsNull(index) {
 		return nil, nil
@@ -397,6 +595,18 @@ func getValueFromColumn(arr arrow.Array, index int) (any, error) {
 		return a.Value(index), nil
 	case *array.Int32:
 		return int64(a.Value(index)), nil
+	case *array.Int16:
+		return int64(a.Value(index)), nil
+	case *array.Int8:
+		return int64(a.Value(index)), nil
+	case *array.Uint64:
+		return a.Value(index), nil
+	case *array.Uint32:
+		return int64(a.Value(index)), nil
+	case *array.Uint16:
+		return int64(a.Value(index)), nil
+	case *array.Uint8:
+		return int64(a.Value(index)), nil
 	case *array.Float64:
 		return a.Value(index), nil
 	case *array.Float32:
@@ -405,6 +615,42 @@ func getValueFromColumn(arr arrow.Array, index int) (any, error) {
 		return a.Value(index), nil
 	case *array.String:
 		return a.Value(index), nil
+	case *array.LargeString:
+		return a.Value(index), nil
+	case *array.Binary:
+		return slices.Clone(a.Value(index)), nil
+	case *array.Timestamp:
+		return a.Value(index).ToTime(a.DataType().(*arrow.TimestampType).Unit), nil
+	case *array.Date32:
+		return a.Value(index).ToTime(), nil
+	case *array.Date64:
+		return a.Value(index).ToTime(), nil
+	case *array.Decimal128:
+		return Decimal{Unscaled: a.Value(index).BigInt(), Scale: a.DataType().(*arrow.Decimal128Type).Scale}, nil
+	case *array.Dictionary:
+		return getValueFromColumn(a.Dictionary(), a.GetValueIndex(index))
+	case *array.List:
+		start, end := a.ValueOffsets(index)
+		values := make([]any, 0, end-start)
+		for j := start; j < end; j++ {
+			v, err := getValueFromColumn(a.ListValues(), int(j))
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case *array.Struct:
+		st := a.DataType().(*arrow.StructType)
+		m := make(map[string]any, len(st.Fields()))
+		for j, f := range st.Fields() {
+			v, err := getValueFromColumn(a.Field(j), index)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Name, err)
+			}
+			m[f.Name] = v
+		}
+		return m, nil
 	default:
 		return nil, fmt.Errorf("unsupported array type: %T", arr)
 	}
@@ -0,0 +1,335 @@
+package fileiterator
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/apache/arrow/go/v14/parquet"
+	"github.com/apache/arrow/go/v14/parquet/metadata"
+	"github.com/apache/arrow/go/v14/parquet/schema"
+)
+
+// Expr is a row filter for Parquet scans (see ParquetReadOptions.Filter).
+// Build it with Eq, Lt, Le, Gt, Ge, In, IsNull, NotNull, And and Or.
+//
+// Row groups whose column statistics prove that no row can match are skipped
+// without being decoded; the remaining rows are checked one by one.
+// Comparisons never match NULL. Values are compared as numbers (any Go int,
+// uint or float type), strings ([]byte compares as string) or bools.
+type Expr interface {
+	// match reports whether a row matches; value returns a column value
+	match(value func(column string) any) bool
+	// mayMatch reports whether any row of a row group may match; false only if stats prove otherwise
+	mayMatch(stats func(column string) *columnStats) bool
+	// columns appends the columns the expression reads
+	columns(dst []string) []string
+}
+
+// Eq matches rows where column == value
+func Eq(column string, value any) Expr { return &cmpExpr{column, opEq, normalizeValue(value)} }
+
+// Lt matches rows where column < value
+func Lt(column string, value any) Expr { return &cmpExpr{column, opLt, normalizeValue(value)} }
+
+// Le matches rows where column <= value
+func Le(column string, value any) Expr { return &cmpExpr{column, opLe, normalizeValue(value)} }
+
+// Gt matches rows where column > value
+func Gt(column string, value any) Expr { return &cmpExpr{column, opGt, normalizeValue(value)} }
+
+// Ge matches rows where column >= value
+func Ge(column string, value any) Expr { return &cmpExpr{column, opGe, normalizeValue(value)} }
+
+// In matches rows where column equals any of values
+func In(column string, values ...any) Expr {
+	exprs := make([]Expr, len(values))
+	for i, v := range values {
+		exprs[i] = Eq(column, v)
+	}
+	return &logicalExpr{or: true, exprs: exprs, column: column}
+}
+
+// IsNull matches rows where column is NULL
+func IsNull(column string) Expr { return &nullExpr{column: column, null: true} }
+
+// NotNull matches rows where column is not NULL
+func NotNull(column string) Expr { return &nullExpr{column: column} }
+
+// And matches rows matching all exprs
+func And(exprs ...Expr) Expr { return &logicalExpr{exprs: exprs} }
+
+// Or matches rows matching any of exprs
+func Or(exprs ...Expr) Expr { return &logicalExpr{or: true, exprs: exprs} }
+
+type cmpOp int
+
+const (
+	opEq cmpOp = iota
+	opLt
+	opLe
+	opGt
+	opGe
+)
+
+type cmpExpr struct {
+	column string
+	op     cmpOp
+	value  any
+}
+
+func (e *cmpExpr) match(value func(string) any) bool {
+	c, ok := compareValues(normalizeValue(value(e.column)), e.value)
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case opEq:
+		return c == 0
+	case opLt:
+		return c < 0
+	case opLe:
+		return c <= 0
+	case opGt:
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+func (e *cmpExpr) mayMatch(stats func(string) *columnStats) bool {
+	s := stats(e.column)
+	if s == nil {
+		return true
+	}
+	if s.allNull() {
+		return false
+	}
+	if !s.hasMinMax {
+		return true
+	}
+	cMin, ok1 := compareValues(s.min, e.value)
+	cMax, ok2 := compareValues(s.max, e.value)
+	if !ok1 || !ok2 {
+		return true
+	}
+	switch e.op {
+	case opEq:
+		return cMin <= 0 && cMax >= 0
+	case opLt:
+		return cMin < 0
+	case opLe:
+		return cMin <= 0
+	case opGt:
+		return cMax > 0
+	default:
+		return cMax >= 0
+	}
+}
+
+func (e *cmpExpr) columns(dst []string) []string { return append(dst, e.column) }
+
+type nullExpr struct {
+	column string
+	null   bool
+}
+
+func (e *nullExpr) match(value func(string) any) bool {
+	return (value(e.column) == nil) == e.null
+}
+
+func (e *nullExpr) mayMatch(stats func(string) *columnStats) bool {
+	s := stats(e.column)
+	if s == nil || !s.hasNulls {
+		return true
+	}
+	if e.null {
+		return s.nulls > 0
+	}
+	return !s.allNull()
+}
+
+func (e *nullExpr) columns(dst []string) []string { return append(dst, e.column) }
+
+type logicalExpr struct {
+	or     bool
+	exprs  []Expr
+	column string // set by In, so the column is checked even for an empty list
+}
+
+func (e *logicalExpr) match(value func(string) any) bool {
+	for _, x := range e.exprs {
+		if x.match(value) == e.or {
+			return e.or
+		}
+	}
+	return !e.or
+}
+
+func (e *logicalExpr) mayMatch(stats func(string) *columnStats) bool {
+	for _, x := range e.exprs {
+		if x.mayMatch(stats) == e.or {
+			return e.or
+		}
+	}
+	return !e.or
+}
+
+func (e *logicalExpr) columns(dst []string) []string {
+	if e.column != "" {
+		dst = append(dst, e.column)
+	}
+	for _, x := range e.exprs {
+		dst = x.columns(dst)
+	}
+	return dst
+}
+
+// columnStats is the row-group statistics of one column chunk
+type columnStats struct {
+	rows      int64
+	hasMinMax bool
+	min, max  any
+	hasNulls  bool
+	nulls     int64
+}
+
+func (s *columnStats) allNull() bool {
+	return s.hasNulls && s.nulls == s.rows
+}
+
+// rowGroupStats returns statistics of a top-level primitive column, nil if unknown
+func rowGroupStats(rg *metadata.RowGroupMetaData, leaf int) *columnStats {
+	chunk, err := rg.ColumnChunk(leaf)
+	if err != nil {
+		return nil
+	}
+	st, err := chunk.Statistics()
+	if err != nil || st == nil {
+		return nil
+	}
+
+	s := &columnStats{rows: rg.NumRows(), hasNulls: st.HasNullCount(), nulls: st.NullCount()}
+	if !st.HasMinMax() {
+		return s
+	}
+	s.hasMinMax = true
+	// uint columns store min/max of the unsigned values in signed physical types
+	unsigned := rg.Schema.Column(leaf).SortOrder() == schema.SortUNSIGNED
+	switch st := st.(type) {
+	case *metadata.Int32Statistics:
+		if unsigned {
+			s.min, s.max = int64(uint32(st.Min())), int64(uint32(st.Max()))
+		} else {
+			s.min, s.max = int64(st.Min()), int64(st.Max())
+		}
+	case *metadata.Int64Statistics:
+		if unsigned && uint64(st.Max()) > math.MaxInt64 {
+			// beyond int64: not comparable exactly - treat as unknown
+			s.hasMinMax = false
+		} else {
+			s.min, s.max = st.Min(), st.Max()
+		}
+	case *metadata.Float32Statistics:
+		s.min, s.max = float64(st.Min()), float64(st.Max())
+	case *metadata.Float64Statistics:
+		s.min, s.max = st.Min(), st.Max()
+	case *metadata.BooleanStatistics:
+		s.min, s.max = st.Min(), st.Max()
+	case *metadata.ByteArrayStatistics:
+		s.min, s.max = string(st.Min()), string(st.Max())
+	default:
+		s.hasMinMax = false
+	}
+	return s
+}
+
+// normalizeValue converts Go values to int64, float64, string or bool where possible
+func normalizeValue(v any) any {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return normalizeValue(uint64(v))
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			return float64(v)
+		}
+		return int64(v)
+	case float32:
+		return float64(v)
+	case []byte:
+		return string(v)
+	case parquet.ByteArray:
+		return string(v)
+	}
+	return v
+}
+
+// compareValues compares two normalized values; ok is false if they are not comparable
+func compareValues(a, b any) (c int, ok bool) {
+	switch a := a.(type) {
+	case int64:
+		switch b := b.(type) {
+		case int64:
+			return cmp.Compare(a, b), true
+		case float64:
+			return cmp.Compare(float64(a), b), true
+		}
+	case float64:
+		switch b := b.(type) {
+		case int64:
+			return cmp.Compare(a, float64(b)), true
+		case float64:
+			return cmp.Compare(a, b), true
+		}
+	case string:
+		if b, isStr := b.(string); isStr {
+			return strings.Compare(a, b), true
+		}
+	case bool:
+		if b, isBool := b.(bool); isBool {
+			switch {
+			case a == b:
+				return 0, true
+			case b:
+				return -1, true
+			default:
+				return 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// checkFilterValues reports filter values that can never be compared
+func checkFilterValues(e Expr) error {
+	switch e := e.(type) {
+	case *cmpExpr:
+		switch e.value.(type) {
+		case int64, float64, string, bool:
+			return nil
+		}
+		return fmt.Errorf("parquet filter: unsupported value %T for column %s", e.value, e.column)
+	case *logicalExpr:
+		for _, x := range e.exprs {
+			if err := checkFilterValues(x); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
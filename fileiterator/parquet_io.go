@@ -0,0 +1,270 @@
+package fileiterator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/apache/arrow/go/v14/parquet"
+	"github.com/apache/arrow/go/v14/parquet/compress"
+	"github.com/apache/arrow/go/v14/parquet/file"
+	"github.com/apache/arrow/go/v14/parquet/pqarrow"
+)
+
+// ParquetSnappy is the default Parquet page codec (see ParquetWriteOptions.Compression)
+const ParquetSnappy = "snappy"
+
+// parquetMagic starts and ends every Parquet file
+var parquetMagic = []byte("PAR1")
+
+// parquetCodecs maps codec names to Parquet page codecs
+var parquetCodecs = map[string]compress.Compression{
+	ParquetSnappy: compress.Codecs.Snappy,
+	CodecNone:     compress.Codecs.Uncompressed,
+	CodecGzip:     compress.Codecs.Gzip,
+	CodecZstd:     compress.Codecs.Zstd,
+	CodecLz4:      compress.Codecs.Lz4,
+	CodecBrotli:   compress.Codecs.Brotli,
+}
+
+// writerProperties builds Parquet writer properties from opts
+func (o ParquetWriteOptions) writerProperties() (*parquet.WriterProperties, error) {
+	name := o.Compression
+	if name == "" {
+		name = ParquetSnappy
+	}
+	codec, ok := parquetCodecs[name]
+	if !ok {
+		return nil, fmt.Errorf("parquet: unsupported compression %q", o.Compression)
+	}
+
+	props := []parquet.WriterProperty{
+		parquet.WithCompression(codec),
+		parquet.WithMaxRowGroupLength(int64(o.rowGroupSize())),
+		parquet.WithDictionaryDefault(!o.DisableDictionary),
+		parquet.WithStats(!o.DisableStatistics),
+	}
+	if o.CompressionLevel != 0 {
+		props = append(props, parquet.WithCompressionLevel(o.CompressionLevel))
+	}
+	if o.PageSize > 0 {
+		props = append(props, parquet.WithDataPageSize(o.PageSize))
+	}
+	for column, enabled := range o.DictionaryColumns {
+		props = append(props, parquet.WithDictionaryFor(column, enabled))
+	}
+	return parquet.NewWriterProperties(props...), nil
+}
+
+// rowGroupSize returns RowGroupSize or the 64K default
+func (o ParquetWriteOptions) rowGroupSize() int {
+	if o.RowGroupSize > 0 {
+		return o.RowGroupSize
+	}
+	return parquetBatchSize
+}
+
+// createParquetFile creates filename for a Parquet writer.
+// A compression suffix after .parquet/.pk (data.parquet.zst) selects the
+// Parquet page codec instead of compressing the whole file, so the result
+// stays readable by any Parquet tool; opts.Compression is set accordingly
+// unless already given. Codecs Parquet has no equivalent for (xz) still wrap the file.
+func createParquetFile(filename string, opts *ParquetWriteOptions) (io.WriteCloser, error) {
+	codec := codecFromSuffix(filename)
+	if _, internal := parquetCodecs[codec]; !internal || codec == CodecNone {
+		return Create(filename, CreateOptions{Atomic: opts.Atomic})
+	}
+
+	if opts.Compression == "" {
+		opts.Compression = codec
+		if opts.CompressionLevel == 0 && strings.HasSuffix(filename, ".zst1") {
+			opts.CompressionLevel = 1
+		}
+	}
+	return Create(filename, CreateOptions{Codec: CodecNone, Atomic: opts.Atomic})
+}
+
+// parquetFile is an open Parquet file; tmp is removed on Close
+type parquetFile struct {
+	*file.Reader
+	tmp string
+}
+
+func (p *parquetFile) Close() error {
+	err := p.Reader.Close()
+	if p.tmp != "" {
+		os.Remove(p.tmp)
+	}
+	return err
+}
+
+// openParquetFile opens filename for random access.
+// Plain local Parquet files are read in place. Anything else - URLs, stdin and
+// files compressed as a whole (.parquet.zst written by older versions) - is
+// decompressed into a temporary file first.
+func openParquetFile(filename string, opts ...file.ReadOption) (*parquetFile, error) {
+	if isPlainParquet(filename) {
+		pf, err := file.OpenParquetFile(filename, false, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &parquetFile{Reader: pf}, nil
+	}
+
+	src, err := Open(context.Background(), filename)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp("", "fileiterator-*.parquet")
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(tmp, src)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("parquet: %s: %w", filename, err)
+	}
+
+	pf, err := file.OpenParquetFile(tmp.Name(), false, opts...)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("parquet: %s: %w", filename, err)
+	}
+	return &parquetFile{Reader: pf, tmp: tmp.Name()}, nil
+}
+
+// isPlainParquet reports whether filename is a local file starting with the Parquet magic
+func isPlainParquet(filename string) bool {
+	if filename == "-" || isURL(filename) {
+		return false
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		// Let file.OpenParquetFile report the error
+		return true
+	}
+	defer f.Close()
+	head := make([]byte, len(parquetMagic))
+	if _, err := io.ReadFull(f, head); err != nil {
+		return true
+	}
+	return bytes.Equal(head, parquetMagic)
+}
+
+// newParquetWriter starts a Parquet writer on w; Close closes w as well.
+// On error w is aborted (see Abort), so an atomic destination is left untouched.
+// storeSchema embeds the Arrow schema so the file reads back with identical types.
+func newParquetWriter(schema *arrow.Schema, w io.WriteCloser, opts ParquetWriteOptions, storeSchema bool) (*pqarrow.FileWriter, error) {
+	props, err := opts.writerProperties()
+	if err != nil {
+		Abort(w)
+		return nil, err
+	}
+	arrowProps := pqarrow.DefaultWriterProps()
+	if storeSchema {
+		arrowProps = pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema())
+	}
+	writer, err := pqarrow.NewFileWriter(schema, w, props, arrowProps)
+	if err != nil {
+		Abort(w)
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(opts.Metadata))
+	for key := range opts.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := writer.AppendKeyValueMetadata(key, opts.Metadata[key]); err != nil {
+			abortParquetWriter(writer, w)
+			return nil, err
+		}
+	}
+	return writer, nil
+}
+
+// writeParquetRows writes n rows to filename; appendRow adds row i to the builder.
+// Every RowGroupSize rows become one row group.
+func writeParquetRows(filename string, schema *arrow.Schema, opts ParquetWriteOptions, n int, appendRow func(b *array.RecordBuilder, i int) error) error {
+	f, err := createParquetFile(filename, &opts)
+	if err != nil {
+		return err
+	}
+	writer, err := newParquetWriter(schema, f, opts, false)
+	if err != nil {
+		return err
+	}
+
+	builder := array.NewRecordBuilder(memory.NewGoAllocator(), schema)
+	defer builder.Release()
+
+	flush := func() error {
+		rec := builder.NewRecord()
+		defer rec.Release()
+		return writer.Write(rec)
+	}
+
+	rowGroupSize := opts.rowGroupSize()
+	rows := 0
+	for i := 0; i < n; i++ {
+		if err := appendRow(builder, i); err != nil {
+			abortParquetWriter(writer, f)
+			return err
+		}
+		rows++
+		if rows >= rowGroupSize {
+			if err := flush(); err != nil {
+				abortParquetWriter(writer, f)
+				return err
+			}
+			rows = 0
+		}
+	}
+	if rows > 0 {
+		if err := flush(); err != nil {
+			abortParquetWriter(writer, f)
+			return err
+		}
+	}
+
+	// Closes the underlying file as well
+	return writer.Close()
+}
+
+// ParquetMetadata returns the key/value metadata stored in a Parquet file footer
+func ParquetMetadata(filename string) (map[string]string, error) {
+	pf, err := openParquetFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer pf.Close()
+
+	kv := pf.MetaData().KeyValueMetadata()
+	meta := make(map[string]string, kv.Len())
+	for i, key := range kv.Keys() {
+		meta[key] = kv.Values()[i]
+	}
+	return meta, nil
+}
+
+// abortParquetWriter closes writer after an error; an atomic destination is
+// discarded first, so closing the writer does not rename it into place
+func abortParquetWriter(writer *pqarrow.FileWriter, dest io.WriteCloser) {
+	if a, ok := dest.(*AtomicWriter); ok {
+		a.Abort()
+	}
+	writer.Close()
+}
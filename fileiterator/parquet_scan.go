@@ -0,0 +1,310 @@
+package fileiterator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/apache/arrow/go/v14/parquet"
+	"github.com/apache/arrow/go/v14/parquet/file"
+	"github.com/apache/arrow/go/v14/parquet/pqarrow"
+)
+
+// ParquetReadOptions controls how Parquet files are scanned
+type ParquetReadOptions struct {
+	// BatchSize is the maximum number of rows decoded at once (0 = 64K).
+	// Memory use is bounded by BatchSize rows per row group in flight.
+	BatchSize int
+	// Parallel is the number of row groups decoded concurrently (0 or 1 = sequential).
+	// Records are still passed to the processor in file order, from a single goroutine.
+	Parallel int
+	// Columns limits decoding to the named top-level columns (nil = all columns)
+	Columns []string
+	// Filter passes only matching rows to the processor; row groups whose
+	// statistics rule out a match are skipped without decoding
+	Filter Expr
+}
+
+// DefaultParquetReadOptions returns sequential scanning in 64K-row batches
+func DefaultParquetReadOptions() ParquetReadOptions {
+	return ParquetReadOptions{
+		BatchSize: parquetBatchSize,
+		Parallel:  1,
+	}
+}
+
+// ParquetScanStats reports what a Parquet scan read
+type ParquetScanStats struct {
+	RowGroups        int   // row groups read
+	RowGroupsSkipped int   // row groups skipped by Filter using column statistics
+	Rows             int64 // rows passed to the processor
+}
+
+// parquetBufferSize is the read buffer per column chunk; pages are streamed
+// through it instead of loading whole column chunks into memory
+const parquetBufferSize = 1 << 20
+
+// IterateParquetAnyWithOptions is IterateParquetAny with control over batch size,
+// parallel row-group decoding, column projection and row filtering. Files are read one row group at a time,
+// so scanning needs constant memory regardless of file size.
+//
+// Example:
+//
+//	opts := fileiterator.DefaultParquetReadOptions()
+//	opts.Parallel = 4
+//	stats, err := fileiterator.IterateParquetAnyWithOptions("events.parquet", opts, func(rec map[string]any) error {
+//	    fmt.Println(rec["id"])
+//	    return nil
+//	})
+//
+// Projection and filtering:
+//
+//	opts.Columns = []string{"id", "country", "amount"}
+//	opts.Filter = fileiterator.And(fileiterator.Eq("country", "US"), fileiterator.Gt("amount", 100))
+func IterateParquetAnyWithOptions(filename string, opts ParquetReadOptions, processor func(map[string]any) error) (ParquetScanStats, error) {
+	var rowNum, passed int64
+	process := func(record map[string]any) error {
+		passed++
+		return processor(record)
+	}
+	if opts.Filter != nil {
+		// Columns read only for the filter are removed before the processor sees the record
+		var drop []string
+		if opts.Columns != nil {
+			for _, name := range opts.Filter.columns(nil) {
+				if !slices.Contains(opts.Columns, name) && !slices.Contains(drop, name) {
+					drop = append(drop, name)
+				}
+			}
+		}
+		process = func(record map[string]any) error {
+			if !opts.Filter.match(func(column string) any { return record[column] }) {
+				return nil
+			}
+			for _, name := range drop {
+				delete(record, name)
+			}
+			passed++
+			return processor(record)
+		}
+	}
+
+	stats, err := scanParquet(filename, opts, func(rec arrow.Record) error {
+		return processRecordMaps(rec, &rowNum, process)
+	})
+	stats.Rows = passed
+	return stats, err
+}
+
+// scanParquet calls fn for every record batch of filename, in file order.
+// Batches are only valid during the call.
+func scanParquet(filename string, opts ParquetReadOptions, fn func(arrow.Record) error) (ParquetScanStats, error) {
+	var stats ParquetScanStats
+
+	props := parquet.NewReaderProperties(memory.DefaultAllocator)
+	props.BufferedStreamEnabled = true
+	props.BufferSize = parquetBufferSize
+	pf, err := openParquetFile(filename, file.WithReadProps(props))
+	if err != nil {
+		return stats, err
+	}
+	defer pf.Close()
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = parquetBatchSize
+	}
+	reader, err := pqarrow.NewFileReader(pf.Reader, pqarrow.ArrowReadProperties{BatchSize: int64(batchSize)}, memory.NewGoAllocator())
+	if err != nil {
+		return stats, err
+	}
+
+	leaves, err := parquetLeaves(pf.Reader, opts)
+	if err != nil {
+		return stats, err
+	}
+	var colIndices []int
+	if opts.Columns != nil {
+		for _, idx := range leaves {
+			colIndices = append(colIndices, idx...)
+		}
+		slices.Sort(colIndices)
+	}
+
+	var rowGroups []int
+	for rg := 0; rg < pf.NumRowGroups(); rg++ {
+		if opts.Filter != nil && !opts.Filter.mayMatch(statsLookup(pf.Reader, rg, leaves)) {
+			stats.RowGroupsSkipped++
+			continue
+		}
+		rowGroups = append(rowGroups, rg)
+	}
+
+	if opts.Parallel > 1 && len(rowGroups) > 1 {
+		err = scanRowGroupsParallel(reader, colIndices, rowGroups, opts.Parallel, fn)
+	} else {
+		ctx := context.Background()
+		for _, rg := range rowGroups {
+			if err = readRowGroup(ctx, reader, colIndices, rg, fn); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return stats, err
+	}
+	stats.RowGroups = len(rowGroups)
+	return stats, nil
+}
+
+// parquetLeaves maps the columns a scan needs (Columns and Filter columns,
+// all columns if Columns is nil) to their leaf column indices
+func parquetLeaves(pf *file.Reader, opts ParquetReadOptions) (map[string][]int, error) {
+	schema := pf.MetaData().Schema
+	all := make(map[string][]int)
+	for i := 0; i < schema.NumColumns(); i++ {
+		name := schema.Column(i).ColumnPath()[0]
+		all[name] = append(all[name], i)
+	}
+
+	if opts.Filter != nil {
+		if err := checkFilterValues(opts.Filter); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Columns == nil && opts.Filter == nil {
+		return all, nil
+	}
+
+	names := slices.Clone(opts.Columns)
+	if opts.Filter != nil {
+		names = opts.Filter.columns(names)
+	}
+	if opts.Columns == nil {
+		// Everything is read, only check the filter columns
+		for _, name := range names {
+			if all[name] == nil {
+				return nil, fmt.Errorf("parquet: unknown column %q", name)
+			}
+		}
+		return all, nil
+	}
+	leaves := make(map[string][]int, len(names))
+	for _, name := range names {
+		if all[name] == nil {
+			return nil, fmt.Errorf("parquet: unknown column %q", name)
+		}
+		leaves[name] = all[name]
+	}
+	return leaves, nil
+}
+
+// statsLookup returns row-group statistics by column name;
+// only top-level primitive columns have usable statistics
+func statsLookup(pf *file.Reader, rg int, leaves map[string][]int) func(string) *columnStats {
+	meta := pf.MetaData().RowGroup(rg)
+	schema := pf.MetaData().Schema
+	return func(column string) *columnStats {
+		idx := leaves[column]
+		if len(idx) != 1 || len(schema.Column(idx[0]).ColumnPath()) != 1 {
+			return nil
+		}
+		return rowGroupStats(meta, idx[0])
+	}
+}
+
+// readRowGroup streams one row group in batches; colIndices nil reads all columns
+func readRowGroup(ctx context.Context, reader *pqarrow.FileReader, colIndices []int, rg int, fn func(arrow.Record) error) error {
+	rr, err := reader.GetRecordReader(ctx, colIndices, []int{rg})
+	if err != nil {
+		return err
+	}
+	defer rr.Release()
+
+	for rr.Next() {
+		if err := fn(rr.Record()); err != nil {
+			return err
+		}
+	}
+	if err := rr.Err(); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// scanRowGroupsParallel decodes up to workers row groups at once and passes
+// their batches to fn in row-group order
+func scanRowGroupsParallel(reader *pqarrow.FileReader, colIndices, rowGroups []int, workers int, fn func(arrow.Record) error) error {
+	type batch struct {
+		rec arrow.Record
+		err error
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	// One channel per row group, queued in file order
+	queue := make(chan chan batch, workers)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(queue)
+		sem := make(chan struct{}, workers)
+		for _, rg := range rowGroups {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			out := make(chan batch, 1)
+			select {
+			case queue <- out:
+			case <-ctx.Done():
+				return
+			}
+			wg.Add(1)
+			go func(rg int) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				defer close(out)
+				err := readRowGroup(ctx, reader, colIndices, rg, func(rec arrow.Record) error {
+					rec.Retain()
+					select {
+					case out <- batch{rec: rec}:
+						return nil
+					case <-ctx.Done():
+						rec.Release()
+						return ctx.Err()
+					}
+				})
+				if err != nil && ctx.Err() == nil {
+					select {
+					case out <- batch{err: err}:
+					case <-ctx.Done():
+					}
+				}
+			}(rg)
+		}
+	}()
+
+	for out := range queue {
+		for b := range out {
+			if b.err != nil {
+				return b.err
+			}
+			err := fn(b.rec)
+			b.rec.Release()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,626 @@
+package fileiterator_test
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/parquet/compress"
+	"github.com/apache/arrow/go/v14/parquet/file"
+	"github.com/parf/homebase-go-lib/fileiterator"
+)
+
+// standardRow mirrors fileiterator.ParquetRecord; WriteParquetTyped starts a
+// new row group every 64K rows
+type standardRow struct {
+	ID        int64   `parquet:"id"`
+	Name      string  `parquet:"name"`
+	Email     string  `parquet:"email"`
+	Age       int64   `parquet:"age"`
+	Score     float64 `parquet:"score"`
+	Active    bool    `parquet:"active"`
+	Category  string  `parquet:"category"`
+	Timestamp int64   `parquet:"timestamp"`
+}
+
+func writeRowGroups(t *testing.T, path string, n int) {
+	t.Helper()
+	rows := make([]standardRow, n)
+	for i := range rows {
+		rows[i] = standardRow{ID: int64(i), Name: "user", Age: int64(i % 90), Active: i%2 == 0}
+	}
+	if err := fileiterator.WriteParquetTyped(path, rows); err != nil {
+		t.Fatalf("WriteParquetTyped failed: %v", err)
+	}
+}
+
+func TestIterateParquetMultipleRowGroups(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "rows.parquet")
+	writeRowGroups(t, testFile, 150000)
+
+	next := int64(0)
+	err := fileiterator.IterateParquet(testFile, func(r fileiterator.ParquetRecord) error {
+		if r.ID != next || r.Active != (next%2 == 0) {
+			t.Fatalf("Unexpected record %+v, want id %d", r, next)
+		}
+		next++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateParquet failed: %v", err)
+	}
+	if next != 150000 {
+		t.Errorf("Expected 150000 records, got %d", next)
+	}
+
+	count := 0
+	err = fileiterator.IterateParquetAny(testFile, func(rec map[string]any) error {
+		if rec["id"].(int64) != int64(count) {
+			t.Fatalf("Expected id %d, got %v", count, rec["id"])
+		}
+		count++
+		return nil
+	})
+	if err != nil || count != 150000 {
+		t.Errorf("IterateParquetAny: %d records, err %v", count, err)
+	}
+}
+
+func TestIterateParquetAnyWithOptions(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "rows.parquet")
+	writeRowGroups(t, testFile, 200000)
+
+	for _, parallel := range []int{1, 4} {
+		opts := fileiterator.ParquetReadOptions{BatchSize: 1000, Parallel: parallel}
+		next := int64(0)
+		stats, err := fileiterator.IterateParquetAnyWithOptions(testFile, opts, func(rec map[string]any) error {
+			if rec["id"].(int64) != next {
+				t.Fatalf("Parallel %d: expected id %d, got %v", parallel, next, rec["id"])
+			}
+			next++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Parallel %d: IterateParquetAnyWithOptions failed: %v", parallel, err)
+		}
+		if stats.Rows != 200000 || stats.RowGroups != 4 || next != 200000 {
+			t.Errorf("Parallel %d: unexpected stats %+v, %d records", parallel, stats, next)
+		}
+	}
+}
+
+func TestIterateParquetAnyParallelProcessorError(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "rows.parquet")
+	writeRowGroups(t, testFile, 200000)
+
+	stop := errors.New("stop")
+	opts := fileiterator.ParquetReadOptions{BatchSize: 500, Parallel: 3}
+	processed := 0
+	stats, err := fileiterator.IterateParquetAnyWithOptions(testFile, opts, func(rec map[string]any) error {
+		if rec["id"].(int64) == 70000 {
+			return stop
+		}
+		processed++
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("Expected processor error, got %v", err)
+	}
+	if processed != 70000 || stats.Rows != 70001 {
+		t.Errorf("Expected 70000 records before the error, got %d (stats %+v)", processed, stats)
+	}
+}
+
+func TestIterateParquetAnyProjectionAndFilter(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "rows.parquet")
+	writeRowGroups(t, testFile, 200000)
+
+	opts := fileiterator.DefaultParquetReadOptions()
+	opts.Columns = []string{"name", "age"}
+	opts.Filter = fileiterator.And(fileiterator.Ge("id", 150000), fileiterator.In("age", 7, 8))
+	stats, err := fileiterator.IterateParquetAnyWithOptions(testFile, opts, func(rec map[string]any) error {
+		if len(rec) != 2 || rec["name"] != "user" {
+			t.Fatalf("Expected only projected columns, got %v", rec)
+		}
+		if age := rec["age"].(int64); age != 7 && age != 8 {
+			t.Fatalf("Filter let through age %d", age)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateParquetAnyWithOptions failed: %v", err)
+	}
+	// Row groups hold ids 0-65535 and 65536-131071 - ruled out by id statistics
+	if stats.RowGroupsSkipped != 2 || stats.RowGroups != 2 {
+		t.Errorf("Expected 2 row groups skipped and 2 read, got %+v", stats)
+	}
+	want := int64(0)
+	for id := 150000; id < 200000; id++ {
+		if age := id % 90; age == 7 || age == 8 {
+			want++
+		}
+	}
+	if stats.Rows != want {
+		t.Errorf("Expected %d rows, got %d", want, stats.Rows)
+	}
+
+	opts = fileiterator.DefaultParquetReadOptions()
+	opts.Filter = fileiterator.Or(fileiterator.Lt("id", 0), fileiterator.Eq("name", "nobody"))
+	stats, err = fileiterator.IterateParquetAnyWithOptions(testFile, opts, func(map[string]any) error {
+		t.Fatal("No row should match")
+		return nil
+	})
+	if err != nil || stats.RowGroupsSkipped != 4 || stats.Rows != 0 {
+		t.Errorf("Expected all row groups skipped, got %+v, %v", stats, err)
+	}
+
+	opts.Filter = fileiterator.Eq("missing", 1)
+	if _, err := fileiterator.IterateParquetAnyWithOptions(testFile, opts, func(map[string]any) error { return nil }); err == nil {
+		t.Error("Expected unknown column error")
+	}
+}
+
+func TestIterateParquetAnyNullFilter(t *testing.T) {
+	type Row struct {
+		ID   int64   `parquet:"id"`
+		Note *string `parquet:"note"`
+	}
+	testFile := filepath.Join(t.TempDir(), "notes.parquet")
+	rows := make([]Row, 100000)
+	note := "set"
+	for i := range rows {
+		rows[i].ID = int64(i)
+		if i >= 65536 && i%10 == 0 { // first row group is all NULL
+			rows[i].Note = &note
+		}
+	}
+	if err := fileiterator.WriteParquetTyped(testFile, rows); err != nil {
+		t.Fatalf("WriteParquetTyped failed: %v", err)
+	}
+
+	opts := fileiterator.DefaultParquetReadOptions()
+	opts.Filter = fileiterator.NotNull("note")
+	stats, err := fileiterator.IterateParquetAnyWithOptions(testFile, opts, func(rec map[string]any) error {
+		if rec["note"] != "set" {
+			t.Fatalf("Unexpected row %v", rec)
+		}
+		return nil
+	})
+	if err != nil || stats.RowGroupsSkipped != 1 || stats.Rows != 3446 {
+		t.Errorf("NotNull: got %+v, %v", stats, err)
+	}
+
+	opts.Filter = fileiterator.And(fileiterator.IsNull("note"), fileiterator.Lt("id", 10))
+	stats, err = fileiterator.IterateParquetAnyWithOptions(testFile, opts, func(map[string]any) error { return nil })
+	if err != nil || stats.RowGroupsSkipped != 1 || stats.Rows != 10 {
+		t.Errorf("IsNull: got %+v, %v", stats, err)
+	}
+}
+
+func readParquetAny(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	if err := fileiterator.IterateParquetAny(path, func(rec map[string]any) error {
+		records = append(records, rec)
+		return nil
+	}); err != nil {
+		t.Fatalf("IterateParquetAny failed: %v", err)
+	}
+	return records
+}
+
+func TestWriteParquetAnyInference(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "rich.parquet")
+	created := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	price, _ := fileiterator.ParseDecimal("19.99")
+	records := []map[string]any{
+		// NULLs in the first record do not decide the type
+		{"id": 1, "score": 10, "note": nil, "tags": []string{"a"}, "created": created, "price": price,
+			"day": arrow.Date32FromTime(created), "raw": []byte{1, 2}, "address": map[string]any{"city": "Paris"}},
+		{"id": 2, "score": 7.5, "note": "late", "tags": nil, "created": nil, "price": nil,
+			"day": created, "raw": nil, "address": map[string]any{"city": "Rome", "zip": 123}},
+	}
+	if err := fileiterator.WriteParquetAny(testFile, records); err != nil {
+		t.Fatalf("WriteParquetAny failed: %v", err)
+	}
+
+	got := readParquetAny(t, testFile)
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(got))
+	}
+	first, second := got[0], got[1]
+	if first["score"] != 10.0 || second["score"] != 7.5 {
+		t.Errorf("Expected int widened to float, got %v and %v", first["score"], second["score"])
+	}
+	if first["note"] != nil || second["note"] != "late" {
+		t.Errorf("Unexpected note: %v, %v", first["note"], second["note"])
+	}
+	if !reflect.DeepEqual(first["tags"], []any{"a"}) || second["tags"] != nil {
+		t.Errorf("Unexpected tags: %v, %v", first["tags"], second["tags"])
+	}
+	if ts, ok := first["created"].(time.Time); !ok || !ts.Equal(created) || second["created"] != nil {
+		t.Errorf("Unexpected created: %v, %v", first["created"], second["created"])
+	}
+	if d, ok := first["price"].(fileiterator.Decimal); !ok || d.String() != "19.99" {
+		t.Errorf("Unexpected price: %#v", first["price"])
+	}
+	if day, ok := second["day"].(time.Time); !ok || !day.Equal(created) {
+		t.Errorf("Expected date widened to timestamp, got %v", second["day"])
+	}
+	if !reflect.DeepEqual(first["raw"], []byte{1, 2}) {
+		t.Errorf("Unexpected raw: %v", first["raw"])
+	}
+	wantAddr := map[string]any{"city": "Rome", "zip": int64(123)}
+	if !reflect.DeepEqual(second["address"], wantAddr) || first["address"].(map[string]any)["zip"] != nil {
+		t.Errorf("Unexpected address: %v, %v", first["address"], second["address"])
+	}
+}
+
+func TestWriteParquetAnyIncompatibleTypesFallBackToString(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "mixed.parquet")
+	records := []map[string]any{{"v": 1}, {"v": "two"}, {"v": []int{3}}}
+	if err := fileiterator.WriteParquetAny(testFile, records); err != nil {
+		t.Fatalf("WriteParquetAny failed: %v", err)
+	}
+	got := readParquetAny(t, testFile)
+	if got[0]["v"] != "1" || got[1]["v"] != "two" || got[2]["v"] != "[3]" {
+		t.Errorf("Unexpected values: %v", got)
+	}
+}
+
+func TestWriteParquetAnyWithOptions(t *testing.T) {
+	tmpDir := t.TempDir()
+	records := []map[string]any{
+		{"id": 1, "price": "10.5"},
+		{"id": 2, "price": fileiterator.Decimal{Unscaled: big.NewInt(1234), Scale: 3}},
+	}
+
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "price", Type: &arrow.Decimal128Type{Precision: 10, Scale: 3}, Nullable: true},
+	}, nil)
+	testFile := filepath.Join(tmpDir, "schema.parquet")
+	opts := fileiterator.ParquetWriteOptions{Schema: schema}
+	if err := fileiterator.WriteParquetAnyWithOptions(testFile, records, opts); err != nil {
+		t.Fatalf("WriteParquetAnyWithOptions failed: %v", err)
+	}
+	got := readParquetAny(t, testFile)
+	if got[0]["price"].(fileiterator.Decimal).String() != "10.500" || got[1]["price"].(fileiterator.Decimal).String() != "1.234" {
+		t.Errorf("Unexpected prices: %v", got)
+	}
+
+	records = append(records, map[string]any{"id": 3, "extra": true})
+	if err := fileiterator.WriteParquetAnyWithOptions(testFile, records, opts); err == nil {
+		t.Error("Expected error for field outside the schema")
+	}
+
+	// Schema from the first record only: later floats do not fit the int column
+	opts = fileiterator.ParquetWriteOptions{SampleSize: 1}
+	records = []map[string]any{{"n": 1}, {"n": "x"}}
+	if err := fileiterator.WriteParquetAnyWithOptions(filepath.Join(tmpDir, "sample.parquet"), records, opts); err == nil {
+		t.Error("Expected conversion error outside the sample")
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	for in, want := range map[string]string{"19.99": "19.99", "-0.05": "-0.05", "+7": "7", ".5": "0.5", "100.00": "100.00"} {
+		d, err := fileiterator.ParseDecimal(in)
+		if err != nil || d.String() != want {
+			t.Errorf("ParseDecimal(%q) = %v, %v; want %s", in, d, err, want)
+		}
+	}
+	for _, in := range []string{"", "1.2.3", "1e5", "abc"} {
+		if _, err := fileiterator.ParseDecimal(in); err == nil {
+			t.Errorf("ParseDecimal(%q): expected error", in)
+		}
+	}
+}
+
+func makeRecords(n int) []map[string]any {
+	records := make([]map[string]any, n)
+	for i := range records {
+		records[i] = map[string]any{"id": i, "category": []string{"a", "b", "c"}[i%3], "payload": "row"}
+	}
+	return records
+}
+
+func TestWriteParquetAnyWriterOptions(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "opts.parquet")
+	opts := fileiterator.ParquetWriteOptions{
+		Compression:       fileiterator.CodecZstd,
+		CompressionLevel:  5,
+		RowGroupSize:      1000,
+		PageSize:          4096,
+		DisableDictionary: true,
+		DictionaryColumns: map[string]bool{"category": true},
+		DisableStatistics: true,
+		Metadata:          map[string]string{"source": "test", "version": "2"},
+	}
+	if err := fileiterator.WriteParquetAnyWithOptions(testFile, makeRecords(2500), opts); err != nil {
+		t.Fatalf("WriteParquetAnyWithOptions failed: %v", err)
+	}
+
+	pf, err := file.OpenParquetFile(testFile, false)
+	if err != nil {
+		t.Fatalf("OpenParquetFile failed: %v", err)
+	}
+	defer pf.Close()
+	if pf.NumRowGroups() != 3 {
+		t.Errorf("Expected 3 row groups, got %d", pf.NumRowGroups())
+	}
+	rg := pf.MetaData().RowGroup(0)
+	schema := pf.MetaData().Schema
+	for i := 0; i < schema.NumColumns(); i++ {
+		chunk, _ := rg.ColumnChunk(i)
+		name := schema.Column(i).Name()
+		if chunk.Compression() != compress.Codecs.Zstd {
+			t.Errorf("Column %s: expected zstd, got %v", name, chunk.Compression())
+		}
+		if chunk.HasDictionaryPage() != (name == "category") {
+			t.Errorf("Column %s: dictionary page %v", name, chunk.HasDictionaryPage())
+		}
+		if stats, _ := chunk.Statistics(); stats != nil {
+			t.Errorf("Column %s: expected no statistics", name)
+		}
+	}
+
+	meta, err := fileiterator.ParquetMetadata(testFile)
+	if err != nil || meta["source"] != "test" || meta["version"] != "2" {
+		t.Errorf("Unexpected metadata %v, %v", meta, err)
+	}
+
+	opts = fileiterator.ParquetWriteOptions{Compression: "lzo"}
+	if err := fileiterator.WriteParquetAnyWithOptions(testFile, makeRecords(1), opts); err == nil {
+		t.Error("Expected unsupported compression error")
+	}
+}
+
+func TestWriteParquetCompressionSuffix(t *testing.T) {
+	tmpDir := t.TempDir()
+	for name, codec := range map[string]compress.Compression{
+		"data.parquet":     compress.Codecs.Snappy,
+		"data.parquet.zst": compress.Codecs.Zstd,
+		"data.parquet.gz":  compress.Codecs.Gzip,
+		"data.pk.br":       compress.Codecs.Brotli,
+	} {
+		testFile := filepath.Join(tmpDir, name)
+		if err := fileiterator.WriteParquetAny(testFile, makeRecords(100)); err != nil {
+			t.Fatalf("%s: WriteParquetAny failed: %v", name, err)
+		}
+
+		// A regular Parquet file with compressed pages
+		pf, err := file.OpenParquetFile(testFile, false)
+		if err != nil {
+			t.Fatalf("%s: not a plain Parquet file: %v", name, err)
+		}
+		chunk, _ := pf.MetaData().RowGroup(0).ColumnChunk(0)
+		if chunk.Compression() != codec {
+			t.Errorf("%s: expected %v, got %v", name, codec, chunk.Compression())
+		}
+		pf.Close()
+
+		if n := len(readParquetAny(t, testFile)); n != 100 {
+			t.Errorf("%s: expected 100 records, got %d", name, n)
+		}
+	}
+}
+
+func TestIterateParquetWholeFileCompressed(t *testing.T) {
+	tmpDir := t.TempDir()
+	plain := filepath.Join(tmpDir, "plain.parquet")
+	if err := fileiterator.WriteParquetAny(plain, makeRecords(500)); err != nil {
+		t.Fatalf("WriteParquetAny failed: %v", err)
+	}
+	data, _ := os.ReadFile(plain)
+
+	// Files written by older versions: the whole Parquet file compressed with zstd
+	legacy := filepath.Join(tmpDir, "legacy.parquet.zst")
+	w, err := fileiterator.Create(legacy, fileiterator.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	w.Write(data)
+	w.Close()
+	raw, _ := os.ReadFile(legacy)
+	if bytes.HasPrefix(raw, []byte("PAR1")) {
+		t.Fatal("Expected a compressed file")
+	}
+
+	if n := len(readParquetAny(t, legacy)); n != 500 {
+		t.Errorf("Expected 500 records, got %d", n)
+	}
+	r, err := fileiterator.OpenRecordReader(legacy)
+	if err != nil {
+		t.Fatalf("OpenRecordReader failed: %v", err)
+	}
+	defer r.Close()
+	if rec, err := r.Read(); err != nil || rec["id"] != int64(0) {
+		t.Errorf("Unexpected first record %v, %v", rec, err)
+	}
+}
+
+func TestParquetWriterRowGroups(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "stream.parquet.zst")
+	opts := fileiterator.ParquetWriteOptions{RowGroupSize: 1000, SampleSize: 10}
+	w, err := fileiterator.CreateParquetWriter(testFile, opts)
+	if err != nil {
+		t.Fatalf("CreateParquetWriter failed: %v", err)
+	}
+	records := makeRecords(2500)
+	if err := w.WriteBatch(records[:1200]); err != nil {
+		t.Fatalf("WriteBatch failed: %v", err)
+	}
+	// Flush ends the second row group early
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	for _, rec := range records[1200:] {
+		if err := w.Write(rec); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := w.Write(records[0]); err == nil {
+		t.Error("Expected error writing to closed writer")
+	}
+
+	pf, err := file.OpenParquetFile(testFile, false)
+	if err != nil {
+		t.Fatalf("OpenParquetFile failed: %v", err)
+	}
+	defer pf.Close()
+	if pf.NumRowGroups() != 4 || pf.NumRows() != 2500 {
+		t.Errorf("Expected 4 row groups of 2500 rows, got %d of %d", pf.NumRowGroups(), pf.NumRows())
+	}
+	if chunk, _ := pf.MetaData().RowGroup(0).ColumnChunk(0); chunk.Compression() != compress.Codecs.Zstd {
+		t.Errorf("Expected zstd pages, got %v", chunk.Compression())
+	}
+	got := readParquetAny(t, testFile)
+	if got[2499]["id"] != int64(2499) || got[2499]["category"] != "a" {
+		t.Errorf("Unexpected last record %v", got[2499])
+	}
+}
+
+func TestParquetWriterSchemaEvolution(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "evolve.parquet")
+	w, err := fileiterator.CreateParquetWriter(testFile, fileiterator.ParquetWriteOptions{SampleSize: 2, RowGroupSize: 3})
+	if err != nil {
+		t.Fatalf("CreateParquetWriter failed: %v", err)
+	}
+	records := []map[string]any{
+		{"id": 1},
+		{"id": 2},
+		{"id": 3, "name": "c"},
+		{"id": 4, "name": "d", "geo": map[string]any{"lat": 1.5}},
+		{"id": 5, "tags": []string{"x"}},
+	}
+	if err := w.WriteBatch(records); err != nil {
+		t.Fatalf("WriteBatch failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	got := readParquetAny(t, testFile)
+	if len(got) != 5 {
+		t.Fatalf("Expected 5 records, got %d", len(got))
+	}
+	for i, rec := range got {
+		if len(rec) != 4 || rec["id"] != int64(i+1) {
+			t.Errorf("Record %d: expected all 4 columns, got %v", i, rec)
+		}
+	}
+	if got[0]["name"] != nil || got[2]["name"] != "c" || got[4]["name"] != nil {
+		t.Errorf("Unexpected names: %v", got)
+	}
+	if got[2]["geo"] != nil || !reflect.DeepEqual(got[3]["geo"], map[string]any{"lat": 1.5}) {
+		t.Errorf("Unexpected geo: %v, %v", got[2]["geo"], got[3]["geo"])
+	}
+	if got[3]["tags"] != nil || !reflect.DeepEqual(got[4]["tags"], []any{"x"}) {
+		t.Errorf("Unexpected tags: %v, %v", got[3]["tags"], got[4]["tags"])
+	}
+
+	// No temporary segments left behind
+	entries, _ := os.ReadDir(filepath.Dir(testFile))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the output file, got %d entries", len(entries))
+	}
+}
+
+func TestParquetWriterStream(t *testing.T) {
+	var buf bytes.Buffer
+	w := fileiterator.NewParquetWriter(&buf, fileiterator.ParquetWriteOptions{SampleSize: 1})
+	if err := w.WriteBatch([]map[string]any{{"id": 1}, {"id": 2, "name": "b"}}); err != nil {
+		t.Fatalf("WriteBatch failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("PAR1")) {
+		t.Fatal("Expected Parquet data in buffer")
+	}
+
+	testFile := filepath.Join(t.TempDir(), "stream.parquet")
+	os.WriteFile(testFile, buf.Bytes(), 0644)
+	got := readParquetAny(t, testFile)
+	if len(got) != 2 || got[0]["name"] != nil || got[1]["name"] != "b" {
+		t.Errorf("Unexpected records %v", got)
+	}
+}
+
+func TestParquetWriterErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	w, _ := fileiterator.CreateParquetWriter(filepath.Join(tmpDir, "empty.parquet"), fileiterator.DefaultParquetWriteOptions())
+	if err := w.Close(); err == nil {
+		t.Error("Expected error closing writer without records")
+	}
+
+	// Explicit schema: unknown fields are rejected
+	schema := arrow.NewSchema([]arrow.Field{{Name: "id", Type: arrow.PrimitiveTypes.Int64}}, nil)
+	testFile := filepath.Join(tmpDir, "schema.parquet")
+	w, err := fileiterator.CreateParquetWriter(testFile, fileiterator.ParquetWriteOptions{Schema: schema, SampleSize: 1})
+	if err != nil {
+		t.Fatalf("CreateParquetWriter failed: %v", err)
+	}
+	if err := w.Write(map[string]any{"id": 1}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Write(map[string]any{"id": 2, "extra": true}); err == nil {
+		t.Error("Expected error for field outside the schema")
+	}
+	// The error is sticky and Close removes the partial file
+	if err := w.Write(map[string]any{"id": 3}); err == nil {
+		t.Error("Expected error after failed write")
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Expected Close to report the write error")
+	}
+	if _, err := os.Stat(testFile); !os.IsNotExist(err) {
+		t.Errorf("Expected partial file removed, got %v", err)
+	}
+}
+
+func TestParquetWriterIntColumnRange(t *testing.T) {
+	tmpDir := t.TempDir()
+	tests := []struct {
+		name  string
+		value any
+		ok    bool
+	}{
+		{"whole float", 3.0, true},
+		{"fraction", 2.75, false},
+		{"NaN", math.NaN(), false},
+		{"uint64 max", uint64(math.MaxUint64), false},
+		{"uint64 in range", uint64(math.MaxInt64), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the schema (int64) is inferred from the first two records
+			w, err := fileiterator.CreateParquetWriter(filepath.Join(tmpDir, "ints.parquet"), fileiterator.ParquetWriteOptions{SampleSize: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write(map[string]any{"n": int64(1)})
+			w.Write(map[string]any{"n": int64(2)})
+			err = w.Write(map[string]any{"n": tt.value})
+			if err == nil {
+				err = w.Close()
+			} else {
+				w.Close()
+			}
+			if (err == nil) != tt.ok {
+				t.Errorf("Write(%v): err = %v, want ok=%v", tt.value, err, tt.ok)
+			}
+		})
+	}
+}
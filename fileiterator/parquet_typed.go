@@ -0,0 +1,475 @@
+package fileiterator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+)
+
+// Struct <-> Parquet mapping used by IterateParquetTyped and WriteParquetTyped:
+//
+//	type Order struct {
+//	    ID      int64      `parquet:"id"`
+//	    Note    *string    `parquet:"note"`    // pointer - nullable column
+//	    Created time.Time  `parquet:"created"` // timestamp (microseconds, UTC)
+//	    Items   []Item     `parquet:"items"`   // list
+//	    Address Address    `parquet:"address"` // struct
+//	    Secret  string     `parquet:"-"`       // skipped
+//	}
+//
+// Fields without a tag use the Go field name. Unexported fields are skipped.
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// parquetTimestampType is the Arrow type written for time.Time fields
+var parquetTimestampType = &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+
+// structField is an exported struct field mapped to a Parquet column
+type structField struct {
+	name  string
+	index int
+	typ   reflect.Type
+}
+
+var structFieldsCache sync.Map // reflect.Type -> []structField
+
+// structFields returns the mapped fields of struct type t
+func structFields(t reflect.Type) []structField {
+	if cached, ok := structFieldsCache.Load(t); ok {
+		return cached.([]structField)
+	}
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("parquet"); ok {
+			tag, _, _ = strings.Cut(tag, ",")
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, structField{name: name, index: i, typ: f.Type})
+	}
+	structFieldsCache.Store(t, fields)
+	return fields
+}
+
+// ParquetSchemaOf returns the Arrow schema WriteParquetTyped uses for struct type T
+func ParquetSchemaOf[T any]() (*arrow.Schema, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("parquet: %s is not a struct", t)
+	}
+	st, err := arrowStructOf(t, map[reflect.Type]bool{})
+	if err != nil {
+		return nil, err
+	}
+	return arrow.NewSchema(st.Fields(), nil), nil
+}
+
+// arrowStructOf maps a struct type; mapping holds the struct types being mapped,
+// so self-referential types fail instead of recursing forever
+func arrowStructOf(t reflect.Type, mapping map[reflect.Type]bool) (*arrow.StructType, error) {
+	if mapping[t] {
+		return nil, fmt.Errorf("recursive type %s", t)
+	}
+	mapping[t] = true
+	defer delete(mapping, t)
+
+	var fields []arrow.Field
+	for _, f := range structFields(t) {
+		dt, nullable, err := arrowTypeOf(f.typ, mapping)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.name, err)
+		}
+		fields = append(fields, arrow.Field{Name: f.name, Type: dt, Nullable: nullable})
+	}
+	return arrow.StructOf(fields...), nil
+}
+
+// arrowTypeOf maps a Go type to an Arrow type; pointers, slices and []byte are nullable
+func arrowTypeOf(t reflect.Type, mapping map[reflect.Type]bool) (arrow.DataType, bool, error) {
+	nullable := false
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+	if t == timeType {
+		return parquetTimestampType, nullable, nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return arrow.FixedWidthTypes.Boolean, nullable, nil
+	case reflect.Int, reflect.Int64:
+		return arrow.PrimitiveTypes.Int64, nullable, nil
+	case reflect.Int32:
+		return arrow.PrimitiveTypes.Int32, nullable, nil
+	case reflect.Int16:
+		return arrow.PrimitiveTypes.Int16, nullable, nil
+	case reflect.Int8:
+		return arrow.PrimitiveTypes.Int8, nullable, nil
+	case reflect.Uint, reflect.Uint64:
+		return arrow.PrimitiveTypes.Uint64, nullable, nil
+	case reflect.Uint32:
+		return arrow.PrimitiveTypes.Uint32, nullable, nil
+	case reflect.Uint16:
+		return arrow.PrimitiveTypes.Uint16, nullable, nil
+	case reflect.Uint8:
+		return arrow.PrimitiveTypes.Uint8, nullable, nil
+	case reflect.Float64:
+		return arrow.PrimitiveTypes.Float64, nullable, nil
+	case reflect.Float32:
+		return arrow.PrimitiveTypes.Float32, nullable, nil
+	case reflect.String:
+		return arrow.BinaryTypes.String, nullable, nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return arrow.BinaryTypes.Binary, true, nil
+		}
+		elem, elemNullable, err := arrowTypeOf(t.Elem(), mapping)
+		if err != nil {
+			return nil, false, err
+		}
+		return arrow.ListOfField(arrow.Field{Name: "element", Type: elem, Nullable: elemNullable}), true, nil
+	case reflect.Struct:
+		st, err := arrowStructOf(t, mapping)
+		return st, nullable, err
+	}
+	return nil, false, fmt.Errorf("unsupported type %s", t)
+}
+
+// appendReflect appends Go value v to builder b
+func appendReflect(b array.Builder, v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			b.AppendNull()
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch b := b.(type) {
+	case *array.BooleanBuilder:
+		b.Append(v.Bool())
+	case *array.Int64Builder:
+		b.Append(v.Int())
+	case *array.Int32Builder:
+		b.Append(int32(v.Int()))
+	case *array.Int16Builder:
+		b.Append(int16(v.Int()))
+	case *array.Int8Builder:
+		b.Append(int8(v.Int()))
+	case *array.Uint64Builder:
+		b.Append(v.Uint())
+	case *array.Uint32Builder:
+		b.Append(uint32(v.Uint()))
+	case *array.Uint16Builder:
+		b.Append(uint16(v.Uint()))
+	case *array.Uint8Builder:
+		b.Append(uint8(v.Uint()))
+	case *array.Float64Builder:
+		b.Append(v.Float())
+	case *array.Float32Builder:
+		b.Append(float32(v.Float()))
+	case *array.StringBuilder:
+		b.Append(v.String())
+	case *array.BinaryBuilder:
+		if v.IsNil() {
+			b.AppendNull()
+		} else {
+			b.Append(v.Bytes())
+		}
+	case *array.TimestampBuilder:
+		b.Append(arrow.Timestamp(v.Interface().(time.Time).UnixMicro()))
+	case *array.ListBuilder:
+		if v.IsNil() {
+			b.AppendNull()
+			return nil
+		}
+		b.Append(true)
+		vb := b.ValueBuilder()
+		for i := 0; i < v.Len(); i++ {
+			if err := appendReflect(vb, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case *array.StructBuilder:
+		b.Append(true)
+		for i, f := range structFields(v.Type()) {
+			if err := appendReflect(b.FieldBuilder(i), v.Field(f.index)); err != nil {
+				return fmt.Errorf("%s: %w", f.name, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported builder type: %T", b)
+	}
+	return nil
+}
+
+// WriteParquetTyped writes structs to a Parquet file with Snappy compression.
+// The schema comes from the struct fields and their `parquet:"name"` tags (see ParquetSchemaOf).
+// A .gz/.zst/.lz4/.br suffix selects that Parquet page codec instead (see ParquetWriteOptions)
+//
+// Example:
+//
+//	type User struct {
+//	    ID    int64   `parquet:"id"`
+//	    Name  string  `parquet:"name"`
+//	    Email *string `parquet:"email"`
+//	}
+//	err := fileiterator.WriteParquetTyped("users.parquet", users)
+func WriteParquetTyped[T any](filename string, records []T) error {
+	return WriteParquetTypedWithOptions(filename, records, DefaultParquetWriteOptions())
+}
+
+// WriteParquetTypedWithOptions is WriteParquetTyped with compression, layout and atomic write settings
+// (the schema always comes from T; opts.Schema and opts.SampleSize are ignored)
+func WriteParquetTypedWithOptions[T any](filename string, records []T, opts ParquetWriteOptions) error {
+	schema, err := ParquetSchemaOf[T]()
+	if err != nil {
+		return err
+	}
+	fields := structFields(reflect.TypeOf((*T)(nil)).Elem())
+
+	return writeParquetRows(filename, schema, opts, len(records), func(builder *array.RecordBuilder, n int) error {
+		v := reflect.ValueOf(&records[n]).Elem()
+		for i, field := range fields {
+			if err := appendReflect(builder.Field(i), v.Field(field.index)); err != nil {
+				return fmt.Errorf("record %d: field %s: %w", n, field.name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// IterateParquetTyped reads a Parquet file into structs of type T, all row groups in batches.
+// Columns are matched to fields by `parquet:"name"` tags (or field names);
+// missing columns leave the field at its zero value, NULL sets pointers to nil.
+//
+// Example:
+//
+//	fileiterator.IterateParquetTyped("users.parquet", func(u User) error {
+//	    fmt.Println(u.ID, u.Name)
+//	    return nil
+//	})
+func IterateParquetTyped[T any](filename string, processor func(T) error) error {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("parquet: %s is not a struct", t)
+	}
+	fields := structFields(t)
+
+	// Column index for every struct field, -1 if the file has no such column
+	var columns []int
+	rowNum := 0
+	_, err := scanParquet(filename, DefaultParquetReadOptions(), func(rec arrow.Record) error {
+		if columns == nil {
+			columns = make([]int, len(fields))
+			for i, f := range fields {
+				columns[i] = -1
+				if idx := rec.Schema().FieldIndices(f.name); len(idx) > 0 {
+					columns[i] = idx[0]
+				}
+			}
+		}
+
+		for row := 0; row < int(rec.NumRows()); row++ {
+			rowNum++
+			var obj T
+			v := reflect.ValueOf(&obj).Elem()
+			for i, f := range fields {
+				if columns[i] < 0 {
+					continue
+				}
+				if err := assignReflect(rec.Column(columns[i]), row, v.Field(f.index)); err != nil {
+					return fmt.Errorf("row %d: column %s: %w", rowNum, f.name, err)
+				}
+			}
+			if err := processor(obj); err != nil {
+				return fmt.Errorf("row %d: processor error: %w", rowNum, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("File %s. Rows processed: %d\n", filename, rowNum)
+	return nil
+}
+
+// assignReflect stores element i of arr into dst, converting between compatible types
+func assignReflect(arr arrow.Array, i int, dst reflect.Value) error {
+	if arr.IsNull(i) {
+		dst.SetZero()
+		return nil
+	}
+	if dst.Kind() == reflect.Pointer {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+
+	if dst.Type() == timeType {
+		var t time.Time
+		switch a := arr.(type) {
+		case *array.Timestamp:
+			t = a.Value(i).ToTime(a.DataType().(*arrow.TimestampType).Unit)
+		case *array.Date32:
+			t = a.Value(i).ToTime()
+		case *array.Date64:
+			t = a.Value(i).ToTime()
+		default:
+			return fmt.Errorf("cannot assign %s to time.Time", arr.DataType())
+		}
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch a := arr.(type) {
+	case *array.Boolean:
+		if dst.Kind() != reflect.Bool {
+			return fmt.Errorf("cannot assign bool to %s", dst.Type())
+		}
+		dst.SetBool(a.Value(i))
+	case *array.Int64:
+		return setInt(dst, a.Value(i))
+	case *array.Int32:
+		return setInt(dst, int64(a.Value(i)))
+	case *array.Int16:
+		return setInt(dst, int64(a.Value(i)))
+	case *array.Int8:
+		return setInt(dst, int64(a.Value(i)))
+	case *array.Uint64:
+		return setUint(dst, a.Value(i))
+	case *array.Uint32:
+		return setUint(dst, uint64(a.Value(i)))
+	case *array.Uint16:
+		return setUint(dst, uint64(a.Value(i)))
+	case *array.Uint8:
+		return setUint(dst, uint64(a.Value(i)))
+	case *array.Float64:
+		return setFloat(dst, a.Value(i))
+	case *array.Float32:
+		return setFloat(dst, float64(a.Value(i)))
+	case *array.String:
+		return setBytes(dst, a.Value(i), nil)
+	case *array.LargeString:
+		return setBytes(dst, a.Value(i), nil)
+	case *array.Binary:
+		return setBytes(dst, "", a.Value(i))
+	case *array.Dictionary:
+		return assignReflect(a.Dictionary(), a.GetValueIndex(i), dst)
+	case *array.List:
+		if dst.Kind() != reflect.Slice {
+			return fmt.Errorf("cannot assign list to %s", dst.Type())
+		}
+		start, end := a.ValueOffsets(i)
+		values := a.ListValues()
+		slice := reflect.MakeSlice(dst.Type(), int(end-start), int(end-start))
+		for j := 0; j < slice.Len(); j++ {
+			if err := assignReflect(values, int(start)+j, slice.Index(j)); err != nil {
+				return err
+			}
+		}
+		dst.Set(slice)
+	case *array.Struct:
+		if dst.Kind() != reflect.Struct {
+			return fmt.Errorf("cannot assign struct to %s", dst.Type())
+		}
+		st := a.DataType().(*arrow.StructType)
+		for _, f := range structFields(dst.Type()) {
+			idx, ok := st.FieldIdx(f.name)
+			if !ok {
+				continue
+			}
+			if err := assignReflect(a.Field(idx), i, dst.Field(f.index)); err != nil {
+				return fmt.Errorf("%s: %w", f.name, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported array type: %T", arr)
+	}
+	return nil
+}
+
+func setInt(dst reflect.Value, v int64) error {
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if dst.OverflowInt(v) {
+			return fmt.Errorf("value %d overflows %s", v, dst.Type())
+		}
+		dst.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v < 0 || dst.OverflowUint(uint64(v)) {
+			return fmt.Errorf("value %d overflows %s", v, dst.Type())
+		}
+		dst.SetUint(uint64(v))
+	case reflect.Float32, reflect.Float64:
+		dst.SetFloat(float64(v))
+	default:
+		return fmt.Errorf("cannot assign integer to %s", dst.Type())
+	}
+	return nil
+}
+
+func setUint(dst reflect.Value, v uint64) error {
+	switch dst.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if dst.OverflowUint(v) {
+			return fmt.Errorf("value %d overflows %s", v, dst.Type())
+		}
+		dst.SetUint(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v > 1<<63-1 || dst.OverflowInt(int64(v)) {
+			return fmt.Errorf("value %d overflows %s", v, dst.Type())
+		}
+		dst.SetInt(int64(v))
+	case reflect.Float32, reflect.Float64:
+		dst.SetFloat(float64(v))
+	default:
+		return fmt.Errorf("cannot assign integer to %s", dst.Type())
+	}
+	return nil
+}
+
+func setFloat(dst reflect.Value, v float64) error {
+	if dst.Kind() != reflect.Float32 && dst.Kind() != reflect.Float64 {
+		return fmt.Errorf("cannot assign float to %s", dst.Type())
+	}
+	dst.SetFloat(v)
+	return nil
+}
+
+// setBytes assigns a string (s) or binary (b) value to a string or []byte field
+func setBytes(dst reflect.Value, s string, b []byte) error {
+	switch {
+	case dst.Kind() == reflect.String:
+		if b != nil {
+			s = string(b)
+		}
+		dst.SetString(s)
+	case dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() == reflect.Uint8:
+		if b == nil {
+			b = []byte(s)
+		} else {
+			b = append([]byte(nil), b...)
+		}
+		dst.SetBytes(b)
+	default:
+		return fmt.Errorf("cannot assign string to %s", dst.Type())
+	}
+	return nil
+}
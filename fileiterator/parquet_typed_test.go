@@ -0,0 +1,232 @@
+package fileiterator_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parf/homebase-go-lib/fileiterator"
+)
+
+type typedAddress struct {
+	City string `parquet:"city"`
+	Zip  *int32 `parquet:"zip"`
+}
+
+type typedItem struct {
+	SKU   string  `parquet:"sku"`
+	Price float64 `parquet:"price"`
+}
+
+type typedOrder struct {
+	ID       int64         `parquet:"id"`
+	Customer string        `parquet:"customer"`
+	Note     *string       `parquet:"note"`
+	Created  time.Time     `parquet:"created"`
+	Tags     []string      `parquet:"tags"`
+	Items    []typedItem   `parquet:"items"`
+	Address  typedAddress  `parquet:"address"`
+	Billing  *typedAddress `parquet:"billing"`
+	Payload  []byte        `parquet:"payload"`
+	Qty      uint16        `parquet:"qty"`
+	Secret   string        `parquet:"-"`
+	internal int
+}
+
+func makeOrders(n int) []typedOrder {
+	base := time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC)
+	orders := make([]typedOrder, n)
+	for i := range orders {
+		zip := int32(10000 + i)
+		o := typedOrder{
+			ID:       int64(i + 1),
+			Customer: "customer",
+			Created:  base.Add(time.Duration(i) * time.Minute),
+			Address:  typedAddress{City: "Springfield", Zip: &zip},
+			Qty:      uint16(i % 100),
+		}
+		if i%2 == 0 {
+			note := "fragile"
+			o.Note = &note
+			o.Tags = []string{"a", "b"}
+			o.Items = []typedItem{{SKU: "X1", Price: 9.5}, {SKU: "Y2", Price: 1}}
+			o.Billing = &typedAddress{City: "Shelbyville"}
+			o.Payload = []byte{1, 2, 3}
+		}
+		orders[i] = o
+	}
+	return orders
+}
+
+func TestParquetTypedRoundTrip(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "orders.parquet")
+	orders := makeOrders(10)
+	orders[0].Secret = "not written"
+
+	if err := fileiterator.WriteParquetTyped(testFile, orders); err != nil {
+		t.Fatalf("WriteParquetTyped failed: %v", err)
+	}
+
+	var got []typedOrder
+	err := fileiterator.IterateParquetTyped(testFile, func(o typedOrder) error {
+		got = append(got, o)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateParquetTyped failed: %v", err)
+	}
+
+	orders[0].Secret = ""
+	if len(got) != len(orders) {
+		t.Fatalf("Expected %d orders, got %d", len(orders), len(got))
+	}
+	for i := range orders {
+		if !got[i].Created.Equal(orders[i].Created) {
+			t.Errorf("Order %d: created %v, want %v", i, got[i].Created, orders[i].Created)
+		}
+		got[i].Created = orders[i].Created
+		if !reflect.DeepEqual(got[i], orders[i]) {
+			t.Errorf("Order %d:\n got  %+v\n want %+v", i, got[i], orders[i])
+		}
+	}
+}
+
+func TestParquetTypedManyRowGroups(t *testing.T) {
+	type Row struct {
+		ID  int64  `parquet:"id"`
+		Val string `parquet:"val"`
+	}
+	testFile := filepath.Join(t.TempDir(), "rows.parquet")
+	rows := make([]Row, 150000) // more than one row group / batch
+	for i := range rows {
+		rows[i] = Row{ID: int64(i), Val: "v"}
+	}
+	if err := fileiterator.WriteParquetTyped(testFile, rows); err != nil {
+		t.Fatalf("WriteParquetTyped failed: %v", err)
+	}
+
+	next := int64(0)
+	err := fileiterator.IterateParquetTyped(testFile, func(r Row) error {
+		if r.ID != next {
+			t.Fatalf("Expected id %d, got %d", next, r.ID)
+		}
+		next++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateParquetTyped failed: %v", err)
+	}
+	if next != int64(len(rows)) {
+		t.Errorf("Expected %d rows, got %d", len(rows), next)
+	}
+}
+
+func TestParquetTypedReadsMapWrittenFile(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "any.parquet")
+	records := []map[string]any{
+		{"id": 1, "name": "Alice", "score": 9.5},
+		{"id": 2, "name": "Bob", "score": 7.0},
+	}
+	if err := fileiterator.WriteParquetAny(testFile, records); err != nil {
+		t.Fatalf("WriteParquetAny failed: %v", err)
+	}
+
+	type User struct {
+		ID      int32    `parquet:"id"`
+		Name    string   `parquet:"name"`
+		Score   *float64 `parquet:"score"`
+		Missing string   `parquet:"missing"`
+	}
+	var users []User
+	err := fileiterator.IterateParquetTyped(testFile, func(u User) error {
+		users = append(users, u)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateParquetTyped failed: %v", err)
+	}
+	if len(users) != 2 || users[0].ID != 1 || users[1].Name != "Bob" || *users[0].Score != 9.5 || *users[1].Score != 7 {
+		t.Errorf("Unexpected users: %+v", users)
+	}
+}
+
+func TestParquetSchemaOf(t *testing.T) {
+	schema, err := fileiterator.ParquetSchemaOf[typedOrder]()
+	if err != nil {
+		t.Fatalf("ParquetSchemaOf failed: %v", err)
+	}
+	if schema.NumFields() != 10 {
+		t.Errorf("Expected 10 fields, got %d: %v", schema.NumFields(), schema)
+	}
+	note, _ := schema.FieldsByName("note")
+	id, _ := schema.FieldsByName("id")
+	if !note[0].Nullable || id[0].Nullable {
+		t.Errorf("Expected pointer fields nullable, plain fields required: %v", schema)
+	}
+
+	if _, err := fileiterator.ParquetSchemaOf[struct{ M map[string]int }](); err == nil {
+		t.Error("Expected error for map field")
+	}
+}
+
+type treeNode struct {
+	Name string
+	Kids []treeNode
+}
+
+type listNode struct {
+	ID   int
+	Next *listNode
+}
+
+type typedPoint struct{ X, Y int }
+
+func TestParquetSchemaOfRecursiveType(t *testing.T) {
+	if _, err := fileiterator.ParquetSchemaOf[treeNode](); err == nil || !strings.Contains(err.Error(), "recursive type") {
+		t.Errorf("Expected recursive type error for []treeNode, got %v", err)
+	}
+	if _, err := fileiterator.ParquetSchemaOf[listNode](); err == nil || !strings.Contains(err.Error(), "recursive type") {
+		t.Errorf("Expected recursive type error for *listNode, got %v", err)
+	}
+	err := fileiterator.WriteParquetTyped(filepath.Join(t.TempDir(), "tree.parquet"), []treeNode{{Name: "root"}})
+	if err == nil {
+		t.Error("Expected WriteParquetTyped error for a recursive type")
+	}
+
+	// The same struct type in sibling fields is not recursion
+	if _, err := fileiterator.ParquetSchemaOf[struct{ From, To typedPoint }](); err != nil {
+		t.Errorf("ParquetSchemaOf of repeated struct fields failed: %v", err)
+	}
+}
+
+func TestParquetFilterUnsignedStats(t *testing.T) {
+	type row struct {
+		Small uint32 `parquet:"small"`
+		Big   uint64 `parquet:"big"`
+	}
+	testFile := filepath.Join(t.TempDir(), "uints.parquet")
+	// values above the signed range look negative to signed statistics
+	rows := []row{{1, 1}, {3_000_000_000, 1<<63 + 5}}
+	if err := fileiterator.WriteParquetTyped(testFile, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, filter := range []fileiterator.Expr{
+		fileiterator.Eq("small", uint32(3_000_000_000)),
+		fileiterator.Gt("small", 2),
+		fileiterator.Eq("big", uint64(1<<63+5)),
+		fileiterator.Gt("big", 2),
+	} {
+		opts := fileiterator.DefaultParquetReadOptions()
+		opts.Filter = filter
+		stats, err := fileiterator.IterateParquetAnyWithOptions(testFile, opts, func(map[string]any) error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Rows != 1 {
+			t.Errorf("Filter %v: %d rows (%+v), want 1", filter, stats.Rows, stats)
+		}
+	}
+}
@@ -0,0 +1,469 @@
+package fileiterator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/apache/arrow/go/v14/parquet/pqarrow"
+)
+
+// ParquetWriter writes generic records to Parquet one at a time, so streams of
+// any size can be converted in constant memory.
+//
+// Rows are buffered into row groups of opts.RowGroupSize rows. The schema is
+// inferred from the first opts.SampleSize records (0 = 10,000) or taken from
+// opts.Schema. Fields first seen after that are added as nullable columns;
+// rows written before read them as NULL. Evolving the schema rewrites the
+// data written so far once, when the writer is closed.
+//
+// Example:
+//
+//	w, err := fileiterator.CreateParquetWriter("events.parquet.zst", fileiterator.DefaultParquetWriteOptions())
+//	if err != nil {
+//	    return err
+//	}
+//	for event := range events {
+//	    if err := w.Write(event); err != nil {
+//	        w.Close()
+//	        return err
+//	    }
+//	}
+//	return w.Close()
+type ParquetWriter struct {
+	opts       ParquetWriteOptions
+	sampleSize int
+	filename   string         // destination file, "" when writing to out
+	out        io.Writer      // destination stream, not closed
+	dest       io.WriteCloser // destination file created up front
+	atomic     *AtomicWriter  // dest in atomic mode until it is renamed into place or becomes a segment
+	atomicMode bool           // the destination is written atomically (ParquetWriteOptions.Atomic)
+
+	pending    []map[string]any // records buffered until the schema is fixed
+	schema     *arrow.Schema
+	fieldIndex map[string]int
+	writer     *pqarrow.FileWriter
+	builder    *array.RecordBuilder
+	rows       int // rows in builder
+
+	segment  string   // temp file of the current segment, "" if writing to dest
+	segments []string // finished segments with older schemas
+	err      error    // first write error; the writer cannot continue after it
+	closed   bool
+}
+
+// CreateParquetWriter creates filename and returns a streaming Parquet writer.
+// Compression suffixes select the Parquet page codec (see ParquetWriteOptions).
+func CreateParquetWriter(filename string, opts ParquetWriteOptions) (*ParquetWriter, error) {
+	dest, err := createParquetFile(filename, &opts)
+	if err != nil {
+		return nil, err
+	}
+	w := newParquetWriterFor(opts)
+	w.filename = filename
+	w.dest = dest
+	w.atomic, w.atomicMode = dest.(*AtomicWriter)
+	return w, nil
+}
+
+// NewParquetWriter returns a streaming Parquet writer to out (e.g. os.Stdout).
+// Parquet needs a seekable layout, so data is staged in a temporary file
+// and copied to out by Close. out is not closed.
+func NewParquetWriter(out io.Writer, opts ParquetWriteOptions) *ParquetWriter {
+	w := newParquetWriterFor(opts)
+	w.out = out
+	return w
+}
+
+func newParquetWriterFor(opts ParquetWriteOptions) *ParquetWriter {
+	sampleSize := opts.SampleSize
+	if sampleSize <= 0 {
+		sampleSize = schemaSampleSize
+	}
+	return &ParquetWriter{opts: opts, sampleSize: sampleSize}
+}
+
+// Write adds one record. After an error (e.g. a value that does not fit its
+// column) the writer is unusable and Close removes the partial output.
+func (w *ParquetWriter) Write(record map[string]any) error {
+	if w.closed {
+		return errors.New("parquet: write to closed writer")
+	}
+	if w.err != nil {
+		return w.err
+	}
+	if w.writer == nil {
+		w.pending = append(w.pending, record)
+		if len(w.pending) < w.sampleSize {
+			return nil
+		}
+		w.err = w.start()
+		return w.err
+	}
+	w.err = w.append(record)
+	return w.err
+}
+
+// WriteBatch adds records in order
+func (w *ParquetWriter) WriteBatch(records []map[string]any) error {
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes buffered rows as a row group; the schema is fixed from the records seen so far
+func (w *ParquetWriter) Flush() error {
+	if w.closed {
+		return errors.New("parquet: flush of closed writer")
+	}
+	if w.err != nil {
+		return w.err
+	}
+	if w.writer == nil {
+		if len(w.pending) == 0 {
+			return nil
+		}
+		if w.err = w.start(); w.err != nil {
+			return w.err
+		}
+	}
+	w.err = w.flush()
+	return w.err
+}
+
+// Close flushes all rows and finishes the file.
+// Closing a writer that received no records or failed earlier returns an error.
+func (w *ParquetWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.err != nil {
+		w.discard()
+		return w.err
+	}
+	if w.writer == nil {
+		if len(w.pending) == 0 {
+			w.closeDest()
+			return fmt.Errorf("no records to write")
+		}
+		if err := w.start(); err != nil {
+			w.abort()
+			return err
+		}
+	}
+	defer w.builder.Release()
+
+	if err := w.flush(); err != nil {
+		w.abort()
+		return err
+	}
+	// Closes the segment (or destination) file as well
+	if err := w.writer.Close(); err != nil {
+		w.abort()
+		return err
+	}
+	w.writer = nil
+
+	switch {
+	case w.segment == "":
+		// Single schema written straight to the destination
+		return nil
+	case len(w.segments) == 0 && w.out != nil:
+		err := copyFileTo(w.out, w.segment)
+		os.Remove(w.segment)
+		return err
+	default:
+		w.segments = append(w.segments, w.segment)
+		w.segment = ""
+		err := w.merge()
+		w.removeSegments()
+		return err
+	}
+}
+
+// start fixes the schema and writes the pending records
+func (w *ParquetWriter) start() error {
+	schema := w.opts.Schema
+	if schema == nil {
+		var err error
+		if schema, _, err = inferSchema(w.pending); err != nil {
+			return err
+		}
+	}
+	if err := w.open(schema); err != nil {
+		return err
+	}
+
+	pending := w.pending
+	w.pending = nil
+	for _, record := range pending {
+		if err := w.append(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// open starts a segment with schema: the destination file for the first
+// segment of CreateParquetWriter, a temporary file otherwise
+func (w *ParquetWriter) open(schema *arrow.Schema) error {
+	var sink io.WriteCloser
+	if w.dest != nil {
+		sink, w.dest = w.dest, nil
+	} else {
+		f, err := w.createSegment()
+		if err != nil {
+			return err
+		}
+		sink, w.segment = f, f.Name()
+	}
+
+	writer, err := newParquetWriter(schema, sink, w.opts, true)
+	if err != nil {
+		return err
+	}
+	if w.builder != nil {
+		w.builder.Release()
+	}
+	w.rows = 0
+	w.writer = writer
+	w.schema = schema
+	w.builder = array.NewRecordBuilder(memory.NewGoAllocator(), schema)
+	w.fieldIndex = make(map[string]int, schema.NumFields())
+	for i, field := range schema.Fields() {
+		w.fieldIndex[field.Name] = i
+	}
+	return nil
+}
+
+// createSegment creates a temporary segment file next to the destination
+func (w *ParquetWriter) createSegment() (*os.File, error) {
+	if w.filename == "" {
+		return os.CreateTemp("", "fileiterator-*.parquet")
+	}
+	return os.CreateTemp(filepath.Dir(w.filename), "."+filepath.Base(w.filename)+".*.tmp")
+}
+
+func (w *ParquetWriter) append(record map[string]any) error {
+	var added []string
+	for key := range record {
+		if _, ok := w.fieldIndex[key]; !ok {
+			added = append(added, key)
+		}
+	}
+	if len(added) > 0 {
+		if err := w.evolve(record, added); err != nil {
+			return err
+		}
+	}
+
+	for i, field := range w.schema.Fields() {
+		if err := appendValue(w.builder.Field(i), record[field.Name]); err != nil {
+			return fmt.Errorf("error appending field %s: %w", field.Name, err)
+		}
+	}
+	w.rows++
+	if w.rows >= w.opts.rowGroupSize() {
+		return w.flush()
+	}
+	return nil
+}
+
+// evolve finishes the current segment and starts a new one whose schema has
+// the new fields of record added as nullable columns
+func (w *ParquetWriter) evolve(record map[string]any, added []string) error {
+	if w.opts.Schema != nil {
+		return fmt.Errorf("field %q is not in Parquet schema", added[0])
+	}
+
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if w.segment == "" && w.atomic != nil {
+		w.atomic.keepTemp = true // the temporary file becomes the first segment
+	}
+	if err := w.writer.Close(); err != nil {
+		return err
+	}
+	w.writer = nil
+
+	if w.segment == "" {
+		// The destination holds the first segment - move it aside, it is rewritten by Close
+		first := w.filename
+		if w.atomic != nil {
+			first, w.atomic = w.atomic.TempName(), nil
+		}
+		f, err := w.createSegment()
+		if err != nil {
+			return err
+		}
+		f.Close()
+		if err := os.Rename(first, f.Name()); err != nil {
+			os.Remove(f.Name())
+			return err
+		}
+		w.segment = f.Name()
+	}
+	w.segments = append(w.segments, w.segment)
+	w.segment = ""
+
+	sort.Strings(added)
+	fields := w.schema.Fields()
+	for _, key := range added {
+		fields = append(fields, arrow.Field{Name: key, Type: resolveNullType(inferType(record[key])), Nullable: true})
+	}
+	return w.open(arrow.NewSchema(fields, nil))
+}
+
+func (w *ParquetWriter) flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+	rec := w.builder.NewRecord()
+	defer rec.Release()
+	w.rows = 0
+	return w.writer.Write(rec)
+}
+
+// merge rewrites all segments into the destination with the final schema;
+// columns a segment does not have are filled with NULLs
+func (w *ParquetWriter) merge() error {
+	var sink io.WriteCloser = nopWriteCloser{w.out}
+	if w.filename != "" {
+		var err error
+		if sink, err = createParquetFile(w.filename, &w.opts); err != nil {
+			return err
+		}
+	}
+	writer, err := newParquetWriter(w.schema, sink, w.opts, true)
+	if err != nil {
+		return err
+	}
+
+	for _, segment := range w.segments {
+		if err := w.copySegment(writer, segment); err != nil {
+			abortParquetWriter(writer, sink)
+			return fmt.Errorf("parquet: rewriting evolved schema: %w", err)
+		}
+	}
+	return writer.Close()
+}
+
+func (w *ParquetWriter) copySegment(writer *pqarrow.FileWriter, segment string) error {
+	pf, err := openParquetFile(segment)
+	if err != nil {
+		return err
+	}
+	defer pf.Close()
+
+	mem := memory.NewGoAllocator()
+	reader, err := pqarrow.NewFileReader(pf.Reader, pqarrow.ArrowReadProperties{BatchSize: int64(w.opts.rowGroupSize())}, mem)
+	if err != nil {
+		return err
+	}
+	rr, err := reader.GetRecordReader(context.Background(), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer rr.Release()
+
+	for rr.Next() {
+		rec := rr.Record()
+		cols := make([]arrow.Array, w.schema.NumFields())
+		for i, field := range w.schema.Fields() {
+			if idx := rec.Schema().FieldIndices(field.Name); len(idx) > 0 {
+				cols[i] = rec.Column(idx[0])
+				cols[i].Retain()
+			} else {
+				cols[i] = array.MakeArrayOfNull(mem, field.Type, int(rec.NumRows()))
+			}
+		}
+		out := array.NewRecord(w.schema, cols, rec.NumRows())
+		for _, col := range cols {
+			col.Release()
+		}
+		err := writer.Write(out)
+		out.Release()
+		if err != nil {
+			return err
+		}
+	}
+	if err := rr.Err(); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// Abort discards the output: buffered rows are dropped and the destination file is removed
+// (in atomic mode the destination is left untouched)
+func (w *ParquetWriter) Abort() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.discard()
+	return nil
+}
+
+// discard releases all files and removes the partial destination
+func (w *ParquetWriter) discard() {
+	w.abort()
+	if w.filename != "" && !w.atomicMode {
+		os.Remove(w.filename)
+	}
+}
+
+// abort releases files after a failed Close
+func (w *ParquetWriter) abort() {
+	if w.atomic != nil {
+		// Before the writer closes dest, which would rename it into place
+		w.atomic.Abort()
+		w.atomic = nil
+	}
+	if w.writer != nil {
+		w.writer.Close()
+		w.writer = nil
+	}
+	w.closeDest()
+	if w.segment != "" {
+		os.Remove(w.segment)
+	}
+	w.removeSegments()
+}
+
+func (w *ParquetWriter) closeDest() {
+	if w.dest != nil {
+		Abort(w.dest)
+		w.dest = nil
+	}
+}
+
+func (w *ParquetWriter) removeSegments() {
+	for _, segment := range w.segments {
+		os.Remove(segment)
+	}
+	w.segments = nil
+}
+
+// copyFileTo copies the file at path to out
+func copyFileTo(out io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(out, f)
+	return err
+}
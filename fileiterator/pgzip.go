@@ -0,0 +1,322 @@
+package fileiterator
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"sync"
+)
+
+// Parallel gzip works on multi-member files where every member header carries
+// its compressed size in a BGZF "BC" extra subfield (bgzip / htslib format,
+// written by Create with CreateOptions.Concurrency > 1).
+// Members can then be split without decoding and inflated concurrently.
+
+const (
+	bgzfHeaderLen    = 18     // gzip header with the 6-byte BC extra field
+	bgzfFooterLen    = 8      // CRC32 + ISIZE
+	bgzfMaxBlockData = 0xff00 // input bytes per member; keeps BSIZE within uint16
+)
+
+// bgzfEOF is the empty member bgzip appends as an end-of-file marker
+var bgzfEOF = []byte{
+	0x1f, 0x8b, 0x08, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0x06, 0x00, 0x42, 0x43, 0x02, 0x00,
+	0x1b, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+// bgzfMemberSize peeks at the next gzip member header and returns its total size.
+// ok is false if the member has no BC subfield; io.EOF at the end of the stream.
+func bgzfMemberSize(r *bufio.Reader) (size int, ok bool, err error) {
+	head, err := r.Peek(12)
+	if err != nil {
+		if err == io.EOF && len(head) == 0 {
+			return 0, false, io.EOF
+		}
+		return 0, false, nil // let the sequential reader report it
+	}
+	if head[0] != 0x1f || head[1] != 0x8b || head[3]&0x04 == 0 {
+		return 0, false, nil
+	}
+	xlen := int(binary.LittleEndian.Uint16(head[10:12]))
+	head, err = r.Peek(12 + xlen)
+	if err != nil {
+		return 0, false, nil
+	}
+	extra := head[12:]
+	for len(extra) >= 4 {
+		slen := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if len(extra) < 4+slen {
+			break
+		}
+		if extra[0] == 'B' && extra[1] == 'C' && slen == 2 {
+			return int(binary.LittleEndian.Uint16(extra[4:6])) + 1, true, nil
+		}
+		extra = extra[4+slen:]
+	}
+	return 0, false, nil
+}
+
+// memberResult is a decoded member, or the sequential reader for the rest of the stream
+type memberResult struct {
+	r   io.Reader
+	err error
+}
+
+// parallelGzipReader inflates BGZF members with up to `workers` goroutines,
+// returning data in order. Members without a size fall back to sequential decoding.
+type parallelGzipReader struct {
+	results chan chan memberResult
+	done    chan struct{}
+	cur     io.Reader
+	err     error // dispatcher error, valid once results is closed
+	once    sync.Once
+}
+
+// newGzipReader returns a parallel reader for BGZF input, a sequential gzip reader otherwise
+func newGzipReader(src io.Reader, workers int) (io.ReadCloser, error) {
+	br := bufio.NewReaderSize(src, 1<<17)
+	if workers > 1 {
+		if _, ok, _ := bgzfMemberSize(br); ok {
+			p := &parallelGzipReader{
+				results: make(chan chan memberResult, 2*workers),
+				done:    make(chan struct{}),
+			}
+			go p.dispatch(br, workers)
+			return p, nil
+		}
+	}
+	return gzip.NewReader(br)
+}
+
+func (p *parallelGzipReader) dispatch(src *bufio.Reader, workers int) {
+	defer close(p.results)
+	sem := make(chan struct{}, workers)
+	for {
+		size, ok, err := bgzfMemberSize(src)
+		if err == io.EOF {
+			return
+		}
+		fut := make(chan memberResult, 1)
+		if !ok {
+			// Not a BGZF member - decode the rest of the stream sequentially
+			zr, err := gzip.NewReader(src)
+			fut <- memberResult{r: zr, err: err}
+			p.send(fut)
+			return
+		}
+
+		member := make([]byte, size)
+		if _, err := io.ReadFull(src, member); err != nil {
+			fut <- memberResult{err: err}
+			p.send(fut)
+			return
+		}
+		if !p.send(fut) {
+			return
+		}
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem }()
+			fut <- inflateMember(member)
+		}()
+	}
+}
+
+// send queues a future; false if the reader was closed
+func (p *parallelGzipReader) send(fut chan memberResult) bool {
+	select {
+	case p.results <- fut:
+		return true
+	case <-p.done:
+		return false
+	}
+}
+
+func inflateMember(member []byte) memberResult {
+	zr, err := gzip.NewReader(bytes.NewReader(member))
+	if err != nil {
+		return memberResult{err: err}
+	}
+	zr.Multistream(false)
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return memberResult{err: err}
+	}
+	return memberResult{r: bytes.NewReader(data)}
+}
+
+func (p *parallelGzipReader) Read(b []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	for {
+		if p.cur != nil {
+			n, err := p.cur.Read(b)
+			if err != io.EOF {
+				if err != nil {
+					p.err = err
+				}
+				return n, err
+			}
+			p.cur = nil
+			if n > 0 {
+				return n, nil
+			}
+		}
+		fut, ok := <-p.results
+		if !ok {
+			p.err = io.EOF
+			return 0, io.EOF
+		}
+		res := <-fut
+		if res.err != nil {
+			p.err = res.err
+			return 0, res.err
+		}
+		p.cur = res.r
+	}
+}
+
+func (p *parallelGzipReader) Close() error {
+	p.once.Do(func() { close(p.done) })
+	return nil
+}
+
+// parallelGzipWriter compresses input in bgzfMaxBlockData chunks with up to `workers`
+// goroutines and writes them in order as BGZF members
+type parallelGzipWriter struct {
+	dst     io.WriteCloser
+	level   int
+	buf     []byte
+	sem     chan struct{}
+	queue   chan chan []byte
+	written chan struct{}
+	mu      sync.Mutex
+	err     error
+	closed  bool
+}
+
+func newParallelGzipWriter(dst io.WriteCloser, workers, level int) *parallelGzipWriter {
+	w := &parallelGzipWriter{
+		dst:     dst,
+		level:   level,
+		buf:     make([]byte, 0, bgzfMaxBlockData),
+		sem:     make(chan struct{}, workers),
+		queue:   make(chan chan []byte, 2*workers),
+		written: make(chan struct{}),
+	}
+	go w.drain()
+	return w
+}
+
+// drain writes compressed members in submission order
+func (w *parallelGzipWriter) drain() {
+	defer close(w.written)
+	for fut := range w.queue {
+		member := <-fut
+		if w.failed() == nil {
+			if _, err := w.dst.Write(member); err != nil {
+				w.fail(err)
+			}
+		}
+	}
+}
+
+func (w *parallelGzipWriter) fail(err error) {
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.mu.Unlock()
+}
+
+func (w *parallelGzipWriter) failed() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *parallelGzipWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed gzip writer")
+	}
+	if err := w.failed(); err != nil {
+		return 0, err
+	}
+	written := len(p)
+	for len(p) > 0 {
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		if len(w.buf) == cap(w.buf) {
+			w.submit()
+		}
+	}
+	return written, nil
+}
+
+func (w *parallelGzipWriter) submit() {
+	block := w.buf
+	w.buf = make([]byte, 0, bgzfMaxBlockData)
+	fut := make(chan []byte, 1)
+	w.queue <- fut
+	w.sem <- struct{}{}
+	go func() {
+		defer func() { <-w.sem }()
+		member, err := deflateMember(block, w.level)
+		if err != nil {
+			w.fail(err)
+		}
+		fut <- member
+	}()
+}
+
+func (w *parallelGzipWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if len(w.buf) > 0 {
+		w.submit()
+	}
+	close(w.queue)
+	<-w.written
+	err := w.failed()
+	if err == nil {
+		_, err = w.dst.Write(bgzfEOF)
+	}
+	if cerr := w.dst.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// deflateMember compresses block into a single BGZF gzip member
+func deflateMember(block []byte, level int) ([]byte, error) {
+	var out bytes.Buffer
+	out.Write([]byte{0x1f, 0x8b, 0x08, 0x04, 0, 0, 0, 0, 0, 0xff, 6, 0, 'B', 'C', 2, 0, 0, 0})
+	fw, err := flate.NewWriter(&out, level)
+	if err != nil {
+		return nil, err
+	}
+	fw.Write(block)
+	if err := fw.Close(); err != nil {
+		return nil, err
+	}
+	var footer [bgzfFooterLen]byte
+	binary.LittleEndian.PutUint32(footer[0:4], crc32.ChecksumIEEE(block))
+	binary.LittleEndian.PutUint32(footer[4:8], uint32(len(block)))
+	out.Write(footer[:])
+
+	member := out.Bytes()
+	if len(member) > 1<<16 {
+		return nil, errors.New("bgzf member exceeds 64KB")
+	}
+	binary.LittleEndian.PutUint16(member[bgzfHeaderLen-2:bgzfHeaderLen], uint16(len(member)-1))
+	return member, nil
+}
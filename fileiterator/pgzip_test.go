@@ -0,0 +1,165 @@
+package fileiterator_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parf/homebase-go-lib/fileiterator"
+)
+
+func writeParallel(t *testing.T, path string, data []byte) {
+	t.Helper()
+	w, err := fileiterator.Create(path, fileiterator.CreateOptions{Concurrency: 4})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	// Uneven writes to cross block boundaries
+	for len(data) > 0 {
+		n := min(len(data), 10007)
+		w.Write(data[:n])
+		data = data[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func readAllWith(t *testing.T, path string, opts fileiterator.OpenOptions) []byte {
+	t.Helper()
+	r, err := fileiterator.OpenWithOptions(context.Background(), path, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	return data
+}
+
+func TestParallelCodecsRoundTrip(t *testing.T) {
+	payload := testPayload(3 << 20)
+	tmpDir := t.TempDir()
+
+	for _, name := range []string{"data.txt.gz", "data.txt.zst", "data.txt.lz4"} {
+		t.Run(name, func(t *testing.T) {
+			testFile := filepath.Join(tmpDir, name)
+			writeParallel(t, testFile, payload)
+
+			for _, workers := range []int{1, 4, -1} {
+				data := readAllWith(t, testFile, fileiterator.OpenOptions{Concurrency: workers})
+				if !bytes.Equal(data, payload) {
+					t.Errorf("Concurrency %d: got %d bytes, want %d", workers, len(data), len(payload))
+				}
+			}
+		})
+	}
+}
+
+func TestParallelGzipIsStandardGzip(t *testing.T) {
+	payload := testPayload(500000)
+	testFile := filepath.Join(t.TempDir(), "data.gz")
+	writeParallel(t, testFile, payload)
+
+	raw, _ := os.ReadFile(testFile)
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("gzip.NewReader failed: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil || !bytes.Equal(data, payload) {
+		t.Errorf("Standard gzip reader failed: %v, %d bytes", err, len(data))
+	}
+}
+
+func TestParallelGzipWriteAfterClose(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "data.gz")
+	w, err := fileiterator.Create(testFile, fileiterator.CreateOptions{Concurrency: 4})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	w.Write([]byte("hello\n"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := w.Write([]byte("late\n")); err == nil {
+		t.Error("Expected error writing to closed writer")
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Second Close failed: %v", err)
+	}
+	if data := readAllWith(t, testFile, fileiterator.OpenOptions{}); string(data) != "hello\n" {
+		t.Errorf("Unexpected content %q", data)
+	}
+}
+
+func TestParallelGzipFallsBackOnPlainMembers(t *testing.T) {
+	part1 := testPayload(200000)
+	part2 := []byte("plain gzip member\n")
+	testFile := filepath.Join(t.TempDir(), "mixed.gz")
+	writeParallel(t, testFile, part1)
+
+	// Append a regular gzip member without BGZF size
+	f, _ := os.OpenFile(testFile, os.O_APPEND|os.O_WRONLY, 0644)
+	gz := gzip.NewWriter(f)
+	gz.Write(part2)
+	gz.Close()
+	f.Close()
+
+	data := readAllWith(t, testFile, fileiterator.OpenOptions{Concurrency: 4})
+	if !bytes.Equal(data, append(part1, part2...)) {
+		t.Errorf("Got %d bytes, want %d", len(data), len(part1)+len(part2))
+	}
+}
+
+func TestParallelGzipCorruptMember(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "data.gz")
+	writeParallel(t, testFile, testPayload(300000))
+
+	raw, _ := os.ReadFile(testFile)
+	raw[len(raw)/2] ^= 0xff
+	os.WriteFile(testFile, raw, 0644)
+
+	r, err := fileiterator.OpenWithOptions(context.Background(), testFile, fileiterator.OpenOptions{Concurrency: 4})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+	_, err = io.ReadAll(r)
+	var corrupt *fileiterator.ErrCorruptStream
+	if !errors.As(err, &corrupt) {
+		t.Errorf("Expected ErrCorruptStream, got %v", err)
+	}
+}
+
+func TestSetDecodeConcurrencyIterateLines(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "lines.jsonl.gz")
+	var buf bytes.Buffer
+	for i := 0; i < 50000; i++ {
+		fmt.Fprintf(&buf, "{\"id\": %d}\n", i)
+	}
+	writeParallel(t, testFile, buf.Bytes())
+
+	fileiterator.SetDecodeConcurrency(4)
+	defer fileiterator.SetDecodeConcurrency(0)
+
+	sum := 0
+	err := fileiterator.IterateJSONL(testFile, func(obj map[string]any) error {
+		sum += int(obj["id"].(float64))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateJSONL failed: %v", err)
+	}
+	if sum != 50000*49999/2 {
+		t.Errorf("Expected sum %d, got %d", 50000*49999/2, sum)
+	}
+}
@@ -0,0 +1,566 @@
+package fileiterator
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/apache/arrow/go/v14/parquet/pqarrow"
+)
+
+// Record formats understood by OpenRecordReader and CreateRecordWriter
+const (
+	FormatJSONL   = "jsonl"
+	FormatCSV     = "csv"
+	FormatMsgPack = "msgpack"
+	FormatParquet = "parquet"
+)
+
+// schemaSampleSize is the number of leading records buffered by the CSV and
+// Parquet writers to determine the column set (and Parquet types)
+const schemaSampleSize = 10000
+
+// parquetBatchSize is the number of rows per Arrow record batch used when
+// streaming Parquet files
+const parquetBatchSize = 64 * 1024
+
+// RecordReader streams generic records one at a time.
+// Read returns io.EOF after the last record.
+type RecordReader interface {
+	Read() (map[string]any, error)
+	Close() error
+}
+
+// RecordWriter streams generic records one at a time.
+// Close flushes buffered data and must always be called.
+type RecordWriter interface {
+	Write(record map[string]any) error
+	Close() error
+}
+
+// DetectFormat returns the record format of filename based on its extension.
+// Compression extensions (.gz, .zst, .lz4, .br, .xz) are skipped.
+func DetectFormat(filename string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	// Remove compression extension if present
+	if ext == ".gz" || ext == ".zst" || ext == ".lz4" || ext == ".br" || ext == ".xz" {
+		base := strings.TrimSuffix(filename, filepath.Ext(filename))
+		ext = strings.ToLower(filepath.Ext(base))
+	}
+
+	switch ext {
+	case ".jsonl", ".ndjson":
+		return FormatJSONL, nil
+	case ".msgpack", ".mp":
+		return FormatMsgPack, nil
+	case ".csv":
+		return FormatCSV, nil
+	case ".parquet", ".pk":
+		return FormatParquet, nil
+	default:
+		return "", fmt.Errorf("unsupported format: %s (supported: .jsonl, .csv, .msgpack, .parquet/.pk)", ext)
+	}
+}
+
+// OpenRecordReader opens any supported format for streaming reads.
+// Compression is auto-detected via Open.
+// A glob pattern ("events-*.jsonl.zst") or a directory reads all matching files
+// in sorted order as one stream (see GlobFiles); directories skip files of unknown format.
+//
+// Example:
+//
+//	r, err := fileiterator.OpenRecordReader("events.jsonl.zst")
+//	if err != nil {
+//	    return err
+//	}
+//	defer r.Close()
+//	for {
+//	    record, err := r.Read()
+//	    if err == io.EOF {
+//	        break
+//	    }
+//	    if err != nil {
+//	        return err
+//	    }
+//	    ...
+//	}
+func OpenRecordReader(filename string) (RecordReader, error) {
+	if IsGlobInput(filename) {
+		files, err := recordFiles(filename)
+		if err != nil {
+			return nil, err
+		}
+		return &multiRecordReader{files: files}, nil
+	}
+	return openRecordFile(filename)
+}
+
+// openRecordFile opens a single file for streaming reads
+func openRecordFile(filename string) (RecordReader, error) {
+	format, err := DetectFormat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatParquet {
+		return newParquetRecordReader(filename)
+	}
+
+	rc, err := Open(context.Background(), filename)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatJSONL:
+		return newJSONLRecordReader(rc), nil
+	case FormatMsgPack:
+		return newMsgPackRecordReader(rc), nil
+	default:
+		return newCSVRecordReader(rc)
+	}
+}
+
+// RecordWriterOptions configures CreateRecordWriterWithOptions, NewRecordWriterWithOptions
+// and WriteOutputWithOptions
+type RecordWriterOptions struct {
+	// Atomic writes a temporary file that is renamed into place on success (see CreateOptions.Atomic)
+	Atomic bool
+
+	// SampleSize is the number of leading records buffered to build the CSV header and the
+	// Parquet schema (0 - 10000). A CSV record with a field none of them had is an error.
+	SampleSize int
+}
+
+// CreateRecordWriter creates filename for streaming writes.
+// Compression is auto-detected via FUCreate.
+func CreateRecordWriter(filename string) (RecordWriter, error) {
+	return CreateRecordWriterWithOptions(filename, RecordWriterOptions{})
+}
+
+// CreateRecordWriterWithOptions is CreateRecordWriter with atomic writes and
+// a schema sample size (see RecordWriterOptions)
+func CreateRecordWriterWithOptions(filename string, opts RecordWriterOptions) (RecordWriter, error) {
+	sampleSize := opts.SampleSize
+	if sampleSize <= 0 {
+		sampleSize = schemaSampleSize
+	}
+	format, err := DetectFormat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatParquet {
+		// data.parquet.zst - zstd pages instead of a compressed file
+		popts := DefaultParquetWriteOptions()
+		popts.SampleSize = sampleSize
+		popts.Atomic = opts.Atomic
+		return CreateParquetWriter(filename, popts)
+	}
+	out, err := Create(filename, CreateOptions{Atomic: opts.Atomic})
+	if err != nil {
+		return nil, err
+	}
+	return &fileRecordWriter{RecordWriter: newRecordWriter(out, format, sampleSize), out: out}, nil
+}
+
+// fileRecordWriter is a RecordWriter over a file created by CreateRecordWriterWithOptions
+type fileRecordWriter struct {
+	RecordWriter
+	out io.WriteCloser
+}
+
+// Abort discards the output; in atomic mode (see CreateOptions.Atomic) the destination is left untouched
+func (w *fileRecordWriter) Abort() error {
+	return Abort(w.out)
+}
+
+// NewRecordWriter streams records in the given format (FormatJSONL, FormatCSV,
+// FormatMsgPack, FormatParquet) to w. Close flushes the output but does not close w,
+// which makes it suitable for os.Stdout.
+func NewRecordWriter(w io.Writer, format string) (RecordWriter, error) {
+	return NewRecordWriterWithOptions(w, format, RecordWriterOptions{})
+}
+
+// NewRecordWriterWithOptions is NewRecordWriter with a schema sample size
+// (see RecordWriterOptions; Atomic does not apply to a stream)
+func NewRecordWriterWithOptions(w io.Writer, format string, opts RecordWriterOptions) (RecordWriter, error) {
+	sampleSize := opts.SampleSize
+	if sampleSize <= 0 {
+		sampleSize = schemaSampleSize
+	}
+	switch format {
+	case FormatJSONL, FormatCSV, FormatMsgPack, FormatParquet:
+		return newRecordWriter(nopWriteCloser{w}, format, sampleSize), nil
+	default:
+		return nil, fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+func newRecordWriter(w io.WriteCloser, format string, sampleSize int) RecordWriter {
+	switch format {
+	case FormatJSONL:
+		return newJSONLRecordWriter(w)
+	case FormatMsgPack:
+		return newMsgPackRecordWriter(w)
+	case FormatCSV:
+		return newCSVRecordWriter(w, sampleSize)
+	default:
+		opts := DefaultParquetWriteOptions()
+		opts.SampleSize = sampleSize
+		return NewParquetWriter(w, opts)
+	}
+}
+
+// CopyRecords copies all records from r to w and returns the number of records copied.
+// Neither r nor w is closed.
+func CopyRecords(w RecordWriter, r RecordReader) (int64, error) {
+	var n int64
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if err := w.Write(record); err != nil {
+			return n, fmt.Errorf("record %d: %w", n+1, err)
+		}
+		n++
+	}
+}
+
+// nopWriteCloser hides the Close method of the wrapped writer
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// ---- JSONL ----
+
+type jsonlRecordReader struct {
+	rc      io.ReadCloser
+	reader  *bufio.Reader
+	lineNum int
+}
+
+func newJSONLRecordReader(rc io.ReadCloser) *jsonlRecordReader {
+	return &jsonlRecordReader{rc: rc, reader: bufio.NewReaderSize(rc, bufferSize)}
+}
+
+func (r *jsonlRecordReader) Read() (map[string]any, error) {
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("line %d: read error: %w", r.lineNum+1, err)
+		}
+		r.lineNum++
+
+		line = trimEOL(line)
+		// Skip empty lines
+		if len(line) == 0 {
+			continue
+		}
+
+		var obj map[string]any
+		if err := json.Unmarshal(line, &obj); err != nil {
+			return nil, fmt.Errorf("line %d: JSON parse error: %w", r.lineNum, err)
+		}
+		return obj, nil
+	}
+}
+
+func (r *jsonlRecordReader) Close() error {
+	return r.rc.Close()
+}
+
+// trimEOL strips a trailing "\n" or "\r\n"
+func trimEOL(line []byte) []byte {
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+		if n := len(line); n > 0 && line[n-1] == '\r' {
+			line = line[:n-1]
+		}
+	}
+	return line
+}
+
+type jsonlRecordWriter struct {
+	wc      io.WriteCloser
+	buf     *bufio.Writer
+	encoder *json.Encoder
+}
+
+func newJSONLRecordWriter(wc io.WriteCloser) *jsonlRecordWriter {
+	buf := bufio.NewWriterSize(wc, bufferSize)
+	return &jsonlRecordWriter{wc: wc, buf: buf, encoder: json.NewEncoder(buf)}
+}
+
+func (w *jsonlRecordWriter) Write(record map[string]any) error {
+	return w.encoder.Encode(record)
+}
+
+func (w *jsonlRecordWriter) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		Abort(w.wc)
+		return err
+	}
+	return w.wc.Close()
+}
+
+// ---- MsgPack ----
+
+type msgpackRecordReader struct {
+	rc     io.ReadCloser
+	values *msgpackValues
+	count  int
+}
+
+func newMsgPackRecordReader(rc io.ReadCloser) *msgpackRecordReader {
+	return &msgpackRecordReader{rc: rc, values: newMsgPackValues(bufio.NewReaderSize(rc, bufferSize), true)}
+}
+
+func (r *msgpackRecordReader) Read() (map[string]any, error) {
+	for {
+		var record any
+		if err := r.values.next(&record); err != nil {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("record %d: decode error: %w", r.count+1, err)
+		}
+		r.count++
+		// Non-map values have no field names - skip them
+		if m, ok := record.(map[string]any); ok {
+			return m, nil
+		}
+	}
+}
+
+func (r *msgpackRecordReader) Close() error {
+	return r.rc.Close()
+}
+
+// msgpackRecordWriter adapts MsgPackWriter to RecordWriter
+type msgpackRecordWriter struct {
+	*MsgPackWriter
+}
+
+func newMsgPackRecordWriter(wc io.WriteCloser) msgpackRecordWriter {
+	w := NewMsgPackWriter(wc)
+	w.out = wc
+	return msgpackRecordWriter{w}
+}
+
+func (w msgpackRecordWriter) Write(record map[string]any) error {
+	return w.MsgPackWriter.Write(record)
+}
+
+// ---- CSV ----
+
+type csvRecordReader struct {
+	rc     io.ReadCloser
+	reader *csv.Reader
+	header []string
+	rowNum int
+}
+
+func newCSVRecordReader(rc io.ReadCloser) (*csvRecordReader, error) {
+	reader := csv.NewReader(bufio.NewReaderSize(rc, bufferSize))
+	reader.ReuseRecord = true
+
+	headerRow, err := reader.Read()
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	// Copy header to avoid issues with ReuseRecord
+	header := make([]string, len(headerRow))
+	copy(header, headerRow)
+
+	return &csvRecordReader{rc: rc, reader: reader, header: header, rowNum: 1}, nil
+}
+
+func (r *csvRecordReader) Read() (map[string]any, error) {
+	row, err := r.reader.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("row %d: failed to read CSV row: %w", r.rowNum+1, err)
+	}
+	r.rowNum++
+
+	record := make(map[string]any, len(r.header))
+	for i, value := range row {
+		if i >= len(r.header) {
+			continue
+		}
+		record[r.header[i]] = inferValue(value)
+	}
+	return record, nil
+}
+
+func (r *csvRecordReader) Close() error {
+	return r.rc.Close()
+}
+
+// csvRecordWriter buffers the first sampleSize records to build the header
+// from the union of their fields (sorted). Later records may not introduce new fields.
+type csvRecordWriter struct {
+	wc         io.WriteCloser
+	writer     *csv.Writer
+	sampleSize int
+	pending    []map[string]any
+	header     []string
+	columns    map[string]bool
+	row        []string
+}
+
+func newCSVRecordWriter(wc io.WriteCloser, sampleSize int) *csvRecordWriter {
+	return &csvRecordWriter{wc: wc, writer: csv.NewWriter(bufio.NewWriterSize(wc, bufferSize)), sampleSize: sampleSize}
+}
+
+func (w *csvRecordWriter) Write(record map[string]any) error {
+	if w.header == nil {
+		w.pending = append(w.pending, record)
+		if len(w.pending) < w.sampleSize {
+			return nil
+		}
+		return w.writeHeader()
+	}
+	return w.writeRow(record)
+}
+
+func (w *csvRecordWriter) writeHeader() error {
+	w.columns = make(map[string]bool)
+	for _, record := range w.pending {
+		for key := range record {
+			w.columns[key] = true
+		}
+	}
+	w.header = make([]string, 0, len(w.columns))
+	for col := range w.columns {
+		w.header = append(w.header, col)
+	}
+	sort.Strings(w.header)
+	w.row = make([]string, len(w.header))
+
+	if err := w.writer.Write(w.header); err != nil {
+		return err
+	}
+
+	pending := w.pending
+	w.pending = nil
+	for _, record := range pending {
+		if err := w.writeRow(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *csvRecordWriter) writeRow(record map[string]any) error {
+	for key := range record {
+		if !w.columns[key] {
+			return fmt.Errorf("field %q is not in CSV header (header is built from the first %d records, see RecordWriterOptions.SampleSize)", key, w.sampleSize)
+		}
+	}
+	for i, col := range w.header {
+		w.row[i] = ""
+		if val, ok := record[col]; ok && val != nil {
+			w.row[i] = fmt.Sprintf("%v", val)
+		}
+	}
+	return w.writer.Write(w.row)
+}
+
+func (w *csvRecordWriter) Close() error {
+	var err error
+	if w.header == nil && len(w.pending) > 0 {
+		err = w.writeHeader()
+	}
+	if err == nil {
+		w.writer.Flush()
+		err = w.writer.Error()
+	}
+	if err != nil {
+		Abort(w.wc)
+		return err
+	}
+	return w.wc.Close()
+}
+
+// ---- Parquet ----
+
+type parquetRecordReader struct {
+	pf     *parquetFile
+	rr     pqarrow.RecordReader
+	schema *arrow.Schema
+	rec    arrow.Record
+	row    int
+}
+
+func newParquetRecordReader(filename string) (*parquetRecordReader, error) {
+	pf, err := openParquetFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := pqarrow.NewFileReader(pf.Reader, pqarrow.ArrowReadProperties{BatchSize: parquetBatchSize}, memory.NewGoAllocator())
+	if err != nil {
+		pf.Close()
+		return nil, err
+	}
+
+	rr, err := reader.GetRecordReader(context.Background(), nil, nil)
+	if err != nil {
+		pf.Close()
+		return nil, err
+	}
+
+	return &parquetRecordReader{pf: pf, rr: rr, schema: rr.Schema()}, nil
+}
+
+func (r *parquetRecordReader) Read() (map[string]any, error) {
+	for r.rec == nil || r.row >= int(r.rec.NumRows()) {
+		if !r.rr.Next() {
+			if err := r.rr.Err(); err != nil && !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+			r.rec = nil
+			return nil, io.EOF
+		}
+		r.rec = r.rr.Record()
+		r.row = 0
+	}
+
+	record := make(map[string]any, r.rec.NumCols())
+	for colIdx := 0; colIdx < int(r.rec.NumCols()); colIdx++ {
+		fieldName := r.schema.Field(colIdx).Name
+		value, err := getValueFromColumn(r.rec.Column(colIdx), r.row)
+		if err != nil {
+			return nil, fmt.Errorf("error reading column %s: %w", fieldName, err)
+		}
+		record[fieldName] = value
+	}
+	r.row++
+	return record, nil
+}
+
+func (r *parquetRecordReader) Close() error {
+	r.rr.Release()
+	return r.pf.Close()
+}
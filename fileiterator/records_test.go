@@ -0,0 +1,196 @@
+package fileiterator_test
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/parf/homebase-go-lib/fileiterator"
+)
+
+func testRecords(n int) []map[string]any {
+	records := make([]map[string]any, n)
+	for i := range records {
+		records[i] = map[string]any{
+			"id":     int64(i),
+			"name":   "user" + strings.Repeat("x", i%3),
+			"score":  float64(i) * 1.5,
+			"active": i%2 == 0,
+		}
+	}
+	return records
+}
+
+func TestRecordReaderWriterRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	records := testRecords(100)
+
+	for _, name := range []string{"data.jsonl", "data.jsonl.zst", "data.csv.gz", "data.msgpack", "data.parquet"} {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(tmpDir, name)
+
+			w, err := fileiterator.CreateRecordWriter(filename)
+			if err != nil {
+				t.Fatalf("CreateRecordWriter failed: %v", err)
+			}
+			for _, record := range records {
+				if err := w.Write(record); err != nil {
+					t.Fatalf("Write failed: %v", err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			r, err := fileiterator.OpenRecordReader(filename)
+			if err != nil {
+				t.Fatalf("OpenRecordReader failed: %v", err)
+			}
+			defer r.Close()
+
+			count := 0
+			for {
+				record, err := r.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Read failed: %v", err)
+				}
+				if record["name"] != records[count]["name"] {
+					t.Errorf("record %d: name = %v, want %v", count, record["name"], records[count]["name"])
+				}
+				count++
+			}
+			if count != len(records) {
+				t.Errorf("Expected %d records, got %d", len(records), count)
+			}
+		})
+	}
+}
+
+func TestReadInputWriteOutput(t *testing.T) {
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "data.parquet")
+
+	if err := fileiterator.WriteOutput(filename, testRecords(10)); err != nil {
+		t.Fatalf("WriteOutput failed: %v", err)
+	}
+
+	records, err := fileiterator.ReadInput(filename)
+	if err != nil {
+		t.Fatalf("ReadInput failed: %v", err)
+	}
+	if len(records) != 10 {
+		t.Fatalf("Expected 10 records, got %d", len(records))
+	}
+	if records[9]["id"] != int64(9) {
+		t.Errorf("Expected id 9, got %v", records[9]["id"])
+	}
+}
+
+func TestNewRecordWriterCSVHeader(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := fileiterator.NewRecordWriter(&buf, fileiterator.FormatCSV)
+	if err != nil {
+		t.Fatalf("NewRecordWriter failed: %v", err)
+	}
+	w.Write(map[string]any{"b": 1, "a": "x"})
+	w.Write(map[string]any{"a": "y", "c": nil})
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	expected := "a,b,c\nx,1,\ny,,\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestRecordWriterCSVSampleSize(t *testing.T) {
+	records := []map[string]any{{"a": 1}, {"a": 2}, {"a": 3, "late": "x"}}
+
+	var buf bytes.Buffer
+	w, _ := fileiterator.NewRecordWriterWithOptions(&buf, fileiterator.FormatCSV, fileiterator.RecordWriterOptions{SampleSize: 2})
+	w.Write(records[0])
+	w.Write(records[1])
+	if err := w.Write(records[2]); err == nil || !strings.Contains(err.Error(), "first 2 records") {
+		t.Errorf("Expected error for a field after the sample, got %v", err)
+	}
+
+	buf.Reset()
+	w, _ = fileiterator.NewRecordWriterWithOptions(&buf, fileiterator.FormatCSV, fileiterator.RecordWriterOptions{SampleSize: 3})
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if expected := "a,late\n1,\n2,\n3,x\n"; buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+
+	file := filepath.Join(t.TempDir(), "out.csv")
+	fw, err := fileiterator.CreateRecordWriterWithOptions(file, fileiterator.RecordWriterOptions{SampleSize: 1})
+	if err != nil {
+		t.Fatalf("CreateRecordWriterWithOptions failed: %v", err)
+	}
+	fw.Write(records[0])
+	if err := fw.Write(records[2]); err == nil {
+		t.Error("Expected error for a field after a sample of 1")
+	}
+	fw.Close()
+}
+
+func TestCopyRecords(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "src.jsonl")
+	if err := fileiterator.WriteOutput(src, testRecords(5)); err != nil {
+		t.Fatalf("WriteOutput failed: %v", err)
+	}
+
+	r, err := fileiterator.OpenRecordReader(src)
+	if err != nil {
+		t.Fatalf("OpenRecordReader failed: %v", err)
+	}
+	defer r.Close()
+
+	var buf bytes.Buffer
+	w, _ := fileiterator.NewRecordWriter(&buf, fileiterator.FormatJSONL)
+	n, err := fileiterator.CopyRecords(w, r)
+	if err != nil {
+		t.Fatalf("CopyRecords failed: %v", err)
+	}
+	w.Close()
+
+	if n != 5 {
+		t.Errorf("Expected 5 records copied, got %d", n)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 5 {
+		t.Errorf("Expected 5 lines, got %d", lines)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := map[string]string{
+		"a.jsonl":      fileiterator.FormatJSONL,
+		"a.ndjson.gz":  fileiterator.FormatJSONL,
+		"a.CSV.zst":    fileiterator.FormatCSV,
+		"a.mp":         fileiterator.FormatMsgPack,
+		"a.pk":         fileiterator.FormatParquet,
+		"a.parquet.xz": fileiterator.FormatParquet,
+	}
+	for name, want := range tests {
+		got, err := fileiterator.DetectFormat(name)
+		if err != nil || got != want {
+			t.Errorf("DetectFormat(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := fileiterator.DetectFormat("a.txt"); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
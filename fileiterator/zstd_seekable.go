@@ -0,0 +1,250 @@
+package fileiterator
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Seekable zstd format (zstd contrib/seekable_format): independent zstd frames
+// followed by a skippable frame with a seek table. Regular zstd decoders skip
+// the seek table, so the file stays a valid .zst file.
+const (
+	seekTableMagic    = 0x184D2A5E // skippable frame magic of the seek table
+	seekableMagic     = 0x8F92EAB1 // last 4 bytes of a seekable file
+	seekTableEntry    = 8          // compressed size, decompressed size (no checksums)
+	seekTableFooter   = 9          // number of frames, descriptor, seekable magic
+	seekableFrameSize = 1 << 20    // uncompressed bytes per frame
+)
+
+// seekableZstdWriter compresses data in independent frames of seekableFrameSize
+// bytes and writes the seek table on Close. Closing it closes the file.
+type seekableZstdWriter struct {
+	file    io.WriteCloser
+	enc     *zstd.Encoder
+	buf     []byte
+	frames  [][2]uint32 // compressed, decompressed size
+	scratch []byte
+}
+
+func newSeekableZstdWriter(file io.WriteCloser, level zstd.EncoderLevel) (*seekableZstdWriter, error) {
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &seekableZstdWriter{file: file, enc: enc, buf: make([]byte, 0, seekableFrameSize)}, nil
+}
+
+func (w *seekableZstdWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), seekableFrameSize-len(w.buf))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(w.buf) == seekableFrameSize {
+			if err := w.flushFrame(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (w *seekableZstdWriter) flushFrame() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	w.scratch = w.enc.EncodeAll(w.buf, w.scratch[:0])
+	if _, err := w.file.Write(w.scratch); err != nil {
+		return err
+	}
+	w.frames = append(w.frames, [2]uint32{uint32(len(w.scratch)), uint32(len(w.buf))})
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Close writes the last frame and the seek table, then closes the file
+func (w *seekableZstdWriter) Close() error {
+	err := w.flushFrame()
+	w.enc.Close()
+	if err == nil {
+		table := make([]byte, 8, 8+len(w.frames)*seekTableEntry+seekTableFooter)
+		binary.LittleEndian.PutUint32(table, seekTableMagic)
+		binary.LittleEndian.PutUint32(table[4:], uint32(cap(table)-8))
+		for _, f := range w.frames {
+			table = binary.LittleEndian.AppendUint32(table, f[0])
+			table = binary.LittleEndian.AppendUint32(table, f[1])
+		}
+		table = binary.LittleEndian.AppendUint32(table, uint32(len(w.frames)))
+		table = append(table, 0) // descriptor: no checksums
+		table = binary.LittleEndian.AppendUint32(table, seekableMagic)
+		_, err = w.file.Write(table)
+	}
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// seekableFrame locates one frame in the compressed and decompressed data
+type seekableFrame struct {
+	off, size       int64 // compressed
+	dataOff, dataSz int64 // decompressed
+}
+
+// seekableZstdReader gives random access to a seekable zstd file.
+// The last decoded frame is cached.
+type seekableZstdReader struct {
+	file   *os.File
+	dec    *zstd.Decoder
+	frames []seekableFrame
+	total  int64 // decompressed size
+
+	cached int // frame in data, -1 if none
+	data   []byte
+	comp   []byte
+}
+
+// isSeekableZstd reports whether f ends with the seekable zstd magic
+func isSeekableZstd(f *os.File, size int64) bool {
+	if size < seekTableFooter {
+		return false
+	}
+	var magic [4]byte
+	if _, err := f.ReadAt(magic[:], size-4); err != nil {
+		return false
+	}
+	return binary.LittleEndian.Uint32(magic[:]) == seekableMagic
+}
+
+func openSeekableZstd(f *os.File, size int64) (*seekableZstdReader, error) {
+	frames, total, err := readSeekTable(f, size)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &seekableZstdReader{file: f, dec: dec, frames: frames, total: total, cached: -1}, nil
+}
+
+// readSeekTable reads the frame layout of a seekable zstd file and its decompressed size
+func readSeekTable(f *os.File, size int64) ([]seekableFrame, int64, error) {
+	var footer [seekTableFooter]byte
+	if _, err := f.ReadAt(footer[:], size-seekTableFooter); err != nil {
+		return nil, 0, err
+	}
+	if binary.LittleEndian.Uint32(footer[5:]) != seekableMagic {
+		return nil, 0, errors.New("zstd: no seek table")
+	}
+	n := int64(binary.LittleEndian.Uint32(footer[:]))
+	entry := int64(seekTableEntry)
+	if footer[4]&0x80 != 0 {
+		entry += 4 // checksums
+	}
+	tableSize := 8 + n*entry + seekTableFooter
+	if tableSize > size {
+		return nil, 0, errors.New("zstd: corrupt seek table")
+	}
+	table := make([]byte, tableSize)
+	if _, err := f.ReadAt(table, size-tableSize); err != nil {
+		return nil, 0, err
+	}
+	if binary.LittleEndian.Uint32(table) != seekTableMagic {
+		return nil, 0, errors.New("zstd: corrupt seek table")
+	}
+
+	frames := make([]seekableFrame, n)
+	var off, total int64
+	for i := range frames {
+		e := table[8+int64(i)*entry:]
+		fr := seekableFrame{
+			off:     off,
+			size:    int64(binary.LittleEndian.Uint32(e)),
+			dataOff: total,
+			dataSz:  int64(binary.LittleEndian.Uint32(e[4:])),
+		}
+		frames[i] = fr
+		off += fr.size
+		total += fr.dataSz
+	}
+	if off > size-tableSize {
+		return nil, 0, errors.New("zstd: corrupt seek table")
+	}
+	return frames, total, nil
+}
+
+// findFrame returns the index of the frame holding decompressed offset off,
+// len(frames) if off is at or past the end
+func findFrame(frames []seekableFrame, off int64) int {
+	return sort.Search(len(frames), func(i int) bool { return frames[i].dataOff+frames[i].dataSz > off })
+}
+
+// size returns the decompressed size
+func (r *seekableZstdReader) size() int64 {
+	return r.total
+}
+
+// readAt returns n decompressed bytes at off. The slice is valid until the next call.
+func (r *seekableZstdReader) readAt(off, n int64) ([]byte, error) {
+	if off < 0 || n < 0 || off+n > r.total {
+		return nil, fmt.Errorf("zstd: %d bytes at offset %d out of range [0, %d)", n, off, r.total)
+	}
+	i := findFrame(r.frames, off)
+	if i < len(r.frames) && off+n <= r.frames[i].dataOff+r.frames[i].dataSz {
+		// Within one frame
+		if err := r.decode(i); err != nil {
+			return nil, err
+		}
+		start := off - r.frames[i].dataOff
+		return r.data[start : start+n], nil
+	}
+
+	out := make([]byte, 0, n)
+	for ; int64(len(out)) < n; i++ {
+		if err := r.decode(i); err != nil {
+			return nil, err
+		}
+		start := off + int64(len(out)) - r.frames[i].dataOff
+		end := min(int64(len(r.data)), start+n-int64(len(out)))
+		out = append(out, r.data[start:end]...)
+	}
+	return out, nil
+}
+
+// decode loads frame i into r.data
+func (r *seekableZstdReader) decode(i int) error {
+	if r.cached == i {
+		return nil
+	}
+	r.cached = -1
+	fr := r.frames[i]
+	if int64(cap(r.comp)) < fr.size {
+		r.comp = make([]byte, fr.size)
+	}
+	r.comp = r.comp[:fr.size]
+	if _, err := r.file.ReadAt(r.comp, fr.off); err != nil {
+		return fmt.Errorf("zstd: frame %d: %w", i, err)
+	}
+	data, err := r.dec.DecodeAll(r.comp, r.data[:0])
+	if err != nil {
+		return fmt.Errorf("zstd: frame %d: %w", i, err)
+	}
+	if int64(len(data)) != fr.dataSz {
+		return fmt.Errorf("zstd: frame %d: got %d bytes, seek table says %d", i, len(data), fr.dataSz)
+	}
+	r.data, r.cached = data, i
+	return nil
+}
+
+func (r *seekableZstdReader) Close() error {
+	r.dec.Close()
+	return r.file.Close()
+}
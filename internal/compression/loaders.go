@@ -2,233 +2,294 @@ package compression
 
 import (
 	"bufio"
-	"bytes"
 	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"net/http"
-	"os"
 	"strconv"
 	"strings"
 
 	"github.com/andybalholm/brotli"
 	"github.com/klauspost/compress/zstd"
+	"github.com/parf/homebase-go-lib/fileiterator"
 	"github.com/pierrec/lz4/v4"
 	"github.com/ulikunitz/xz"
 )
 
-// openFileOrURL opens a file or HTTP URL and returns an io.ReadCloser
-func openFileOrURL(file_or_url string) io.ReadCloser {
-	if strings.HasPrefix(file_or_url, "http") {
-		resp, err := http.Get(file_or_url)
+// openFileOrURLE opens a file or HTTP URL without decompression
+// (HTTP sources get fileiterator retries and resume)
+func openFileOrURLE(file_or_url string) (io.ReadCloser, error) {
+	return fileiterator.OpenWithOptions(context.Background(), file_or_url, fileiterator.OpenOptions{Codec: fileiterator.CodecNone})
+}
+
+// decoder wraps a compressed source with a decompressor
+type decoder func(io.Reader) (io.Reader, error)
+
+var (
+	gzipDecoder = func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }
+	zstdDecoder = func(r io.Reader) (io.Reader, error) {
+		zr, err := zstd.NewReader(r)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		if resp.StatusCode != 200 {
-			err := fmt.Errorf("Url: %s - Unexpected HTTP Code %d", file_or_url, resp.StatusCode)
-			panic(err)
-		}
-		return resp.Body
-	}
-	r, err := os.Open(file_or_url)
-	if err != nil {
-		panic(err)
+		return zr.IOReadCloser(), nil
 	}
-	return r
+	lz4Decoder    = func(r io.Reader) (io.Reader, error) { return lz4.NewReader(r), nil }
+	brotliDecoder = func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }
+	xzDecoder     = func(r io.Reader) (io.Reader, error) { return xz.NewReader(r) }
+)
+
+// sourceReader remembers the last error of the compressed source,
+// so decoder format errors can be told apart from I/O errors
+type sourceReader struct {
+	io.Reader
+	err error
 }
 
-// LoadBinGzFile loads a gzipped file into a byte buffer
-func LoadBinGzFile(filename string, dest *[]byte) {
-	fi := openFileOrURL(filename)
-	defer fi.Close()
-	fz, err := gzip.NewReader(fi)
-	if err != nil {
-		panic(err)
+func (s *sourceReader) Read(p []byte) (int, error) {
+	n, err := s.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		s.err = err
 	}
-	defer fz.Close()
-	*dest, err = ioutil.ReadAll(fz)
-	if err != nil {
-		panic(err)
+	return n, err
+}
+
+// decodeError reports a decoder failure as *ErrCorruptStream; errors of the source
+// (I/O, permissions, cancelled HTTP transfers) are returned as they are
+func decodeError(codec string, src *sourceReader, err error) error {
+	if src.err != nil {
+		return src.err
 	}
-	fmt.Printf("File %s loaded. %d bytes\n", filename, len(*dest))
+	return &fileiterator.ErrCorruptStream{Codec: codec, Err: err}
 }
 
-// LoadBinZstdFile loads a zstd-compressed file into a byte buffer
-func LoadBinZstdFile(filename string, dest *[]byte) {
-	fi := openFileOrURL(filename)
+// loadBin reads a whole compressed file
+func loadBin(filename, codec string, newDecoder decoder) ([]byte, error) {
+	fi, err := openFileOrURLE(filename)
+	if err != nil {
+		return nil, err
+	}
 	defer fi.Close()
 
-	fz, err := zstd.NewReader(fi)
+	src := &sourceReader{Reader: fi}
+	fz, err := newDecoder(src)
 	if err != nil {
-		panic(err)
+		return nil, decodeError(codec, src, err)
 	}
-	defer fz.Close()
-	*dest, err = ioutil.ReadAll(fz)
+	if c, ok := fz.(io.Closer); ok {
+		defer c.Close()
+	}
+	data, err := io.ReadAll(fz)
 	if err != nil {
-		panic(err)
+		return nil, decodeError(codec, src, err)
 	}
-	fmt.Printf("File %s loaded. %d bytes\n", filename, len(*dest))
+	return data, nil
 }
 
-// IterateLinesGz processes lines in a gzipped file (explicit gzip)
-func IterateLinesGz(filename string, processor func(string)) {
-	fi := openFileOrURL(filename)
+// iterateLines calls processor for every line of a compressed file
+func iterateLines(filename, codec string, newDecoder decoder, processor func(string) error) error {
+	fi, err := openFileOrURLE(filename)
+	if err != nil {
+		return err
+	}
 	defer fi.Close()
 
-	fz, err := gzip.NewReader(fi)
+	src := &sourceReader{Reader: fi}
+	fz, err := newDecoder(src)
 	if err != nil {
-		fmt.Printf("File %s\n", filename)
-		panic(err)
+		return decodeError(codec, src, err)
+	}
+	if c, ok := fz.(io.Closer); ok {
+		defer c.Close()
 	}
-	defer fz.Close()
 	scanner := bufio.NewScanner(fz)
 	count := 0
 	for scanner.Scan() {
 		line := scanner.Text()
-		processor(line)
 		count++
+		if err := processor(line); err != nil {
+			return fmt.Errorf("line %d: processor error: %w", count, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("line %d: %w", count+1, err)
+		}
+		return decodeError(codec, src, err)
 	}
 	fmt.Printf("File %s. Lines processed: %d\n", filename, count)
+	return nil
 }
 
-// IterateLinesZstd processes lines in a zstd-compressed file
-func IterateLinesZstd(filename string, processor func(string)) {
-	fi := openFileOrURL(filename)
-	defer fi.Close()
+// legacy adapts a processor without error result
+func legacy(processor func(string)) func(string) error {
+	return func(line string) error {
+		processor(line)
+		return nil
+	}
+}
+
+// LoadBinGzFile loads a gzipped file into a byte buffer
+func LoadBinGzFile(filename string, dest *[]byte) {
+	var err error
+	*dest, err = LoadBinGzFileE(filename)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("File %s loaded. %d bytes\n", filename, len(*dest))
+}
+
+// LoadBinGzFileE is the error-returning version of LoadBinGzFile
+func LoadBinGzFileE(filename string) ([]byte, error) {
+	return loadBin(filename, fileiterator.CodecGzip, gzipDecoder)
+}
 
-	fz, err := zstd.NewReader(fi)
+// LoadBinZstdFile loads a zstd-compressed file into a byte buffer
+func LoadBinZstdFile(filename string, dest *[]byte) {
+	var err error
+	*dest, err = LoadBinZstdFileE(filename)
 	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("File %s loaded. %d bytes\n", filename, len(*dest))
+}
+
+// LoadBinZstdFileE is the error-returning version of LoadBinZstdFile
+func LoadBinZstdFileE(filename string) ([]byte, error) {
+	return loadBin(filename, fileiterator.CodecZstd, zstdDecoder)
+}
+
+// IterateLinesGz processes lines in a gzipped file (explicit gzip)
+func IterateLinesGz(filename string, processor func(string)) {
+	if err := IterateLinesGzE(filename, legacy(processor)); err != nil {
 		fmt.Printf("File %s\n", filename)
 		panic(err)
 	}
-	defer fz.Close()
-	scanner := bufio.NewScanner(fz)
-	count := 0
-	for scanner.Scan() {
-		line := scanner.Text()
-		processor(line)
-		count++
+}
+
+// IterateLinesGzE is the error-returning version of IterateLinesGz
+func IterateLinesGzE(filename string, processor func(string) error) error {
+	return iterateLines(filename, fileiterator.CodecGzip, gzipDecoder, processor)
+}
+
+// IterateLinesZstd processes lines in a zstd-compressed file
+func IterateLinesZstd(filename string, processor func(string)) {
+	if err := IterateLinesZstdE(filename, legacy(processor)); err != nil {
+		fmt.Printf("File %s\n", filename)
+		panic(err)
 	}
-	fmt.Printf("File %s. Lines processed: %d\n", filename, count)
+}
+
+// IterateLinesZstdE is the error-returning version of IterateLinesZstd
+func IterateLinesZstdE(filename string, processor func(string) error) error {
+	return iterateLines(filename, fileiterator.CodecZstd, zstdDecoder, processor)
 }
 
 // LoadBinLz4File loads an LZ4-compressed file into a byte buffer
 func LoadBinLz4File(filename string, dest *[]byte) {
-	fi := openFileOrURL(filename)
-	defer fi.Close()
-
-	fz := lz4.NewReader(fi)
 	var err error
-	*dest, err = ioutil.ReadAll(fz)
+	*dest, err = LoadBinLz4FileE(filename)
 	if err != nil {
 		panic(err)
 	}
 	fmt.Printf("File %s loaded. %d bytes\n", filename, len(*dest))
 }
 
+// LoadBinLz4FileE is the error-returning version of LoadBinLz4File
+func LoadBinLz4FileE(filename string) ([]byte, error) {
+	return loadBin(filename, fileiterator.CodecLz4, lz4Decoder)
+}
+
 // IterateLinesLz4 processes lines in an LZ4-compressed file
 func IterateLinesLz4(filename string, processor func(string)) {
-	fi := openFileOrURL(filename)
-	defer fi.Close()
-
-	fz := lz4.NewReader(fi)
-	scanner := bufio.NewScanner(fz)
-	count := 0
-	for scanner.Scan() {
-		line := scanner.Text()
-		processor(line)
-		count++
+	if err := IterateLinesLz4E(filename, legacy(processor)); err != nil {
+		panic(err)
 	}
-	fmt.Printf("File %s. Lines processed: %d\n", filename, count)
+}
+
+// IterateLinesLz4E is the error-returning version of IterateLinesLz4
+func IterateLinesLz4E(filename string, processor func(string) error) error {
+	return iterateLines(filename, fileiterator.CodecLz4, lz4Decoder, processor)
 }
 
 // LoadBinBrotliFile loads a Brotli-compressed file into a byte buffer
 func LoadBinBrotliFile(filename string, dest *[]byte) {
-	fi := openFileOrURL(filename)
-	defer fi.Close()
-
-	br := brotli.NewReader(fi)
 	var err error
-	*dest, err = ioutil.ReadAll(br)
+	*dest, err = LoadBinBrotliFileE(filename)
 	if err != nil {
 		panic(err)
 	}
 	fmt.Printf("File %s loaded. %d bytes\n", filename, len(*dest))
 }
 
+// LoadBinBrotliFileE is the error-returning version of LoadBinBrotliFile
+func LoadBinBrotliFileE(filename string) ([]byte, error) {
+	return loadBin(filename, fileiterator.CodecBrotli, brotliDecoder)
+}
+
 // IterateLinesBrotli processes lines in a Brotli-compressed file
 func IterateLinesBrotli(filename string, processor func(string)) {
-	fi := openFileOrURL(filename)
-	defer fi.Close()
-
-	br := brotli.NewReader(fi)
-	scanner := bufio.NewScanner(br)
-	count := 0
-	for scanner.Scan() {
-		line := scanner.Text()
-		processor(line)
-		count++
+	if err := IterateLinesBrotliE(filename, legacy(processor)); err != nil {
+		panic(err)
 	}
-	fmt.Printf("File %s. Lines processed: %d\n", filename, count)
+}
+
+// IterateLinesBrotliE is the error-returning version of IterateLinesBrotli
+func IterateLinesBrotliE(filename string, processor func(string) error) error {
+	return iterateLines(filename, fileiterator.CodecBrotli, brotliDecoder, processor)
 }
 
 // LoadBinXzFile loads an XZ-compressed file into a byte buffer
 func LoadBinXzFile(filename string, dest *[]byte) {
-	fi := openFileOrURL(filename)
-	defer fi.Close()
-
-	xzr, err := xz.NewReader(fi)
-	if err != nil {
-		panic(err)
-	}
-	*dest, err = ioutil.ReadAll(xzr)
+	var err error
+	*dest, err = LoadBinXzFileE(filename)
 	if err != nil {
 		panic(err)
 	}
 	fmt.Printf("File %s loaded. %d bytes\n", filename, len(*dest))
 }
 
+// LoadBinXzFileE is the error-returning version of LoadBinXzFile
+func LoadBinXzFileE(filename string) ([]byte, error) {
+	return loadBin(filename, fileiterator.CodecXz, xzDecoder)
+}
+
 // IterateLinesXz processes lines in an XZ-compressed file
 func IterateLinesXz(filename string, processor func(string)) {
-	fi := openFileOrURL(filename)
-	defer fi.Close()
-
-	xzr, err := xz.NewReader(fi)
-	if err != nil {
+	if err := IterateLinesXzE(filename, legacy(processor)); err != nil {
 		fmt.Printf("File %s\n", filename)
 		panic(err)
 	}
-	scanner := bufio.NewScanner(xzr)
-	count := 0
-	for scanner.Scan() {
-		line := scanner.Text()
-		processor(line)
-		count++
-	}
-	fmt.Printf("File %s. Lines processed: %d\n", filename, count)
+}
+
+// IterateLinesXzE is the error-returning version of IterateLinesXz
+func IterateLinesXzE(filename string, processor func(string) error) error {
+	return iterateLines(filename, fileiterator.CodecXz, xzDecoder, processor)
 }
 
 // LoadIDTabGzFile iterates over TAB separated (ID <tab> NAME) GZIP file
 func LoadIDTabGzFile(filename string, processor func(int32, string)) {
-	fi := openFileOrURL(filename)
-	defer fi.Close()
-	fz, err := gzip.NewReader(fi)
+	err := LoadIDTabGzFileE(filename, func(id int32, name string) error {
+		processor(id, name)
+		return nil
+	})
 	if err != nil {
 		panic(err)
 	}
-	defer fz.Close()
-	scanner := bufio.NewScanner(fz)
-	count := 0
-	for scanner.Scan() {
-		line := scanner.Text()
+}
+
+// LoadIDTabGzFileE is the error-returning version of LoadIDTabGzFile
+func LoadIDTabGzFileE(filename string, processor func(int32, string) error) error {
+	return iterateLines(filename, fileiterator.CodecGzip, gzipDecoder, func(line string) error {
 		lc := strings.Split(line, "\t")
+		if len(lc) < 2 {
+			return fmt.Errorf("expected ID<tab>NAME, got %q", line)
+		}
 		id, err := strconv.ParseInt(lc[0], 16, 32)
 		if err != nil {
-			panic(err)
+			return err
 		}
-		processor(int32(id), fmt.Sprintf("%s", bytes.ToLower([]byte(lc[1]))))
-		count++
-	}
-	fmt.Printf("File %s. Lines processed: %d\n", filename, count)
+		return processor(int32(id), strings.ToLower(lc[1]))
+	})
 }
@@ -3,6 +3,7 @@ package compression_test
 import (
 	"bytes"
 	"compress/gzip"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -10,8 +11,9 @@ import (
 
 	"github.com/andybalholm/brotli"
 	"github.com/klauspost/compress/zstd"
-	"github.com/pierrec/lz4/v4"
+	"github.com/parf/homebase-go-lib/fileiterator"
 	"github.com/parf/homebase-go-lib/internal/compression"
+	"github.com/pierrec/lz4/v4"
 	"github.com/ulikunitz/xz"
 )
 
@@ -229,3 +231,33 @@ func TestLoadIDTabGzFile(t *testing.T) {
 		t.Errorf("Expected 3 entries, got %d", count)
 	}
 }
+
+func TestLoaderErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	_, err := compression.LoadBinZstdFileE(filepath.Join(tmpDir, "missing.zst"))
+	if !errors.Is(err, fileiterator.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	badFile := filepath.Join(tmpDir, "bad.gz")
+	os.WriteFile(badFile, []byte("not gzip at all"), 0644)
+
+	err = compression.IterateLinesGzE(badFile, func(string) error { return nil })
+	var corrupt *fileiterator.ErrCorruptStream
+	if !errors.As(err, &corrupt) || corrupt.Codec != fileiterator.CodecGzip {
+		t.Errorf("Expected ErrCorruptStream{gzip}, got %v", err)
+	}
+
+	// A read error of the file is not a corrupt stream
+	dir := filepath.Join(tmpDir, "dir.zst")
+	os.Mkdir(dir, 0755)
+	_, err = compression.LoadBinZstdFileE(dir)
+	if err == nil || errors.As(err, &corrupt) {
+		t.Errorf("Expected a plain read error, got %v", err)
+	}
+	err = compression.IterateLinesGzE(dir, func(string) error { return nil })
+	if err == nil || errors.As(err, &corrupt) {
+		t.Errorf("Expected a plain read error, got %v", err)
+	}
+}
@@ -0,0 +1,320 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/parf/homebase-go-lib/clistat"
+)
+
+// AsyncOptions configures an AsyncInserter
+type AsyncOptions struct {
+	// InserterOptions of every worker; TxPerLoad is not supported
+	// (workers commit independently, so a load cannot be all or nothing)
+	InserterOptions
+
+	// Workers is the number of goroutines flushing batches, each on its own connection (0 - 4)
+	Workers int
+
+	// Queue is the number of full batches waiting for a worker (0 - 2*Workers);
+	// Insert blocks while the queue is full
+	Queue int
+
+	// Stat reports throughput (rows inserted) through clistat every Stat seconds; 0 - no reports
+	Stat int64
+}
+
+// asyncBatch is a batch of rows queued for a worker
+type asyncBatch struct {
+	num   int   // 1-based batch number
+	first int64 // 1-based number of the first row
+	rows  []any
+}
+
+// AsyncInserter is a pipelined Inserter: Insert collects rows into batches and queues
+// them, Workers goroutines insert the batches concurrently, each on its own connection.
+// The producer only blocks when Queue batches are waiting (backpressure).
+//
+// The first failure stops the load: Insert returns it, queued batches are dropped and
+// Close returns all worker errors (errors.Join of *BatchError, numbered across the whole load).
+// Batches are inserted in parallel, so rows may reach the table out of order.
+// Insert, Flush and Close must be called from one goroutine.
+//
+// Example:
+//
+//	ins, err := sql.NewAsyncInserter(ctx, db, "events", "id, name", sql.AsyncOptions{
+//	    InserterOptions: sql.InserterOptions{Param: true, Tx: sql.TxPerFlush},
+//	    Workers:         8,
+//	    Stat:            10,
+//	})
+//	...
+//	for ... {
+//	    if err := ins.Insert(ctx, []any{id, name}); err != nil {
+//	        break
+//	    }
+//	}
+//	err = ins.Close() // waits for the workers
+type AsyncInserter struct {
+	opts   AsyncOptions
+	table  string
+	cols   int
+	ctx    context.Context // workers' context, cancelled by the first failure
+	cancel context.CancelFunc
+	queue  chan asyncBatch
+	wg     sync.WaitGroup
+
+	batch   []any // rows of the batch being collected
+	batches int   // batches queued
+	rows    int64 // rows accepted by Insert
+
+	count  atomic.Int64 // rows inserted
+	failed atomic.Bool
+	mu     sync.Mutex // guards errs and stat
+	errs   []error
+	stat   *clistat.CliStat
+	closed bool
+}
+
+// NewAsyncInserter starts opts.Workers workers inserting into table
+// (fields - comma delimited field list). Every worker takes a dedicated connection
+// from db; ctx bounds the whole load - cancelling it stops the workers.
+func NewAsyncInserter(ctx context.Context, db *sql.DB, table, fields string, opts AsyncOptions) (*AsyncInserter, error) {
+	if opts.Tx == TxPerLoad {
+		return nil, errors.New("AsyncInserter: TxPerLoad is not supported (workers commit independently)")
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	if opts.Copy || opts.LoadData {
+		opts.Param = true
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.Queue <= 0 {
+		opts.Queue = 2 * opts.Workers
+	}
+
+	a := &AsyncInserter{
+		opts:  opts,
+		table: table,
+		cols:  len(strings.Split(fields, ",")),
+		queue: make(chan asyncBatch, opts.Queue),
+	}
+	a.ctx, a.cancel = context.WithCancel(ctx)
+
+	type worker struct {
+		conn *sql.Conn
+		ins  *Inserter
+	}
+	workers := make([]worker, 0, opts.Workers)
+	release := func() {
+		for _, w := range workers {
+			w.conn.Close()
+		}
+		a.cancel()
+	}
+	for range opts.Workers {
+		conn, err := db.Conn(a.ctx)
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("AsyncInserter: %w", err)
+		}
+		ins, err := newInserter(conn, table, fields, opts.InserterOptions)
+		if err != nil {
+			conn.Close()
+			release()
+			return nil, err
+		}
+		workers = append(workers, worker{conn, ins})
+	}
+
+	if opts.Stat > 0 {
+		stat := clistat.New(opts.Stat)
+		a.stat = &stat
+	}
+	a.wg.Add(len(workers))
+	for _, w := range workers {
+		go a.work(w.conn, w.ins)
+	}
+	return a, nil
+}
+
+// work inserts queued batches until the queue is closed
+func (a *AsyncInserter) work(conn *sql.Conn, ins *Inserter) {
+	defer a.wg.Done()
+	defer conn.Close()
+
+	var err error
+	for batch := range a.queue {
+		if err != nil || a.failed.Load() {
+			continue // drop the rest of the load
+		}
+		if err = a.insertBatch(ins, batch); err != nil {
+			a.fail(err)
+		}
+	}
+	if err != nil {
+		ins.Rollback()
+		return
+	}
+	if cerr := ins.Close(); cerr != nil {
+		a.fail(cerr)
+	}
+}
+
+// insertBatch inserts one batch and renumbers its errors within the whole load
+func (a *AsyncInserter) insertBatch(ins *Inserter, batch asyncBatch) error {
+	before := ins.Count()
+	for _, row := range batch.rows {
+		if err := ins.Insert(a.ctx, row); err != nil {
+			return renumber(err, batch, before)
+		}
+	}
+	if err := ins.Flush(a.ctx); err != nil {
+		return renumber(err, batch, before)
+	}
+
+	n := int64(len(batch.rows))
+	a.count.Add(n)
+	if a.stat != nil {
+		a.mu.Lock()
+		a.stat.Add(n)
+		a.mu.Unlock()
+	}
+	return nil
+}
+
+// renumber converts the worker-local numbers of a *BatchError to numbers within the load
+func renumber(err error, batch asyncBatch, before int64) error {
+	var be *BatchError
+	if errors.As(err, &be) {
+		be.Batch = batch.num
+		be.FirstRow = batch.first + (be.FirstRow - before - 1)
+	}
+	return err
+}
+
+// fail records a worker error and stops the load; context.Canceled errors of
+// other workers, caused by the cancel of the first failure, are not recorded
+func (a *AsyncInserter) fail(err error) {
+	a.mu.Lock()
+	if len(a.errs) == 0 || !errors.Is(err, context.Canceled) {
+		a.errs = append(a.errs, err)
+	}
+	a.mu.Unlock()
+	a.failed.Store(true)
+	a.cancel()
+}
+
+// err returns the first worker error
+func (a *AsyncInserter) err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.errs) == 0 {
+		return nil
+	}
+	return a.errs[0]
+}
+
+// Count returns the number of rows inserted so far
+func (a *AsyncInserter) Count() int64 {
+	return a.count.Load()
+}
+
+// Insert adds a row to the current batch and queues the batch when it holds BufferSize rows,
+// waiting while the queue is full. values is a slice/array with one value per field, or
+// (without Param) a raw SQL string that YOU must escape. The row is copied, so values
+// may be reused after Insert returns (the slice - not []byte or other values it holds).
+// An invalid row is reported as *BatchError and skipped; after a worker failure Insert
+// returns the first failure.
+func (a *AsyncInserter) Insert(ctx context.Context, values any) error {
+	if a.closed {
+		return ErrInserterClosed
+	}
+	if a.failed.Load() {
+		return a.err()
+	}
+	row, err := copyRow(values, a.opts.Param, a.cols)
+	if err != nil {
+		return &BatchError{Table: a.table, Batch: a.batches + 1, FirstRow: a.rows + 1, Rows: 1, Row: values, Err: err}
+	}
+	a.batch = append(a.batch, row)
+	a.rows++
+	if len(a.batch) >= a.opts.BufferSize {
+		return a.Flush(ctx)
+	}
+	return nil
+}
+
+// Flush queues the current batch, even when it is not full
+func (a *AsyncInserter) Flush(ctx context.Context) error {
+	if a.closed {
+		return ErrInserterClosed
+	}
+	if len(a.batch) == 0 {
+		return nil
+	}
+	a.batches++
+	batch := asyncBatch{num: a.batches, first: a.rows - int64(len(a.batch)) + 1, rows: a.batch}
+	a.batch = make([]any, 0, a.opts.BufferSize)
+	select {
+	case a.queue <- batch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-a.ctx.Done():
+		if err := a.err(); err != nil {
+			return err
+		}
+		return a.ctx.Err()
+	}
+}
+
+// Close queues the last batch, waits for the workers to insert everything and
+// returns all their errors joined
+func (a *AsyncInserter) Close() error {
+	if a.closed {
+		return nil
+	}
+	var err error
+	if !a.failed.Load() {
+		err = a.Flush(a.ctx)
+	}
+	a.closed = true
+	close(a.queue)
+	a.wg.Wait()
+	a.cancel()
+
+	if a.stat != nil {
+		a.stat.Finish()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.errs) > 0 {
+		return errors.Join(a.errs...)
+	}
+	return err
+}
+
+// copyRow validates a row and copies it for a worker: a Param row becomes a new []any,
+// a raw SQL row its escaped literal string
+func copyRow(values any, param bool, cols int) (any, error) {
+	if !param {
+		return valuesToString(values)
+	}
+	row, err := rowValues(values)
+	if err != nil {
+		return nil, err
+	}
+	if len(row) != cols {
+		return nil, fmt.Errorf("got %d values for %d fields", len(row), cols)
+	}
+	return slices.Clone(row), nil
+}
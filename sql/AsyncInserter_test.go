@@ -0,0 +1,147 @@
+package sql_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	hbsql "github.com/parf/homebase-go-lib/sql"
+)
+
+func TestAsyncInserter(t *testing.T) {
+	db, d := openRecording(t)
+	ctx := context.Background()
+	ins, err := hbsql.NewAsyncInserter(ctx, db, "t", "id, name", hbsql.AsyncOptions{
+		InserterOptions: hbsql.InserterOptions{BufferSize: 7, Param: true, Tx: hbsql.TxPerFlush},
+		Workers:         3,
+		Queue:           1,
+		Stat:            1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := []any{int64(0), "x"} // reused - Insert copies it
+	for i := range 100 {
+		row[0] = int64(i)
+		if err := ins.Insert(ctx, row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ins.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ins.Count() != 100 {
+		t.Errorf("Count = %d, want 100", ins.Count())
+	}
+	if err := ins.Insert(ctx, []any{int64(100), "x"}); !errors.Is(err, hbsql.ErrInserterClosed) {
+		t.Errorf("Insert after Close: %v", err)
+	}
+
+	// every row inserted exactly once, 15 batches (14 full + 2 rows)
+	var ids []int
+	for _, e := range d.execs {
+		for j := 0; j < len(e.args); j += 2 {
+			ids = append(ids, int(e.args[j].(int64)))
+		}
+	}
+	sort.Ints(ids)
+	if len(ids) != 100 {
+		t.Fatalf("inserted %d rows, want 100", len(ids))
+	}
+	for i, id := range ids {
+		if id != i {
+			t.Fatalf("row %d: id %d", i, id)
+		}
+	}
+	if len(d.execs) != 15 {
+		t.Errorf("expected 15 statements, got %d", len(d.execs))
+	}
+}
+
+func TestAsyncInserterError(t *testing.T) {
+	db, _ := openRecording(t)
+	ctx := context.Background()
+	// 2 rows per statement, 10 rows per batch
+	ins, err := hbsql.NewAsyncInserter(ctx, db, "t", "id, name", hbsql.AsyncOptions{
+		InserterOptions: hbsql.InserterOptions{BufferSize: 10, Param: true, MaxParams: 4},
+		Workers:         2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// invalid rows are skipped
+	var be *hbsql.BatchError
+	if err := ins.Insert(ctx, []any{1}); !errors.As(err, &be) || be.FirstRow != 1 {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	var insertErr error
+	for i := 1; i <= 1000 && insertErr == nil; i++ {
+		name := "x"
+		if i == 50 {
+			name = "fail"
+		}
+		insertErr = ins.Insert(ctx, []any{i, name})
+	}
+	closeErr := ins.Close()
+	if closeErr == nil {
+		t.Fatal("expected an error")
+	}
+	if !errors.As(closeErr, &be) {
+		t.Fatalf("expected *BatchError, got %v", closeErr)
+	}
+	if errors.Is(closeErr, context.Canceled) {
+		t.Errorf("cancellation of the other workers reported: %v", closeErr)
+	}
+	if be.Batch != 5 || be.FirstRow != 49 || be.Rows != 2 || be.Row.([]any)[0] != 49 {
+		t.Errorf("unexpected %+v", be)
+	}
+	if insertErr != nil && !errors.As(insertErr, &be) && !errors.Is(insertErr, context.Canceled) {
+		t.Errorf("Insert after failure: %v", insertErr)
+	}
+	if ins.Count() >= 1000 {
+		t.Errorf("load did not stop: %d rows", ins.Count())
+	}
+}
+
+func TestAsyncInserterCancelledWorkers(t *testing.T) {
+	db, _ := openRecording(t)
+	ctx := context.Background()
+	// 1 row per statement: the worker of the slow batch is cancelled between statements
+	ins, err := hbsql.NewAsyncInserter(ctx, db, "t", "id, name", hbsql.AsyncOptions{
+		InserterOptions: hbsql.InserterOptions{BufferSize: 5, Param: true, MaxParams: 2},
+		Workers:         2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 10; i++ {
+		name := "slow"
+		if i == 6 {
+			name = "fail" // while the first batch is being inserted
+		}
+		if ins.Insert(ctx, []any{i, name}) != nil {
+			break
+		}
+	}
+	err = ins.Close()
+	var be *hbsql.BatchError
+	if !errors.As(err, &be) || be.FirstRow != 6 {
+		t.Fatalf("expected *BatchError of row 6, got %v", err)
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Errorf("cancellation of the other worker reported: %v", err)
+	}
+}
+
+func TestAsyncInserterTxPerLoad(t *testing.T) {
+	db, _ := openRecording(t)
+	_, err := hbsql.NewAsyncInserter(context.Background(), db, "t", "id", hbsql.AsyncOptions{
+		InserterOptions: hbsql.InserterOptions{Tx: hbsql.TxPerLoad},
+	})
+	if err == nil {
+		t.Error("TxPerLoad accepted")
+	}
+}
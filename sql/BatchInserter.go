@@ -1,6 +1,7 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"reflect"
@@ -27,9 +28,7 @@ import (
  * 		insert("1, 'Red Sox', 99.95")  // String format - YOU must escape values yourself
  * }
  *
- * TODO:
- *   implement this via channels and parallel go thread
- *   https://goinbigdata.com/golang-wait-for-all-goroutines-to-finish/
+ * Parallel inserts (batches flushed by worker goroutines): see AsyncInserter
  *
  */
 
@@ -101,22 +100,22 @@ func EscapeValue(val any) string {
 // valuesToString converts values (string or slice) to a SQL VALUES string.
 // If values is a string, returns it as-is (unsafe, user must escape).
 // If values is a slice/array, escapes each element and joins with commas (safe).
-func valuesToString(values any) string {
+func valuesToString(values any) (string, error) {
 	// If it's a string, use as-is (backward compatible)
 	if str, ok := values.(string); ok {
-		return str
+		return str, nil
 	}
 
 	// Use reflection to handle any slice type
 	v := reflect.ValueOf(values)
 	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
-		panic(fmt.Sprintf("BatchInserter: values must be string, slice, or array (got %T)", values))
+		return "", fmt.Errorf("values must be string, slice, or array (got %T)", values)
 	}
 
 	// Extract slice elements and escape them
 	length := v.Len()
 	if length == 0 {
-		return ""
+		return "", nil
 	}
 
 	escaped := make([]string, length)
@@ -124,7 +123,7 @@ func valuesToString(values any) string {
 		escaped[i] = EscapeValue(v.Index(i).Interface())
 	}
 
-	return strings.Join(escaped, ", ")
+	return strings.Join(escaped, ", "), nil
 }
 
 // BatchInserter creates a batch inserter for an existing database connection.
@@ -170,7 +169,10 @@ func BatchInserter(db *sql.DB, table string, fields string, bufferSize int) (ins
 		cnt = 0
 	}
 	insert = func(values any) {
-		row := valuesToString(values)
+		row, err := valuesToString(values)
+		if err != nil {
+			panic("BatchInserter: " + err.Error())
+		}
 		buffer = append(buffer, row)
 		cnt++
 		if cnt > bufferSize {
@@ -181,8 +183,12 @@ func BatchInserter(db *sql.DB, table string, fields string, bufferSize int) (ins
 }
 
 // PostgreBatchInserter creates a batch inserter optimized for PostgreSQL.
-// It's functionally identical to BatchInserter but makes the intent clear
-// and can be extended with PostgreSQL-specific optimizations in the future.
+// With the lib/pq driver batches are written with COPY FROM STDIN (see InserterOptions.Copy),
+// otherwise - or when COPY is not available - as multi-row INSERT with bind parameters.
+// Values are passed to the driver as they are: no escaping is involved.
+//
+// Raw SQL string rows (the legacy form) are still accepted when the first row is a string:
+// the inserter then works like BatchInserter.
 //
 // Usage:
 //
@@ -190,12 +196,46 @@ func BatchInserter(db *sql.DB, table string, fields string, bufferSize int) (ins
 //	defer flush()
 //
 //	for ... {
-//		insert([]any{val1, val2, val3})  // Auto-escaped
+//		insert([]any{val1, val2, val3})
 //	}
 func PostgreBatchInserter(db *sql.DB, table string, fields string, bufferSize int) (insert func(any), flush func()) {
-	// PostgreSQL uses the same batch insert syntax as MySQL
-	// The EscapeValue function handles standard SQL escaping which works for both
-	return BatchInserter(db, table, fields, bufferSize)
+	var (
+		ins          *Inserter
+		legacyInsert func(any)
+		legacyFlush  func()
+	)
+	insert = func(values any) {
+		if ins == nil && legacyInsert == nil {
+			if _, ok := values.(string); ok {
+				legacyInsert, legacyFlush = BatchInserter(db, table, fields, bufferSize)
+			} else {
+				var err error
+				ins, err = NewInserter(db, table, fields, InserterOptions{
+					BufferSize: bufferSize, Param: true, Placeholder: DollarPlaceholder, Copy: IsLibPQ(db)})
+				if err != nil {
+					panic("PostgreBatchInserter: " + err.Error())
+				}
+			}
+		}
+		if legacyInsert != nil {
+			legacyInsert(values)
+			return
+		}
+		if err := ins.Insert(context.Background(), values); err != nil {
+			panic(err)
+		}
+	}
+	flush = func() {
+		switch {
+		case legacyFlush != nil:
+			legacyFlush()
+		case ins != nil:
+			if err := ins.Flush(context.Background()); err != nil {
+				panic(err)
+			}
+		}
+	}
+	return
 }
 
 // PostgreBatchDBInserter creates a batch inserter with a new PostgreSQL connection.
@@ -0,0 +1,372 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// TxMode selects the transactions an Inserter wraps its statements in
+type TxMode int
+
+const (
+	// NoTx - every statement commits on its own
+	NoTx TxMode = iota
+	// TxPerFlush - every flush (batch) is one transaction: a batch is inserted completely or not at all
+	TxPerFlush
+	// TxPerLoad - one transaction for the whole load, committed by Close:
+	// nothing is inserted unless every batch succeeds. The transaction begins with the first
+	// flush and is bound to its context (cancelling it rolls the load back)
+	TxPerLoad
+)
+
+// DefaultBufferSize is the default number of rows per batch
+const DefaultBufferSize = 1000
+
+// ErrInserterClosed is returned by an Inserter after Close or Rollback
+var ErrInserterClosed = errors.New("inserter is closed")
+
+// InserterOptions configures an Inserter
+type InserterOptions struct {
+	// BufferSize is the number of rows per batch (0 - DefaultBufferSize)
+	BufferSize int
+
+	// Param sends values as bind parameters (see ParamBatchInserter) instead of escaped
+	// SQL literals (see BatchInserter); raw SQL string rows are only accepted without Param
+	Param bool
+
+	// Placeholder and MaxParams configure Param mode (see ParamBatchOptions)
+	Placeholder Placeholder
+	MaxParams   int
+
+	// Copy writes batches with PostgreSQL COPY FROM STDIN (lib/pq, see IsLibPQ) - several times
+	// faster than INSERT. It implies Param with DollarPlaceholder; when the driver or server
+	// has no COPY the Inserter falls back to multi-row INSERT (other errors are reported).
+	Copy bool
+
+	// LoadData writes batches with MySQL LOAD DATA LOCAL INFILE (see MySQLBulkLoader).
+	// It implies Param rows (slices, no raw SQL strings).
+	LoadData bool
+	// Loc is the time zone LoadData writes time.Time values in - the loc of the DSN,
+	// as go-sql-driver uses for INSERT (nil - UTC; OpenInserter takes it from the DSN)
+	Loc *time.Location
+
+	Tx TxMode
+}
+
+// BatchError reports a failed batch insert
+type BatchError struct {
+	Table    string
+	Batch    int   // 1-based number of the failed batch (flush)
+	FirstRow int64 // 1-based number (since the start of the load) of the first row of the failed statement
+	Rows     int   // rows in the failed statement
+	Row      any   // values of FirstRow, as passed to Insert
+	Err      error
+}
+
+func (e *BatchError) Error() string {
+	if e.Rows <= 1 {
+		return fmt.Sprintf("%s: batch %d, row %d %v: %v", e.Table, e.Batch, e.FirstRow, e.Row, e.Err)
+	}
+	return fmt.Sprintf("%s: batch %d, rows %d-%d (first row %v): %v",
+		e.Table, e.Batch, e.FirstRow, e.FirstRow+int64(e.Rows)-1, e.Row, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Inserter is the error-returning, context-aware form of BatchInserter and ParamBatchInserter.
+// Rows are buffered by Insert and written as multi-row INSERT statements every
+// BufferSize rows, by Flush and by Close. A failed statement is reported as *BatchError;
+// after that every call returns the same error (the current transaction is rolled back).
+// An Inserter is not safe for concurrent use.
+//
+// Example:
+//
+//	ins, err := sql.NewInserter(db, "users", "id, name, created", sql.InserterOptions{
+//	    Param: true, Placeholder: sql.PlaceholderFor("postgres"), Tx: sql.TxPerLoad})
+//	...
+//	for ... {
+//	    if err := ins.Insert(ctx, []any{id, name, time.Now()}); err != nil {
+//	        ins.Rollback()
+//	        return err
+//	    }
+//	}
+//	return ins.Close() // flushes the last batch and commits
+type Inserter struct {
+	db     dbConn    // nil after Close
+	owned  io.Closer // connection opened by OpenInserter, closed by Close
+	table  string
+	fields string
+	opts   InserterOptions
+	cols   int
+	chunk  int            // rows per statement in Param mode
+	stmts  map[int]string // Param mode statements by row count
+	copy   copyState
+
+	args  []any    // buffered values (Param mode)
+	lits  []string // buffered rows as SQL literals
+	first []any    // buffered rows as passed to Insert, for BatchError
+	rows  int      // buffered rows
+
+	count int64   // rows written by successful flushes
+	batch int     // flushes attempted
+	tx    *sql.Tx // TxPerLoad transaction
+	err   error   // sticky error (ErrInserterClosed after Close)
+}
+
+// PlaceholderFor returns the placeholder dialect of a database/sql driver name
+func PlaceholderFor(driverName string) Placeholder {
+	switch driverName {
+	case "postgres", "postgre", "postgresql", "pgx":
+		return DollarPlaceholder
+	}
+	return QuestionPlaceholder
+}
+
+// dbConn is *sql.DB or *sql.Conn
+type dbConn interface {
+	execer
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// NewInserter creates an Inserter writing to table (fields - comma delimited field list)
+func NewInserter(db *sql.DB, table, fields string, opts InserterOptions) (*Inserter, error) {
+	return newInserter(db, table, fields, opts)
+}
+
+// newInserter creates an Inserter on a database or a dedicated connection
+func newInserter(db dbConn, table, fields string, opts InserterOptions) (*Inserter, error) {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	if opts.Copy {
+		opts.Param, opts.Placeholder = true, DollarPlaceholder
+	}
+	if opts.LoadData {
+		opts.Param = true
+	}
+	b := &Inserter{db: db, table: table, fields: fields, opts: opts, cols: len(strings.Split(fields, ","))}
+	if opts.Copy {
+		b.copy = copyTry
+	}
+	if opts.Param {
+		if opts.MaxParams <= 0 {
+			opts.MaxParams = DefaultMaxParams
+		}
+		if b.cols > opts.MaxParams {
+			return nil, fmt.Errorf("%s: %d fields exceed the limit of %d parameters", table, b.cols, opts.MaxParams)
+		}
+		b.opts = opts
+		b.chunk = opts.MaxParams / b.cols
+		b.stmts = map[int]string{}
+	}
+	return b, nil
+}
+
+// OpenInserter opens a database connection (closed by Close) and creates an Inserter on it.
+// In Param mode the placeholder dialect is chosen by driverName (see PlaceholderFor).
+func OpenInserter(driverName, dsn, table, fields string, opts InserterOptions) (*Inserter, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	opts.Placeholder = PlaceholderFor(driverName)
+	if opts.LoadData && opts.Loc == nil {
+		opts.Loc = mysqlLoc(dsn)
+	}
+	b, err := NewInserter(db, table, fields, opts)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	b.owned = db
+	return b, nil
+}
+
+// Count returns the number of rows written by successful flushes
+// (with TxPerLoad they are committed by Close)
+func (b *Inserter) Count() int64 {
+	return b.count
+}
+
+// Insert buffers a row and flushes the buffer when it holds BufferSize rows.
+// values is a slice/array with one value per field, or (without Param) a raw SQL string
+// that YOU must escape. An invalid row is reported as *BatchError and skipped.
+func (b *Inserter) Insert(ctx context.Context, values any) error {
+	if b.err != nil {
+		return b.err
+	}
+
+	var err error
+	if b.opts.Param {
+		var row []any
+		if row, err = rowValues(values); err == nil {
+			if len(row) != b.cols {
+				err = fmt.Errorf("got %d values for %d fields (%s)", len(row), b.cols, b.fields)
+			} else {
+				b.args = append(b.args, row...)
+			}
+		}
+	} else {
+		var lit string
+		if lit, err = valuesToString(values); err == nil {
+			b.lits = append(b.lits, lit)
+		}
+	}
+	if err != nil {
+		return &BatchError{Table: b.table, Batch: b.batch + 1, FirstRow: b.count + int64(b.rows) + 1, Rows: 1, Row: values, Err: err}
+	}
+	b.first = append(b.first, values)
+	b.rows++
+
+	if b.rows >= b.opts.BufferSize {
+		return b.Flush(ctx)
+	}
+	return nil
+}
+
+// Flush writes the buffered rows
+func (b *Inserter) Flush(ctx context.Context) error {
+	if b.err != nil {
+		return b.err
+	}
+	if b.rows == 0 {
+		return nil
+	}
+	b.batch++
+	err := b.flush(ctx)
+	if err == nil {
+		b.count += int64(b.rows)
+	}
+	b.args, b.lits, b.first, b.rows = b.args[:0], b.lits[:0], b.first[:0], 0
+	if err != nil {
+		b.err = err
+		if b.tx != nil {
+			b.tx.Rollback()
+			b.tx = nil
+		}
+	}
+	return err
+}
+
+// execer is *sql.DB or *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// flush writes the buffered rows in the transaction of the TxMode
+func (b *Inserter) flush(ctx context.Context) (err error) {
+	if b.copy != copyOff {
+		err := b.copyFlush(ctx)
+		if !errors.Is(err, errCopyUnavailable) {
+			if err == nil {
+				b.copy = copyOn
+			}
+			return err
+		}
+		b.copy = copyOff // fall back to INSERT
+	}
+
+	var ex execer = b.db
+	switch b.opts.Tx {
+	case TxPerLoad:
+		if b.tx == nil {
+			// the transaction outlives this flush: database/sql would roll it back
+			// when ctx (e.g. a per-flush timeout) ends
+			if b.tx, err = b.db.BeginTx(context.WithoutCancel(ctx), nil); err != nil {
+				return b.batchError(0, b.rows, err)
+			}
+		}
+		ex = b.tx
+	case TxPerFlush:
+		tx, berr := b.db.BeginTx(ctx, nil)
+		if berr != nil {
+			return b.batchError(0, b.rows, berr)
+		}
+		defer func() {
+			if err != nil {
+				tx.Rollback()
+			} else if cerr := tx.Commit(); cerr != nil {
+				err = b.batchError(0, b.rows, fmt.Errorf("commit: %w", cerr))
+			}
+		}()
+		ex = tx
+	}
+
+	if b.opts.LoadData {
+		return b.loadData(ctx, ex)
+	}
+	if !b.opts.Param {
+		sq := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", b.table, b.fields, strings.Join(b.lits, "),("))
+		if _, err := ex.ExecContext(ctx, sq); err != nil {
+			return b.batchError(0, b.rows, err)
+		}
+		return nil
+	}
+	for start := 0; start < b.rows; start += b.chunk {
+		n := min(b.chunk, b.rows-start)
+		sq, ok := b.stmts[n]
+		if !ok {
+			sq = InsertStatement(b.table, b.fields, n, b.cols, b.opts.Placeholder)
+			b.stmts[n] = sq
+		}
+		if _, err := ex.ExecContext(ctx, sq, b.args[start*b.cols:(start+n)*b.cols]...); err != nil {
+			return b.batchError(start, n, err)
+		}
+	}
+	return nil
+}
+
+// batchError reports a failed statement of n buffered rows starting at start
+func (b *Inserter) batchError(start, n int, err error) error {
+	return &BatchError{Table: b.table, Batch: b.batch, FirstRow: b.count + int64(start) + 1, Rows: n, Row: b.first[start], Err: err}
+}
+
+// Close flushes the buffered rows, commits the TxPerLoad transaction and closes the
+// connection opened by OpenInserter. After a failure Close returns the failure
+// (the transaction has been rolled back).
+func (b *Inserter) Close() error {
+	if b.db == nil {
+		return nil
+	}
+	err := b.Flush(context.Background())
+	if err == nil && b.tx != nil {
+		if err = b.tx.Commit(); err != nil {
+			err = fmt.Errorf("%s: commit: %w", b.table, err)
+		}
+		b.tx = nil
+	}
+	b.release()
+	return err
+}
+
+// Rollback discards the buffered rows, rolls back the TxPerLoad transaction and closes
+// the Inserter. Rows written by earlier flushes without TxPerLoad stay inserted.
+func (b *Inserter) Rollback() error {
+	if b.db == nil {
+		return nil
+	}
+	var err error
+	if b.tx != nil {
+		err = b.tx.Rollback()
+		b.tx = nil
+	}
+	b.release()
+	return err
+}
+
+// release drops the buffers and closes the own connection
+func (b *Inserter) release() {
+	if b.owned != nil {
+		b.owned.Close()
+	}
+	b.db = nil
+	b.args, b.lits, b.first, b.rows = nil, nil, nil, 0
+	b.err = ErrInserterClosed
+}
@@ -0,0 +1,180 @@
+package sql_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	hbsql "github.com/parf/homebase-go-lib/sql"
+)
+
+func TestInserterLiteral(t *testing.T) {
+	db, d := openRecording(t)
+	ins, err := hbsql.NewInserter(db, "t", "id, name", hbsql.InserterOptions{BufferSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, row := range []any{[]any{1, "John's"}, "2, 'raw'", []any{3, nil}} {
+		if err := ins.Insert(ctx, row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ins.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ins.Insert(ctx, []any{4, "x"}); !errors.Is(err, hbsql.ErrInserterClosed) {
+		t.Errorf("Insert after Close: %v", err)
+	}
+
+	want := []string{
+		"INSERT INTO t (id, name) VALUES (1, 'John''s'),(2, 'raw')",
+		"INSERT INTO t (id, name) VALUES (3, NULL)",
+	}
+	if len(d.execs) != len(want) {
+		t.Fatalf("expected %d statements, got %d", len(want), len(d.execs))
+	}
+	for i, e := range d.execs {
+		if e.query != want[i] {
+			t.Errorf("statement %d = %q, want %q", i, e.query, want[i])
+		}
+	}
+	if ins.Count() != 3 {
+		t.Errorf("Count = %d, want 3", ins.Count())
+	}
+}
+
+func TestInserterBatchError(t *testing.T) {
+	db, d := openRecording(t)
+	ins, _ := hbsql.NewInserter(db, "t", "id, name", hbsql.InserterOptions{BufferSize: 4, Param: true, MaxParams: 4, Tx: hbsql.TxPerFlush})
+	ctx := context.Background()
+
+	// batch 1: rows 1-4 ok; batch 2: rows 5-6 ok, rows 7-8 fail
+	names := []string{"a", "b", "c", "d", "e", "f", "fail", "h"}
+	var err error
+	for i, name := range names {
+		if err = ins.Insert(ctx, []any{i + 1, name}); err != nil {
+			break
+		}
+	}
+	var be *hbsql.BatchError
+	if !errors.As(err, &be) {
+		t.Fatalf("expected *BatchError, got %v", err)
+	}
+	if be.Batch != 2 || be.FirstRow != 7 || be.Rows != 2 || be.Row.([]any)[0] != 7 || be.Table != "t" {
+		t.Errorf("unexpected %+v", be)
+	}
+	if !strings.Contains(err.Error(), "rows 7-8") {
+		t.Errorf("error text: %v", err)
+	}
+	if ins.Count() != 4 {
+		t.Errorf("Count = %d, want 4", ins.Count())
+	}
+
+	// the error is sticky
+	if err2 := ins.Insert(ctx, []any{9, "i"}); err2 != err {
+		t.Errorf("Insert after failure: %v", err2)
+	}
+	if err2 := ins.Close(); err2 != err {
+		t.Errorf("Close after failure: %v", err2)
+	}
+
+	events := strings.Join(d.events, ",")
+	if want := "begin,exec,exec,commit,begin,exec,exec failed,rollback"; events != want {
+		t.Errorf("events %s, want %s", events, want)
+	}
+}
+
+func TestInserterInvalidRow(t *testing.T) {
+	db, _ := openRecording(t)
+	ins, _ := hbsql.NewInserter(db, "t", "id, name", hbsql.InserterOptions{Param: true})
+	ctx := context.Background()
+
+	ins.Insert(ctx, []any{1, "a"})
+	err := ins.Insert(ctx, []any{2})
+	var be *hbsql.BatchError
+	if !errors.As(err, &be) || be.FirstRow != 2 || be.Rows != 1 {
+		t.Fatalf("unexpected %v", err)
+	}
+	// invalid rows are skipped, not sticky
+	if err := ins.Insert(ctx, "2, 'raw'"); err == nil {
+		t.Error("raw SQL accepted in Param mode")
+	}
+	if err := ins.Insert(ctx, []any{3, "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ins.Close(); err != nil || ins.Count() != 2 {
+		t.Errorf("Close: %v, Count %d", err, ins.Count())
+	}
+}
+
+func TestInserterTxPerLoad(t *testing.T) {
+	db, d := openRecording(t)
+	ins, _ := hbsql.NewInserter(db, "t", "id", hbsql.InserterOptions{BufferSize: 2, Param: true, Tx: hbsql.TxPerLoad})
+	ctx := context.Background()
+	for i := range 5 {
+		if err := ins.Insert(ctx, []int{i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ins.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if events, want := strings.Join(d.events, ","), "begin,exec,exec,exec,commit"; events != want {
+		t.Errorf("events %s, want %s", events, want)
+	}
+
+	// Rollback discards the load
+	d.events = nil
+	ins, _ = hbsql.NewInserter(db, "t", "id", hbsql.InserterOptions{BufferSize: 2, Param: true, Tx: hbsql.TxPerLoad})
+	for i := range 3 {
+		ins.Insert(ctx, []int{i})
+	}
+	if err := ins.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if events, want := strings.Join(d.events, ","), "begin,exec,rollback"; events != want {
+		t.Errorf("events %s, want %s", events, want)
+	}
+}
+
+func TestInserterTxPerLoadFlushContext(t *testing.T) {
+	db, d := openRecording(t)
+	for _, useCopy := range []bool{false, true} {
+		d.events = nil
+		ins, _ := hbsql.NewInserter(db, "t", "id", hbsql.InserterOptions{BufferSize: 2, Param: true, Copy: useCopy, Tx: hbsql.TxPerLoad})
+		// every flush under its own timeout - ending it must not end the load transaction
+		for i := range 4 {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			err := ins.Insert(ctx, []int{i})
+			cancel()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := ins.Close(); err != nil {
+			t.Fatalf("copy %v: Close: %v", useCopy, err)
+		}
+		if events := strings.Join(d.events, ","); !strings.HasSuffix(events, ",commit") {
+			t.Errorf("copy %v: events %s", useCopy, events)
+		}
+	}
+}
+
+func TestInserterContextCancel(t *testing.T) {
+	db, d := openRecording(t)
+	ins, _ := hbsql.NewInserter(db, "t", "id", hbsql.InserterOptions{BufferSize: 2, Param: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ins.Insert(ctx, []int{1})
+	err := ins.Insert(ctx, []int{2})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(d.execs) != 0 {
+		t.Errorf("%d statements executed after cancel", len(d.execs))
+	}
+}
@@ -0,0 +1,286 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// loadSeq makes reader handler names unique
+var loadSeq atomic.Int64
+
+// loadDataStatement returns the LOAD DATA statement reading reader handler name.
+// The default field format (tab separated, backslash escapes, \N for NULL) is used on purpose:
+// FIELDS/LINES clauses would need backslash escapes that change meaning under NO_BACKSLASH_ESCAPES.
+func loadDataStatement(name, table, fields string) string {
+	return fmt.Sprintf("LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE %s CHARACTER SET utf8mb4 (%s)", name, table, fields)
+}
+
+// AppendTSV appends val to buf as a field of LOAD DATA's default text format:
+// NULL is \N; backslash, tab, newline, carriage return and NUL are backslash-escaped;
+// booleans are 1/0 and floats keep full precision. time.Time is converted like
+// go-sql-driver does for INSERT: in loc (the DSN loc, nil - UTC), and the zero time
+// as 0000-00-00. driver.Valuer values are converted first.
+func AppendTSV(buf []byte, val any, loc *time.Location) ([]byte, error) {
+	if v, ok := val.(driver.Valuer); ok {
+		dv, err := v.Value()
+		if err != nil {
+			return buf, err
+		}
+		return AppendTSV(buf, dv, loc)
+	}
+
+	switch v := val.(type) {
+	case nil:
+		return append(buf, `\N`...), nil
+	case string:
+		return appendTSVEscaped(buf, v), nil
+	case []byte:
+		if v == nil {
+			return append(buf, `\N`...), nil
+		}
+		return appendTSVEscaped(buf, string(v)), nil
+	case bool:
+		if v {
+			return append(buf, '1'), nil
+		}
+		return append(buf, '0'), nil
+	case int:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int8:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int16:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int32:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(buf, v, 10), nil
+	case uint:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint8:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint16:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint32:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint64:
+		return strconv.AppendUint(buf, v, 10), nil
+	case float32:
+		return strconv.AppendFloat(buf, float64(v), 'g', -1, 32), nil
+	case float64:
+		return strconv.AppendFloat(buf, v, 'g', -1, 64), nil
+	case time.Time:
+		if v.IsZero() {
+			return append(buf, "0000-00-00"...), nil
+		}
+		if loc == nil {
+			loc = time.UTC
+		}
+		t := v.In(loc)
+		if t.Year() < 1 || t.Year() > 9999 {
+			return buf, fmt.Errorf("year is not in the range [1, 9999]: %d", t.Year())
+		}
+		if h, m, sec := t.Clock(); h == 0 && m == 0 && sec == 0 && t.Nanosecond() == 0 {
+			return t.AppendFormat(buf, "2006-01-02"), nil
+		}
+		return t.AppendFormat(buf, "2006-01-02 15:04:05.999999999"), nil
+	default:
+		return appendTSVEscaped(buf, fmt.Sprint(v)), nil
+	}
+}
+
+// appendTSVEscaped appends s with LOAD DATA escapes
+func appendTSVEscaped(buf []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			buf = append(buf, '\\', '\\')
+		case '\t':
+			buf = append(buf, '\\', 't')
+		case '\n':
+			buf = append(buf, '\\', 'n')
+		case '\r':
+			buf = append(buf, '\\', 'r')
+		case 0:
+			buf = append(buf, '\\', '0')
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
+
+// tsvReader streams rows of cols values as LOAD DATA text, encoding about 64KB at a time
+type tsvReader struct {
+	args []any
+	cols int
+	loc  *time.Location
+	buf  []byte
+	pos  int
+}
+
+func (r *tsvReader) Read(p []byte) (int, error) {
+	for r.pos == len(r.buf) {
+		if len(r.args) == 0 {
+			return 0, io.EOF
+		}
+		buf := r.buf[:0]
+		for len(buf) < 64<<10 && len(r.args) > 0 {
+			for i, v := range r.args[:r.cols] {
+				if i > 0 {
+					buf = append(buf, '\t')
+				}
+				var err error
+				if buf, err = AppendTSV(buf, v, r.loc); err != nil {
+					return 0, err
+				}
+			}
+			buf = append(buf, '\n')
+			r.args = r.args[r.cols:]
+		}
+		r.buf, r.pos = buf, 0
+	}
+	n := copy(p, r.buf[r.pos:])
+	r.pos += n
+	return n, nil
+}
+
+// loadData writes the buffered rows with LOAD DATA LOCAL INFILE through a registered reader handler.
+// With LOCAL the server turns row errors into warnings: duplicate keys skip the row, bad values
+// are converted. The statement then succeeds, so rows affected and SHOW WARNINGS are checked and
+// any loss is reported as *BatchError (without a transaction the other rows stay written).
+func (b *Inserter) loadData(ctx context.Context, ex execer) error {
+	if db, ok := ex.(*sql.DB); ok {
+		// SHOW WARNINGS must run on the connection of the LOAD DATA
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return b.batchError(0, b.rows, err)
+		}
+		defer conn.Close()
+		ex = conn
+	}
+
+	name := fmt.Sprintf("homebase-%d", loadSeq.Add(1))
+	r := &tsvReader{args: b.args[:b.rows*b.cols], cols: b.cols, loc: b.opts.Loc}
+	mysql.RegisterReaderHandler(name, func() io.Reader { return r })
+	defer mysql.DeregisterReaderHandler(name)
+
+	res, err := ex.ExecContext(ctx, loadDataStatement(name, b.table, b.fields))
+	if err != nil {
+		return b.batchError(0, b.rows, err)
+	}
+	var warning string
+	if q, ok := ex.(querier); ok {
+		if warning, err = loadWarning(ctx, q); err != nil {
+			return b.batchError(0, b.rows, fmt.Errorf("show warnings: %w", err))
+		}
+	}
+	if loaded, err := res.RowsAffected(); err == nil && loaded != int64(b.rows) {
+		return b.batchError(0, b.rows, fmt.Errorf("LOAD DATA loaded %d of %d rows: %s", loaded, b.rows, warning))
+	}
+	if warning != "" {
+		return b.batchError(0, b.rows, fmt.Errorf("LOAD DATA: %s", warning))
+	}
+	return nil
+}
+
+// querier runs queries: *sql.Conn and *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// loadWarning returns the first warning (or error) of the previous statement, "" if there is none
+func loadWarning(ctx context.Context, q querier) (string, error) {
+	rows, err := q.QueryContext(ctx, "SHOW WARNINGS")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var level, message string
+		var code int
+		if err := rows.Scan(&level, &code, &message); err != nil {
+			return "", err
+		}
+		if level != "Note" {
+			return fmt.Sprintf("%s %d: %s", level, code, message), nil
+		}
+	}
+	return "", rows.Err()
+}
+
+// mysqlLoc returns the loc of a go-sql-driver DSN (UTC if not set or not parsable)
+func mysqlLoc(dsn string) *time.Location {
+	if cfg, err := mysql.ParseDSN(dsn); err == nil && cfg.Loc != nil {
+		return cfg.Loc
+	}
+	return time.UTC
+}
+
+// MySQLBulkLoader is BatchInserter for MySQL loading every batch with
+// LOAD DATA LOCAL INFILE instead of INSERT - typically an order of magnitude faster.
+// Rows are streamed to the server as tab separated text (see AppendTSV) through a
+// go-sql-driver reader handler; the server must allow it (local_infile=ON).
+// Rows the server skips or converts (duplicate keys, bad values) are reported like
+// failed INSERTs; the other rows of that batch stay loaded.
+//
+// The insert function accepts a slice/array row with one value per field
+// (raw SQL strings are not supported). time.Time values are written in UTC, the
+// go-sql-driver default; MySQLBulkDBLoader uses the loc of its DSN. Errors panic; use
+// Inserter with InserterOptions.LoadData to get them returned.
+//
+// Usage:
+//
+//	insert, flush := sql.MySQLBulkLoader(db, "visits_log.hits", "id, url, created", 100000)
+//	defer flush()
+//
+//	for ... {
+//		insert([]any{id, url, time.Now()})
+//	}
+func MySQLBulkLoader(db *sql.DB, table string, fields string, bufferSize int) (insert func(any), flush func()) {
+	return mysqlBulkLoader(db, table, fields, bufferSize, nil)
+}
+
+func mysqlBulkLoader(db *sql.DB, table, fields string, bufferSize int, loc *time.Location) (insert func(any), flush func()) {
+	b, err := NewInserter(db, table, fields, InserterOptions{BufferSize: bufferSize, LoadData: true, Loc: loc})
+	if err != nil {
+		panic("MySQLBulkLoader: " + err.Error())
+	}
+	flush = func() {
+		if err := b.Flush(context.Background()); err != nil {
+			panic(err)
+		}
+	}
+	insert = func(values any) {
+		if err := b.Insert(context.Background(), values); err != nil {
+			panic(err)
+		}
+	}
+	return
+}
+
+// MySQLBulkDBLoader is MySQLBulkLoader with a new database connection (see BatchDBInserter).
+//
+// Usage:
+//
+//	insert, flush := sql.MySQLBulkDBLoader("parf:passwd@tcp(rxdb:3306)/visits_log?loc=Local", "hits", "id, url", 100000)
+//	defer flush()
+func MySQLBulkDBLoader(dsn, table, fields string, bufferSize int) (insert func(any), flushClose func()) {
+	_db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		panic(err)
+	}
+	insert, flush := mysqlBulkLoader(_db, table, fields, bufferSize, mysqlLoc(dsn))
+	flushClose = func() {
+		flush()
+		_db.Close()
+	}
+	return
+}
@@ -0,0 +1,258 @@
+package sql_test
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	hbsql "github.com/parf/homebase-go-lib/sql"
+)
+
+func TestAppendTSV(t *testing.T) {
+	est := time.FixedZone("EST", -5*3600)
+	tests := []struct {
+		name     string
+		input    any
+		loc      *time.Location
+		expected string
+	}{
+		{"nil", nil, nil, `\N`},
+		{"string", "John's Pizza", nil, "John's Pizza"},
+		{"escapes", "a\tb\nc\rd\\e\x00f", nil, `a\tb\nc\rd\\e\0f`},
+		{"literal \\N", `\N`, nil, `\\N`},
+		{"bytes", []byte{'x', '\t', 0xff}, nil, "x\\t\xff"},
+		{"nil bytes", []byte(nil), nil, `\N`},
+		{"int", -42, nil, "-42"},
+		{"uint64", uint64(math.MaxUint64), nil, "18446744073709551615"},
+		{"float64", math.Pi, nil, "3.141592653589793"},
+		{"small float", 1e-9, nil, "1e-09"},
+		{"bool", true, nil, "1"},
+		{"time", time.Date(2026, 10, 16, 14, 30, 0, 500000000, time.FixedZone("X", 2*3600)), nil, "2026-10-16 12:30:00.5"},
+		{"time in loc", time.Date(2026, 10, 16, 12, 30, 0, 0, time.UTC), est, "2026-10-16 07:30:00"},
+		{"midnight", time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), nil, "2026-10-16"},
+		{"zero time", time.Time{}, est, "0000-00-00"},
+		{"valuer", upperValuer("abc"), nil, "ABC"},
+		{"null valuer", sql.NullString{}, nil, `\N`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := hbsql.AppendTSV(nil, tt.input, tt.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("AppendTSV(%v) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := hbsql.AppendTSV(nil, time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC), nil); err == nil {
+		t.Error("Expected error for year 10000")
+	}
+}
+
+// fakeMySQL is a minimal MySQL server: it accepts any login, answers every query with OK
+// and serves LOAD DATA LOCAL INFILE requests, keeping the received file contents.
+// SHOW WARNINGS lists a duplicate key warning after a LOAD DATA that skipped rows.
+type fakeMySQL struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	loads []string // "statement\ndata" of every LOAD DATA
+	skip  int      // rows of every LOAD DATA reported as skipped duplicates
+}
+
+func startFakeMySQL(t *testing.T) *fakeMySQL {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen:", err)
+	}
+	s := &fakeMySQL{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakeMySQL) dsn() string {
+	return fmt.Sprintf("root@tcp(%s)/test", s.ln.Addr())
+}
+
+func (s *fakeMySQL) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	var seq byte
+	send := func(payload []byte) {
+		hdr := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), seq}
+		c.Write(append(hdr, payload...))
+		seq++
+	}
+	recv := func() ([]byte, bool) {
+		hdr := make([]byte, 4)
+		if _, err := io.ReadFull(r, hdr); err != nil {
+			return nil, false
+		}
+		payload := make([]byte, int(hdr[0])|int(hdr[1])<<8|int(hdr[2])<<16)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, false
+		}
+		seq = hdr[3] + 1
+		return payload, true
+	}
+	ok := []byte{0x00, 0, 0, 0x02, 0, 0, 0} // OK, autocommit
+	okRows := func(n int) []byte { return []byte{0x00, byte(n), 0, 0x02, 0, 0, 0} }
+	eof := []byte{0xfe, 0, 0, 0x02, 0}
+	lenenc := func(b []byte, s string) []byte { return append(append(b, byte(len(s))), s...) }
+	warnings := func(rows ...[]string) { // SHOW WARNINGS result set: Level, Code, Message
+		send([]byte{3})
+		for _, name := range []string{"Level", "Code", "Message"} {
+			col := lenenc(nil, "def")
+			for _, s := range []string{"", "", "", name, ""} {
+				col = lenenc(col, s)
+			}
+			col = append(col, 0x0c, 33, 0, 0, 1, 0, 0, 0xfd, 0, 0, 0, 0, 0) // utf8, VAR_STRING
+			send(col)
+		}
+		send(eof)
+		for _, row := range rows {
+			var p []byte
+			for _, s := range row {
+				p = lenenc(p, s)
+			}
+			send(p)
+		}
+		send(eof)
+	}
+	skipped := 0 // rows skipped by the last statement
+
+	// HandshakeV10 with mysql_native_password
+	const caps = 0x1 | 0x80 | 0x200 | 0x2000 | 0x8000 | 0x80000 // long password, local files, 4.1, transactions, secure conn, plugin auth
+	hs := []byte{10}
+	hs = append(hs, "8.0.0-fake\x00"...)
+	hs = binary.LittleEndian.AppendUint32(hs, 1)
+	hs = append(hs, "abcdefgh\x00"...)
+	hs = binary.LittleEndian.AppendUint16(hs, caps&0xffff)
+	hs = append(hs, 45)                               // utf8mb4
+	hs = binary.LittleEndian.AppendUint16(hs, 0x0002) // autocommit
+	hs = binary.LittleEndian.AppendUint16(hs, caps>>16)
+	hs = append(hs, 21)
+	hs = append(hs, make([]byte, 10)...)
+	hs = append(hs, "ijklmnopqrst\x00"...)
+	hs = append(hs, "mysql_native_password\x00"...)
+	send(hs)
+	if _, alive := recv(); !alive {
+		return
+	}
+	send(ok)
+
+	for {
+		cmd, alive := recv()
+		if !alive || len(cmd) == 0 || cmd[0] == 0x01 { // COM_QUIT
+			return
+		}
+		query := string(cmd[1:])
+		if cmd[0] == 0x03 && query == "SHOW WARNINGS" {
+			if skipped > 0 {
+				warnings([]string{"Warning", "1062", "Duplicate entry '1' for key 'PRIMARY'"})
+			} else {
+				warnings()
+			}
+			continue
+		}
+		if cmd[0] != 0x03 || !strings.HasPrefix(query, "LOAD DATA LOCAL INFILE '") {
+			skipped = 0
+			send(ok)
+			continue
+		}
+		name := strings.SplitN(query, "'", 3)[1]
+		send(append([]byte{0xfb}, name...)) // LOCAL INFILE request
+		var data []byte
+		for {
+			p, alive := recv()
+			if !alive {
+				return
+			}
+			if len(p) == 0 {
+				break
+			}
+			data = append(data, p...)
+		}
+		s.mu.Lock()
+		s.loads = append(s.loads, query+"\n"+string(data))
+		skipped = min(s.skip, strings.Count(string(data), "\n"))
+		s.mu.Unlock()
+		send(okRows(strings.Count(string(data), "\n") - skipped))
+	}
+}
+
+func TestMySQLBulkLoaderWire(t *testing.T) {
+	srv := startFakeMySQL(t)
+	insert, flush := hbsql.MySQLBulkDBLoader(srv.dsn()+"?loc=America%2FNew_York", "hits", "id, url, score, created", 2)
+
+	ts := time.Date(2026, 10, 16, 16, 30, 0, 0, time.UTC)
+	insert([]any{1, "/a\tb\\c\nd", math.Pi, ts})
+	insert([]any{2, nil, 1.5, nil})
+	insert([]any{3, []byte("x"), -1, ts})
+	flush()
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.loads) != 2 {
+		t.Fatalf("expected 2 loads, got %d", len(srv.loads))
+	}
+	first := strings.SplitN(srv.loads[0], "\n", 2)
+	if !strings.HasPrefix(first[0], "LOAD DATA LOCAL INFILE 'Reader::") || !strings.HasSuffix(first[0], "' INTO TABLE hits CHARACTER SET utf8mb4 (id, url, score, created)") {
+		t.Errorf("statement %q", first[0])
+	}
+	// times are written in the DSN loc
+	want := "1\t/a\\tb\\\\c\\nd\t3.141592653589793\t2026-10-16 12:30:00\n" +
+		"2\t\\N\t1.5\t\\N\n"
+	if first[1] != want {
+		t.Errorf("load 1:\n got %q\nwant %q", first[1], want)
+	}
+	if second := strings.SplitN(srv.loads[1], "\n", 2)[1]; second != "3\tx\t-1\t2026-10-16 12:30:00\n" {
+		t.Errorf("load 2: %q", second)
+	}
+}
+
+func TestInserterLoadDataSkippedRows(t *testing.T) {
+	srv := startFakeMySQL(t)
+	srv.skip = 1
+	ins, err := hbsql.OpenInserter("mysql", srv.dsn(), "hits", "id", hbsql.InserterOptions{BufferSize: 3, LoadData: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ins.Close()
+
+	ctx := context.Background()
+	for i := range 3 {
+		ins.Insert(ctx, []int{i})
+	}
+	err = ins.Flush(ctx)
+	var be *hbsql.BatchError
+	if !errors.As(err, &be) || be.Rows != 3 {
+		t.Fatalf("Flush: %v, want *BatchError for 3 rows", err)
+	}
+	if !strings.Contains(err.Error(), "loaded 2 of 3 rows") || !strings.Contains(err.Error(), "Duplicate entry") {
+		t.Errorf("error %q", err)
+	}
+	if ins.Count() != 0 {
+		t.Errorf("Count = %d, want 0", ins.Count())
+	}
+}
@@ -0,0 +1,128 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Placeholder is the bind parameter syntax of a SQL dialect
+type Placeholder int
+
+const (
+	// QuestionPlaceholder - "?" (MySQL, MariaDB, SQLite)
+	QuestionPlaceholder Placeholder = iota
+	// DollarPlaceholder - "$1", "$2", ... (PostgreSQL)
+	DollarPlaceholder
+)
+
+// DefaultMaxParams is the bind parameter limit of MySQL and PostgreSQL prepared statements
+const DefaultMaxParams = 65535
+
+// ParamBatchOptions configures ParamBatchInserter
+type ParamBatchOptions struct {
+	Placeholder Placeholder
+
+	// MaxParams is the maximum number of bind parameters in one statement
+	// (0 - DefaultMaxParams; use 32766 for SQLite, 999 for SQLite before 3.32).
+	// A flush of more than MaxParams values is split into several statements.
+	MaxParams int
+}
+
+// InsertStatement returns a multi-row INSERT with bind parameters for rows x cols values:
+//
+//	INSERT INTO t (a, b) VALUES (?,?),(?,?)
+//	INSERT INTO t (a, b) VALUES ($1,$2),($3,$4)
+func InsertStatement(table, fields string, rows, cols int, ph Placeholder) string {
+	var sb strings.Builder
+	sb.WriteString("INSERT INTO ")
+	sb.WriteString(table)
+	sb.WriteString(" (")
+	sb.WriteString(fields)
+	sb.WriteString(") VALUES ")
+	n := 0
+	for r := 0; r < rows; r++ {
+		if r > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteByte('(')
+		for c := 0; c < cols; c++ {
+			if c > 0 {
+				sb.WriteByte(',')
+			}
+			n++
+			if ph == DollarPlaceholder {
+				sb.WriteByte('$')
+				sb.WriteString(strconv.Itoa(n))
+			} else {
+				sb.WriteByte('?')
+			}
+		}
+		sb.WriteByte(')')
+	}
+	return sb.String()
+}
+
+// rowValues returns the elements of a slice/array row as they are, without escaping
+func rowValues(values any) ([]any, error) {
+	if row, ok := values.([]any); ok {
+		return row, nil
+	}
+	v := reflect.ValueOf(values)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array || v.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, fmt.Errorf("values must be a slice or array (got %T)", values)
+	}
+	row := make([]any, v.Len())
+	for i := range row {
+		row[i] = v.Index(i).Interface()
+	}
+	return row, nil
+}
+
+// ParamBatchInserter is BatchInserter with bind parameters instead of literal SQL:
+// values are sent to the driver as they are, so there is no escaping to get wrong
+// (NO_BACKSLASH_ESCAPES, standard_conforming_strings), floats keep full precision
+// and []byte, time.Time and driver.Valuer values are passed natively.
+//
+// The insert function accepts a slice/array row with one value per field
+// (raw SQL strings are not supported). Rows are flushed every bufferSize rows as
+// multi-row INSERT statements, split so that no statement has more than
+// opts.MaxParams bind parameters. Errors panic; use Inserter to get them returned.
+//
+// Example:
+//
+//	insert, flush := sql.ParamBatchInserter(db, "users", "id, name, avatar, created", 1000,
+//	    sql.ParamBatchOptions{Placeholder: sql.DollarPlaceholder})
+//	defer flush()
+//
+//	insert([]any{1, "John's Pizza", pngBytes, time.Now()})
+func ParamBatchInserter(db *sql.DB, table string, fields string, bufferSize int, opts ParamBatchOptions) (insert func(any), flush func()) {
+	b, err := NewInserter(db, table, fields, InserterOptions{
+		BufferSize:  bufferSize,
+		Param:       true,
+		Placeholder: opts.Placeholder,
+		MaxParams:   opts.MaxParams,
+	})
+	if err != nil {
+		panic("ParamBatchInserter: " + err.Error())
+	}
+	flush = func() {
+		if err := b.Flush(context.Background()); err != nil {
+			panic(err)
+		}
+	}
+	insert = func(values any) {
+		if err := b.Insert(context.Background(), values); err != nil {
+			panic(err)
+		}
+	}
+	return
+}
+
+// PostgreParamBatchInserter is ParamBatchInserter with PostgreSQL "$n" placeholders
+func PostgreParamBatchInserter(db *sql.DB, table string, fields string, bufferSize int) (insert func(any), flush func()) {
+	return ParamBatchInserter(db, table, fields, bufferSize, ParamBatchOptions{Placeholder: DollarPlaceholder})
+}
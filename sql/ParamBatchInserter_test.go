@@ -0,0 +1,241 @@
+package sql_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	hbsql "github.com/parf/homebase-go-lib/sql"
+)
+
+// recordingDriver records executed statements and their arguments;
+// statements with a "fail" value fail, a "slow" value takes 20ms. COPY statements (inside a transaction)
+// are recorded with the values of all rows.
+type recordingDriver struct {
+	mu      sync.Mutex
+	execs   []recordedExec
+	events  []string // begin, exec, copy, commit, rollback
+	copyErr error    // COPY statements fail to prepare with it
+}
+
+func (d *recordingDriver) event(e string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.events = append(d.events, e)
+}
+
+type recordedExec struct {
+	query string
+	args  []driver.Value
+}
+
+func (d *recordingDriver) Open(string) (driver.Conn, error) { return &recordingConn{d: d}, nil }
+
+// Connect and Driver make recordingDriver a driver.Connector for sql.OpenDB
+func (d *recordingDriver) Connect(context.Context) (driver.Conn, error) {
+	return &recordingConn{d: d}, nil
+}
+func (d *recordingDriver) Driver() driver.Driver { return d }
+
+type recordingConn struct {
+	d    *recordingDriver
+	inTx bool
+}
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	if strings.HasPrefix(query, "COPY ") {
+		switch {
+		case c.d.copyErr != nil:
+			return nil, c.d.copyErr
+		case !c.inTx:
+			return nil, errors.New("COPY is only allowed inside a transaction")
+		}
+		return &recordingCopy{d: c.d, query: query}, nil
+	}
+	return &recordingStmt{c.d, query}, nil
+}
+func (c *recordingConn) Close() error { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	c.d.event("begin")
+	c.inTx = true
+	return recordingTx{c}, nil
+}
+
+type recordingTx struct{ c *recordingConn }
+
+func (tx recordingTx) Commit() error   { tx.c.inTx = false; tx.c.d.event("commit"); return nil }
+func (tx recordingTx) Rollback() error { tx.c.inTx = false; tx.c.d.event("rollback"); return nil }
+
+// recordingCopy collects COPY rows; Exec without values ends the COPY
+type recordingCopy struct {
+	d     *recordingDriver
+	query string
+	rows  []driver.Value
+}
+
+func (s *recordingCopy) Close() error  { return nil }
+func (s *recordingCopy) NumInput() int { return -1 }
+func (s *recordingCopy) Exec(args []driver.Value) (driver.Result, error) {
+	if len(args) > 0 {
+		s.rows = append(s.rows, args...)
+		return driver.RowsAffected(0), nil
+	}
+	s.d.event("copy")
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.execs = append(s.d.execs, recordedExec{s.query, s.rows})
+	return driver.RowsAffected(0), nil
+}
+func (s *recordingCopy) Query([]driver.Value) (driver.Rows, error) { return nil, driver.ErrSkip }
+
+type recordingStmt struct {
+	d     *recordingDriver
+	query string
+}
+
+func (s *recordingStmt) Close() error  { return nil }
+func (s *recordingStmt) NumInput() int { return -1 }
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	for _, a := range args {
+		if a == "fail" {
+			s.d.event("exec failed")
+			return nil, errors.New("rejected value")
+		}
+		if a == "slow" {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	if strings.Contains(s.query, "'fail'") {
+		s.d.event("exec failed")
+		return nil, errors.New("rejected value")
+	}
+	s.d.event("exec")
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.execs = append(s.d.execs, recordedExec{s.query, args})
+	return driver.RowsAffected(0), nil
+}
+func (s *recordingStmt) Query([]driver.Value) (driver.Rows, error) { return nil, driver.ErrSkip }
+
+// openRecording opens a database on a new recordingDriver
+func openRecording(t *testing.T) (*sql.DB, *recordingDriver) {
+	d := &recordingDriver{}
+	db := sql.OpenDB(d)
+	t.Cleanup(func() { db.Close() })
+	return db, d
+}
+
+// upperValuer is a driver.Valuer stored as an upper-cased string
+type upperValuer string
+
+func (v upperValuer) Value() (driver.Value, error) { return strings.ToUpper(string(v)), nil }
+
+func TestInsertStatement(t *testing.T) {
+	tests := []struct {
+		ph       hbsql.Placeholder
+		expected string
+	}{
+		{hbsql.QuestionPlaceholder, "INSERT INTO t (a, b) VALUES (?,?),(?,?)"},
+		{hbsql.DollarPlaceholder, "INSERT INTO t (a, b) VALUES ($1,$2),($3,$4)"},
+	}
+	for _, tt := range tests {
+		if got := hbsql.InsertStatement("t", "a, b", 2, 2, tt.ph); got != tt.expected {
+			t.Errorf("InsertStatement = %q, want %q", got, tt.expected)
+		}
+	}
+}
+
+func TestParamBatchInserterNativeValues(t *testing.T) {
+	db, d := openRecording(t)
+	ts := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	blob := []byte{0, '\'', '\\', 0xff}
+
+	insert, flush := hbsql.PostgreParamBatchInserter(db, "t", "id, name, price, data, created, tag", 10)
+	insert([]any{1, `It's\cool`, math.Pi, blob, ts, upperValuer("x")})
+	insert([]any{2, nil, 1e-9, []byte{}, ts, upperValuer("y")})
+	flush()
+	flush() // nothing buffered
+
+	if len(d.execs) != 1 {
+		t.Fatalf("expected 1 statement, got %d", len(d.execs))
+	}
+	e := d.execs[0]
+	if want := hbsql.InsertStatement("t", "id, name, price, data, created, tag", 2, 6, hbsql.DollarPlaceholder); e.query != want {
+		t.Errorf("query = %q, want %q", e.query, want)
+	}
+	if len(e.args) != 12 {
+		t.Fatalf("expected 12 args, got %d", len(e.args))
+	}
+	if e.args[1] != `It's\cool` {
+		t.Errorf("string changed: %q", e.args[1])
+	}
+	if e.args[2] != math.Pi || e.args[8] != 1e-9 {
+		t.Errorf("floats lost precision: %v %v", e.args[2], e.args[8])
+	}
+	if b, ok := e.args[3].([]byte); !ok || string(b) != string(blob) {
+		t.Errorf("blob = %#v", e.args[3])
+	}
+	if tm, ok := e.args[4].(time.Time); !ok || !tm.Equal(ts) {
+		t.Errorf("time = %#v", e.args[4])
+	}
+	if e.args[5] != "X" || e.args[11] != "Y" {
+		t.Errorf("valuers = %v %v", e.args[5], e.args[11])
+	}
+	if e.args[7] != nil {
+		t.Errorf("nil = %#v", e.args[7])
+	}
+}
+
+func TestParamBatchInserterChunking(t *testing.T) {
+	db, d := openRecording(t)
+
+	// 3 fields, at most 10 parameters - 3 rows per statement
+	insert, flush := hbsql.ParamBatchInserter(db, "t", "a, b, c", 8, hbsql.ParamBatchOptions{MaxParams: 10})
+	for i := range 11 {
+		insert([]int{i, i, i})
+	}
+	flush()
+
+	// 8 rows flushed as 3+3+2, then the remaining 3 rows
+	rows := []int{3, 3, 2, 3}
+	if len(d.execs) != len(rows) {
+		t.Fatalf("expected %d statements, got %d", len(rows), len(d.execs))
+	}
+	next := int64(0)
+	for i, e := range d.execs {
+		if want := hbsql.InsertStatement("t", "a, b, c", rows[i], 3, hbsql.QuestionPlaceholder); e.query != want {
+			t.Errorf("statement %d = %q, want %q", i, e.query, want)
+		}
+		for j := 0; j < len(e.args); j += 3 {
+			if e.args[j] != next {
+				t.Errorf("statement %d: row value %v, want %d", i, e.args[j], next)
+			}
+			next++
+		}
+	}
+	if next != 11 {
+		t.Errorf("inserted %d rows, want 11", next)
+	}
+}
+
+func TestParamBatchInserterWrongRow(t *testing.T) {
+	db, _ := openRecording(t)
+	insert, _ := hbsql.ParamBatchInserter(db, "t", "a, b", 10, hbsql.ParamBatchOptions{})
+
+	for _, row := range []any{[]any{1}, "1, 2", []byte("ab")} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("insert(%#v) did not panic", row)
+				}
+			}()
+			insert(row)
+		}()
+	}
+}
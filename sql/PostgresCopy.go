@@ -0,0 +1,110 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/lib/pq"
+)
+
+// copyState tracks COPY FROM STDIN use of an Inserter
+type copyState int
+
+const (
+	copyOff copyState = iota // multi-row INSERT
+	copyTry                  // COPY, falls back to INSERT when the first COPY is unsupported
+	copyOn                   // COPY worked - failures are reported
+)
+
+// errCopyUnavailable makes the first flush fall back to multi-row INSERT
+var errCopyUnavailable = errors.New("COPY FROM STDIN unavailable")
+
+// IsLibPQ reports whether db uses the lib/pq driver, which supports COPY FROM STDIN
+func IsLibPQ(db *sql.DB) bool {
+	_, ok := db.Driver().(*pq.Driver)
+	return ok
+}
+
+// copyStatement returns the COPY statement for table and fields. It is the statement
+// pq.CopyIn builds, but with identifiers left unquoted like in INSERT: "schema.table"
+// keeps working and names fold to lower case the same way.
+func copyStatement(table, fields string) string {
+	return fmt.Sprintf("COPY %s (%s) FROM STDIN", table, fields)
+}
+
+// copyUnsupported reports whether a failed COPY start means COPY FROM STDIN is not
+// available - another driver, or a server without it - rather than a failure of the load
+// (cancelled context, lost connection, missing table, permission denied, ...)
+func copyUnsupported(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, driver.ErrBadConn) {
+		return false
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// feature_not_supported, syntax_error (servers speaking the protocol without COPY)
+		return pqErr.Code == "0A000" || pqErr.Code == "42601"
+	}
+	var netErr net.Error
+	return !errors.As(err, &netErr)
+}
+
+// copyFlush writes the buffered rows with COPY FROM STDIN. lib/pq encodes the values in
+// COPY text format (\N for NULL, backslash escapes, hex bytea) and only allows COPY inside
+// a transaction, so without TxPerLoad every batch gets one.
+func (b *Inserter) copyFlush(ctx context.Context) (err error) {
+	tx, own := b.tx, false
+	if tx == nil {
+		txCtx := ctx
+		if b.opts.Tx == TxPerLoad {
+			txCtx = context.WithoutCancel(ctx) // outlives this flush, see flush
+		}
+		if tx, err = b.db.BeginTx(txCtx, nil); err != nil {
+			return b.batchError(0, b.rows, err)
+		}
+		if b.opts.Tx == TxPerLoad {
+			b.tx = tx
+		} else {
+			own = true
+		}
+	}
+
+	stmt, err := tx.PrepareContext(ctx, copyStatement(b.table, b.fields))
+	if err != nil {
+		if b.copy == copyTry && copyUnsupported(ctx, err) {
+			// nothing written yet - drop the (possibly aborted) transaction and use INSERT
+			tx.Rollback()
+			b.tx = nil
+			return errCopyUnavailable
+		}
+		if own {
+			tx.Rollback()
+		}
+		return b.batchError(0, b.rows, err)
+	}
+
+	for i := 0; i < b.rows && err == nil; i++ {
+		_, err = stmt.ExecContext(ctx, b.args[i*b.cols:(i+1)*b.cols]...)
+	}
+	if err == nil {
+		_, err = stmt.ExecContext(ctx) // ends the COPY and reports errors of the sent rows
+	}
+	stmt.Close()
+	if err != nil {
+		// lib/pq reports server errors asynchronously - the whole batch is named
+		if own {
+			tx.Rollback()
+		}
+		return b.batchError(0, b.rows, err)
+	}
+	if own {
+		if err := tx.Commit(); err != nil {
+			return b.batchError(0, b.rows, fmt.Errorf("commit: %w", err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package sql_test
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	hbsql "github.com/parf/homebase-go-lib/sql"
+)
+
+func TestInserterCopy(t *testing.T) {
+	db, d := openRecording(t)
+	ctx := context.Background()
+	ins, err := hbsql.NewInserter(db, "s.t", "id, name", hbsql.InserterOptions{BufferSize: 2, Copy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		if err := ins.Insert(ctx, []any{int64(i), "x"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ins.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if events, want := strings.Join(d.events, ","), "begin,copy,commit,begin,copy,commit"; events != want {
+		t.Errorf("events %s, want %s", events, want)
+	}
+	if q := d.execs[0].query; q != "COPY s.t (id, name) FROM STDIN" {
+		t.Errorf("query = %q", q)
+	}
+	if len(d.execs[0].args) != 4 || d.execs[0].args[2] != int64(1) {
+		t.Errorf("copied %v", d.execs[0].args)
+	}
+}
+
+func TestInserterCopyFallback(t *testing.T) {
+	db, d := openRecording(t)
+	d.copyErr = errors.New("COPY not supported")
+	ctx := context.Background()
+
+	ins, _ := hbsql.NewInserter(db, "t", "id", hbsql.InserterOptions{BufferSize: 2, Copy: true})
+	for i := range 4 {
+		if err := ins.Insert(ctx, []int{i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ins.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// COPY is tried once, then batches are inserted with $n placeholders
+	if events, want := strings.Join(d.events, ","), "begin,rollback,exec,exec"; events != want {
+		t.Errorf("events %s, want %s", events, want)
+	}
+	if q := d.execs[0].query; q != "INSERT INTO t (id) VALUES ($1),($2)" {
+		t.Errorf("query = %q", q)
+	}
+
+	// the whole-load transaction restarts with INSERT
+	d.events = nil
+	ins, _ = hbsql.NewInserter(db, "t", "id", hbsql.InserterOptions{BufferSize: 2, Copy: true, Tx: hbsql.TxPerLoad})
+	for i := range 4 {
+		ins.Insert(ctx, []int{i})
+	}
+	if err := ins.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if events, want := strings.Join(d.events, ","), "begin,rollback,begin,exec,exec,commit"; events != want {
+		t.Errorf("events %s, want %s", events, want)
+	}
+}
+
+func TestInserterCopyErrors(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		err      error
+		fallback bool
+	}{
+		{"other driver", errors.New("COPY not supported"), true},
+		{"feature not supported", &pq.Error{Code: "0A000"}, true},
+		{"missing table", &pq.Error{Code: "42P01", Message: `relation "t" does not exist`}, false},
+		{"permission denied", &pq.Error{Code: "42501"}, false},
+		{"bad connection", driver.ErrBadConn, false},
+		{"cancelled", fmt.Errorf("pq: %w", context.Canceled), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, d := openRecording(t)
+			d.copyErr = tt.err
+			ins, _ := hbsql.NewInserter(db, "t", "id", hbsql.InserterOptions{BufferSize: 2, Copy: true})
+			ins.Insert(ctx, []int{1})
+			err := ins.Close()
+			if tt.fallback {
+				if err != nil || len(d.execs) != 1 {
+					t.Errorf("expected INSERT fallback, got %v (%d statements)", err, len(d.execs))
+				}
+				return
+			}
+			var be *hbsql.BatchError
+			if !errors.As(err, &be) || len(d.execs) != 0 {
+				t.Errorf("expected *BatchError, got %v (%d statements)", err, len(d.execs))
+			}
+		})
+	}
+}
+
+// fakePostgres is a minimal PostgreSQL server: it accepts any login and answers
+// BEGIN/COMMIT/ROLLBACK and COPY FROM STDIN, keeping the received COPY data
+type fakePostgres struct {
+	ln     net.Listener
+	mu     sync.Mutex
+	copies []string // "statement\ndata" of every COPY
+}
+
+func startFakePostgres(t *testing.T) *fakePostgres {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen:", err)
+	}
+	s := &fakePostgres{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakePostgres) dsn() string {
+	addr := s.ln.Addr().(*net.TCPAddr)
+	return fmt.Sprintf("host=127.0.0.1 port=%d user=test dbname=test sslmode=disable", addr.Port)
+}
+
+func (s *fakePostgres) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	send := func(typ byte, payload ...[]byte) {
+		n := 4
+		for _, p := range payload {
+			n += len(p)
+		}
+		msg := []byte{typ, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(msg[1:], uint32(n))
+		for _, p := range payload {
+			msg = append(msg, p...)
+		}
+		c.Write(msg)
+	}
+	cstr := func(s string) []byte { return append([]byte(s), 0) }
+	ready := func(status byte) { send('Z', []byte{status}) }
+
+	// startup packet: length, protocol, parameters
+	var size uint32
+	if binary.Read(r, binary.BigEndian, &size) != nil {
+		return
+	}
+	if _, err := io.ReadFull(r, make([]byte, size-4)); err != nil {
+		return
+	}
+	send('R', []byte{0, 0, 0, 0}) // AuthenticationOk
+	send('S', cstr("server_version"), cstr("16.0"))
+	ready('I')
+
+	for {
+		typ, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		if binary.Read(r, binary.BigEndian, &size) != nil {
+			return
+		}
+		body := make([]byte, size-4)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+		if typ == 'X' {
+			return
+		}
+		if typ != 'Q' {
+			send('E', []byte("SERROR\x00C0A000\x00Mnot supported by the fake server\x00\x00"))
+			ready('I')
+			continue
+		}
+
+		query := strings.TrimRight(string(body), "\x00")
+		switch {
+		case strings.HasPrefix(query, "BEGIN"):
+			send('C', cstr("BEGIN"))
+			ready('T')
+		case query == "COMMIT" || query == "ROLLBACK":
+			send('C', cstr(query))
+			ready('I')
+		case strings.HasPrefix(query, "COPY "):
+			send('G', []byte{0, 0, 0}) // CopyInResponse: text format, no column formats
+			var data []byte
+			rows := 0
+			for {
+				typ, _ := r.ReadByte()
+				if binary.Read(r, binary.BigEndian, &size) != nil {
+					return
+				}
+				body := make([]byte, size-4)
+				if _, err := io.ReadFull(r, body); err != nil {
+					return
+				}
+				if typ == 'd' {
+					data = append(data, body...)
+					continue
+				}
+				rows = strings.Count(string(data), "\n")
+				break
+			}
+			s.mu.Lock()
+			s.copies = append(s.copies, query+"\n"+string(data))
+			s.mu.Unlock()
+			send('C', cstr(fmt.Sprintf("COPY %d", rows)))
+			ready('T')
+		default:
+			send('E', []byte("SERROR\x00C42601\x00Munexpected query "+query+"\x00\x00"))
+			ready('I')
+		}
+	}
+}
+
+func TestPostgreBatchInserterCopyWire(t *testing.T) {
+	srv := startFakePostgres(t)
+	db, err := sql.Open("postgres", srv.dsn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if !hbsql.IsLibPQ(db) {
+		t.Fatal("postgres driver is not lib/pq")
+	}
+
+	ts := time.Date(2026, 10, 16, 12, 30, 0, 0, time.UTC)
+	insert, flush := hbsql.PostgreBatchInserter(db, "public.events", "id, name, price, ok, data, created", 10)
+	insert([]any{1, "tab\there\nnew line\\back", math.Pi, true, []byte{0xde, 0xad}, ts})
+	insert([]any{2, nil, -1.5, false, nil, ts})
+	flush()
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.copies) != 1 {
+		t.Fatalf("expected 1 COPY, got %d", len(srv.copies))
+	}
+	got := strings.Split(srv.copies[0], "\n")
+	want := []string{
+		"COPY public.events (id, name, price, ok, data, created) FROM STDIN",
+		`1	tab\there\nnew line\\back	3.141592653589793	true	\\xdead	2026-10-16 12:30:00Z`,
+		`2	\N	-1.5	false	\N	2026-10-16 12:30:00Z`,
+		"",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("COPY data:\n%s", srv.copies[0])
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d:\n got %q\nwant %q", i, got[i], want[i])
+		}
+	}
+}